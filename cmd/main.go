@@ -1,10 +1,14 @@
 package main
 
 import (
+	"os"
+	"strconv"
+
 	"github.com/gofiber/fiber/v2"
 	_ "github.com/tison2810/be-go-tc/cmd/docs"
 	"github.com/tison2810/be-go-tc/database"
 	"github.com/tison2810/be-go-tc/middleware"
+	"github.com/tison2810/be-go-tc/services"
 )
 
 // @title           My API
@@ -20,6 +24,9 @@ import (
 // @name Authorization
 func main() {
 	database.ConnectDb()
+	services.StartJobeHealthChecks()
+	services.StartRunQueue(runWorkers())
+	services.FailInterruptedStabilityChecks()
 	app := fiber.New()
 	middleware.FiberMiddleware(app)
 	publicRoutes(app)
@@ -27,3 +34,12 @@ func main() {
 	privateRoutes(app)
 	app.Listen(":3000")
 }
+
+// runWorkers đọc số worker của hàng đợi run từ RUN_WORKERS, mặc định là 4
+func runWorkers() int {
+	workers, err := strconv.Atoi(os.Getenv("RUN_WORKERS"))
+	if err != nil || workers <= 0 {
+		return 4
+	}
+	return workers
+}
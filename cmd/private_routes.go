@@ -9,8 +9,11 @@ import (
 func privateRoutes(app *fiber.App) {
 	// private := app.Group("/api/private", middleware.AuthMiddleware())
 	private := app.Group("/", middleware.AuthMiddleware())
+	// Các route chạy code dùng chung quota của mỗi người dùng
+	runLimit := middleware.RunRateLimit()
 	// private.Post("/create", handlers.CreatePost)
 	private.Post("/create", handlers.CreatePost)
+	private.Post("/create/preview", runLimit, handlers.PreviewExpected)
 	private.Post("/confirm/:id", handlers.PostAnyway)
 	// private.Put("/post/:id", handlers.UpdatePostFormData)
 	private.Get("/posts", handlers.GetAllPosts)
@@ -22,11 +25,17 @@ func privateRoutes(app *fiber.App) {
 	private.Put("/post/:id/like", handlers.LikePost)
 	private.Get("/post/:id/related", handlers.GetRelatedPosts)
 	private.Get("/post/:id/comments", handlers.GetPostComment)
+	private.Get("/post/:id/runs", handlers.GetPostRuns)
+	private.Get("/post/:id/runs/best", handlers.GetPostBestResult)
 	private.Post("/posts/read", handlers.ReadPost)
 	private.Post("/posts/search", handlers.SearchPosts)
 	private.Get("/checkfile", handlers.CheckFileExist)
 	private.Get("/sgposts", handlers.GetPostForStudent)
 	private.Post("/verify/:id", handlers.VerifyPost)
+	private.Post("/post/:id/validate", handlers.ValidatePost)
+	private.Post("/post/:id/stability", runLimit, handlers.CheckPostStability)
+	private.Get("/post/:id/stability", handlers.GetPostStability)
+	private.Get("/post/:id/suspected-expected", handlers.GetSuspectedExpected)
 	// private.Post("/comment", handlers.CreateComment)
 	// private.Put("/comment/:id", handlers.UpdateComment)
 	private.Delete("/comment/:id", handlers.DeleteComment)
@@ -34,17 +43,43 @@ func privateRoutes(app *fiber.App) {
 	private.Put("/comment/:id", handlers.UpdateCommentFormData)
 
 	private.Get("/jobe/languages", handlers.CheckJobeLanguages)
-	private.Put("/jobe/files/:id", handlers.UploadSingleFileToJobeHandler)
+	private.Get("/jobe/nodes", handlers.GetJobeNodes)
+	private.Put("/jobe/files/:scope/:name", handlers.UploadSingleFileToJobeHandler)
 	private.Head("/jobe/files/:id", handlers.CheckFile)
-	private.Post("/jobe/run", handlers.SubmitRun)
+	private.Post("/jobe/run", runLimit, handlers.SubmitRun)
+
+	private.Get("/assignments", handlers.GetAssignments)
+	private.Post("/assignments", handlers.CreateAssignment)
+	private.Get("/assignment/:id", handlers.GetAssignment)
+	private.Put("/assignment/:id", handlers.UpdateAssignment)
+	private.Delete("/assignment/:id", handlers.DeleteAssignment)
+	private.Get("/assignment/:id/harness", handlers.GetHarnessVersions)
+	private.Post("/assignment/:id/harness", handlers.CreateHarnessVersion)
+	private.Put("/assignment/:id/harness/:version/activate", handlers.ActivateHarnessVersion)
+	private.Post("/assignment/:id/regrade", handlers.RegradeAssignment)
+	private.Get("/assignment/:id/reference", handlers.GetReferenceSolution)
+	private.Put("/assignment/:id/reference", handlers.UploadReferenceSolution)
+	private.Delete("/assignment/:id/reference", handlers.DeleteReferenceSolution)
+	private.Get("/assignment/:id/similarity", handlers.GetSimilarityReport)
+	private.Get("/assignment/:id/similarity/pair", handlers.GetSimilarityPair)
+	private.Get("/limits", handlers.GetCourseLimit)
+	private.Put("/limits", handlers.UpdateCourseLimit)
 
 	private.Post("/upload", handlers.UploadTwoFilesHandler)
-	private.Get("/runcode/:id", handlers.RunCode)
+	private.Get("/runcode/:id", runLimit, handlers.RunCode)
+	private.Get("/runcode/:id/stream", runLimit, handlers.StreamRunCode)
+	private.Get("/runs/queue", handlers.GetRunQueueStats)
+	private.Get("/runs/:id", handlers.GetRunStatus)
 
 	private.Get("/user/posts", handlers.GetUserPosts)
 	private.Get("/user/likedposts", handlers.GetLikedPosts)
 	private.Get("/user/commentposts/:id", handlers.GetPostComment)
 	private.Get("/user/commentedposts", handlers.GetUserComments)
+	private.Get("/user/runs", handlers.GetUserRuns)
+	private.Get("/user/runs/best", handlers.GetBestResults)
+	private.Get("/user/uploads", handlers.GetUserUploads)
+	private.Get("/user/uploads/diff", handlers.DiffUserUploads)
+	private.Post("/user/uploads/:version/restore", handlers.RestoreUserUpload)
 
 	// private.Post("/interactions", handlers.CreateInteraction)
 	// private.Get("/interactions", handlers.GetAllInteractions)
@@ -0,0 +1,89 @@
+// Package comparator chứa các cách so sánh output của sinh viên với expected của testcase.
+package comparator
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Các chế độ so sánh được lưu trong testcase
+const (
+	ModeExact            = "exact"             // Giống hệt nhau sau khi bỏ khoảng trắng ở đầu/cuối toàn bộ output
+	ModeIgnoreWhitespace = "ignore_whitespace" // Bỏ qua khác biệt khoảng trắng trong từng dòng và CRLF
+	ModeCaseInsensitive  = "case_insensitive"  // Như ignore_whitespace nhưng không phân biệt hoa thường
+	ModeNumeric          = "numeric"           // So sánh số thực với sai số tuyệt đối hoặc tương đối
+	ModeRegex            = "regex"             // Mỗi dòng expected là một biểu thức chính quy
+	ModeWildcard         = "wildcard"          // Mỗi dòng expected có thể chứa * và ?
+	ModeUnordered        = "unordered"         // Các dòng có thể xuất hiện theo thứ tự bất kỳ
+)
+
+// Comparator so sánh output thực tế với expected
+type Comparator interface {
+	Compare(expected, actual string) bool
+}
+
+// Options là tham số bổ sung cho một số chế độ so sánh
+type Options struct {
+	AbsoluteEpsilon float64 `json:"absolute_epsilon,omitempty"`
+	RelativeEpsilon float64 `json:"relative_epsilon,omitempty"`
+}
+
+// New tạo Comparator theo chế độ, chuỗi rỗng tương đương ModeExact
+func New(mode string, options Options) (Comparator, error) {
+	switch mode {
+	case "", ModeExact:
+		return Exact{}, nil
+	case ModeIgnoreWhitespace:
+		return IgnoreWhitespace{}, nil
+	case ModeCaseInsensitive:
+		return CaseInsensitive{}, nil
+	case ModeNumeric:
+		if options.AbsoluteEpsilon < 0 || options.RelativeEpsilon < 0 {
+			return nil, fmt.Errorf("comparator: epsilon must not be negative")
+		}
+		return Numeric{AbsoluteEpsilon: options.AbsoluteEpsilon, RelativeEpsilon: options.RelativeEpsilon}, nil
+	case ModeRegex:
+		return Regex{}, nil
+	case ModeWildcard:
+		return Wildcard{}, nil
+	case ModeUnordered:
+		return Unordered{}, nil
+	default:
+		return nil, fmt.Errorf("comparator: unknown mode %q", mode)
+	}
+}
+
+// Validate kiểm tra expected có dùng được với chế độ so sánh hay không,
+// ví dụ các dòng regex phải biên dịch được
+func Validate(mode string, options Options, expected string) error {
+	if _, err := New(mode, options); err != nil {
+		return err
+	}
+	if mode == ModeRegex {
+		for i, line := range splitLines(expected) {
+			if _, err := compileLine(line); err != nil {
+				return fmt.Errorf("comparator: invalid regex on line %d: %v", i+1, err)
+			}
+		}
+	}
+	return nil
+}
+
+// splitLines chuẩn hóa CRLF, bỏ khoảng trắng cuối dòng và các dòng trống ở cuối
+func splitLines(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.ReplaceAll(s, "\r", "\n")
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// normalizeLine gộp các khoảng trắng liên tiếp trong dòng thành một dấu cách
+func normalizeLine(line string) string {
+	return strings.Join(strings.Fields(line), " ")
+}
@@ -0,0 +1,197 @@
+package comparator
+
+import (
+	"strings"
+	"testing"
+)
+
+type compareCase struct {
+	name     string
+	expected string
+	actual   string
+	want     bool
+}
+
+func runCompareCases(t *testing.T, cmp Comparator, cases []compareCase) {
+	t.Helper()
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cmp.Compare(tt.expected, tt.actual); got != tt.want {
+				t.Errorf("Compare(%q, %q) = %v, want %v", tt.expected, tt.actual, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExact(t *testing.T) {
+	runCompareCases(t, Exact{}, []compareCase{
+		{"identical", "1 2\n3", "1 2\n3", true},
+		{"surrounding whitespace", "1 2\n3", "\n 1 2\n3 \n\n", true},
+		{"trailing whitespace inside", "1 2\n3", "1 2 \n3", false},
+		{"CRLF", "1\n2", "1\r\n2", false},
+		{"different", "1 2", "1 3", false},
+	})
+}
+
+func TestIgnoreWhitespace(t *testing.T) {
+	runCompareCases(t, IgnoreWhitespace{}, []compareCase{
+		{"CRLF", "a b\nc\n", "a b\r\nc\r\n", true},
+		{"bare CR", "a\nb", "a\rb", true},
+		{"trailing whitespace", "a b\nc", "a b  \t\nc   ", true},
+		{"inner whitespace", "a b", "a    b", true},
+		{"trailing empty lines", "a\nb", "a\nb\n\n\n", true},
+		{"missing whitespace", "a b", "ab", false},
+		{"extra line", "a\nb", "a\nb\nc", false},
+		{"inner empty line", "a\nb", "a\n\nb", false},
+		{"case", "Yes", "yes", false},
+	})
+}
+
+func TestCaseInsensitive(t *testing.T) {
+	runCompareCases(t, CaseInsensitive{}, []compareCase{
+		{"case", "YES\nNo", "yes\r\nNO  ", true},
+		{"different word", "yes", "yep", false},
+	})
+}
+
+func TestNumeric(t *testing.T) {
+	tests := []struct {
+		name     string
+		cmp      Numeric
+		expected string
+		actual   string
+		want     bool
+	}{
+		{"exact tokens", Numeric{}, "1 2 abc", "1 2 abc", true},
+		{"token count", Numeric{AbsoluteEpsilon: 1}, "1 2", "1 2 3", false},
+		{"text token mismatch", Numeric{AbsoluteEpsilon: 1}, "x 1", "y 1", false},
+		{"text versus number", Numeric{AbsoluteEpsilon: 1}, "abc", "1", false},
+		{"whitespace and CRLF", Numeric{}, "1.5\n2", " 1.50\r\n2.0 ", true},
+		{"no epsilon", Numeric{}, "0.1", "0.1000001", false},
+		{"absolute within", Numeric{AbsoluteEpsilon: 1e-3}, "3.1416", "3.1421", true},
+		{"absolute boundary", Numeric{AbsoluteEpsilon: 0.5}, "1", "1.5", true},
+		{"absolute outside", Numeric{AbsoluteEpsilon: 1e-3}, "3.1416", "3.15", false},
+		{"relative within", Numeric{RelativeEpsilon: 1e-6}, "1000000", "1000000.5", true},
+		{"relative outside", Numeric{RelativeEpsilon: 1e-6}, "1000000", "1000002", false},
+		{"relative small values", Numeric{RelativeEpsilon: 1e-6}, "0.001", "0.0011", false},
+		{"relative uses larger value", Numeric{RelativeEpsilon: 0.5}, "1", "2", true},
+		{"either epsilon", Numeric{AbsoluteEpsilon: 1e-9, RelativeEpsilon: 0.01}, "100", "100.9", true},
+		{"scientific notation", Numeric{AbsoluteEpsilon: 1e-9}, "1e-3", "0.001", true},
+		{"NaN equals NaN", Numeric{}, "nan", "NaN", true},
+		{"NaN versus number", Numeric{AbsoluteEpsilon: 1e9}, "NaN", "0", false},
+		{"number versus NaN", Numeric{RelativeEpsilon: 1}, "1", "nan", false},
+		{"Inf equals Inf", Numeric{}, "inf", "+Inf", true},
+		{"Inf sign", Numeric{AbsoluteEpsilon: 1e9}, "inf", "-inf", false},
+		{"Inf versus large number", Numeric{RelativeEpsilon: 0.5}, "inf", "1e308", false},
+		{"large number versus Inf", Numeric{AbsoluteEpsilon: 1e308}, "1e308", "Infinity", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cmp.Compare(tt.expected, tt.actual); got != tt.want {
+				t.Errorf("%+v.Compare(%q, %q) = %v, want %v", tt.cmp, tt.expected, tt.actual, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegex(t *testing.T) {
+	runCompareCases(t, Regex{}, []compareCase{
+		{"per line", "\\d+\nok|done", "42\r\ndone", true},
+		{"anchored at start", `\d+`, "x42", false},
+		{"anchored at end", `\d+`, "42x", false},
+		{"alternation is anchored", `a|b`, "ab", false},
+		{"substring does not match", `ok`, "not ok", false},
+		{"trailing whitespace", `Total: \d+`, "Total: 7   ", true},
+		{"line count", `.*`, "a\nb", false},
+		{"invalid pattern never matches", `(`, "(", false},
+	})
+}
+
+func TestWildcard(t *testing.T) {
+	runCompareCases(t, Wildcard{}, []compareCase{
+		{"star", "Time: * ms", "Time: 12.5 ms", true},
+		{"star matches empty", "a*b", "ab", true},
+		{"question mark", "id=?", "id=7", true},
+		{"question mark is one character", "id=?", "id=42", false},
+		{"anchored", "abc", "xabcx", false},
+		{"dot is literal", "a.c", "abc", false},
+		{"plus is literal", "a+", "aa", false},
+		{"brackets are literal", "[x]", "x", false},
+		{"literal metacharacters match themselves", `a.c+(d)[e]{2}^$|\`, `a.c+(d)[e]{2}^$|\`, true},
+		{"CRLF", "a*\nb", "abc\r\nb", true},
+	})
+}
+
+func TestUnordered(t *testing.T) {
+	runCompareCases(t, Unordered{}, []compareCase{
+		{"reordered", "a\nb\nc", "c\na\nb", true},
+		{"duplicates kept", "a\na\nb", "a\nb\na", true},
+		{"duplicate count differs", "a\na\nb", "a\nb\nb", false},
+		{"missing duplicate", "a\na", "a", false},
+		{"empty lines ignored", "a\n\nb", "b\na\n\n", true},
+		{"inner whitespace", "x  y\nz", "z\r\nx y", true},
+		{"different line", "a\nb", "a\nc", false},
+	})
+}
+
+func TestNew(t *testing.T) {
+	modes := map[string]Comparator{
+		"":                   Exact{},
+		ModeExact:            Exact{},
+		ModeIgnoreWhitespace: IgnoreWhitespace{},
+		ModeCaseInsensitive:  CaseInsensitive{},
+		ModeNumeric:          Numeric{},
+		ModeRegex:            Regex{},
+		ModeWildcard:         Wildcard{},
+		ModeUnordered:        Unordered{},
+	}
+	for mode, want := range modes {
+		got, err := New(mode, Options{})
+		if err != nil || got != want {
+			t.Errorf("New(%q) = %#v, %v; want %#v", mode, got, err, want)
+		}
+	}
+
+	got, err := New(ModeNumeric, Options{AbsoluteEpsilon: 0.1, RelativeEpsilon: 0.2})
+	if err != nil || got != (Numeric{AbsoluteEpsilon: 0.1, RelativeEpsilon: 0.2}) {
+		t.Errorf("New(numeric) = %#v, %v", got, err)
+	}
+	if _, err := New(ModeNumeric, Options{AbsoluteEpsilon: -1}); err == nil {
+		t.Error("New accepted a negative epsilon")
+	}
+	if _, err := New("fuzzy", Options{}); err == nil {
+		t.Error("New accepted an unknown mode")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     string
+		options  Options
+		expected string
+		wantErr  string
+	}{
+		{"valid regex", ModeRegex, Options{}, "\\d+\n[a-z]*", ""},
+		{"invalid regex", ModeRegex, Options{}, "ok\n(unclosed", "line 2"},
+		{"invalid repetition", ModeRegex, Options{}, "a**", "line 1"},
+		{"parentheses are fine outside regex", ModeExact, Options{}, "(unclosed", ""},
+		{"wildcard metacharacters", ModeWildcard, Options{}, "(*)[?]", ""},
+		{"negative epsilon", ModeNumeric, Options{RelativeEpsilon: -0.1}, "1", "epsilon"},
+		{"unknown mode", "fuzzy", Options{}, "1", "unknown mode"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.mode, tt.options, tt.expected)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package comparator
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Exact so sánh toàn bộ output sau khi bỏ khoảng trắng ở đầu và cuối
+type Exact struct{}
+
+func (Exact) Compare(expected, actual string) bool {
+	return strings.TrimSpace(actual) == strings.TrimSpace(expected)
+}
+
+// IgnoreWhitespace so sánh từng dòng, bỏ qua CRLF, khoảng trắng thừa và dòng trống ở cuối
+type IgnoreWhitespace struct{}
+
+func (IgnoreWhitespace) Compare(expected, actual string) bool {
+	return compareLines(expected, actual, func(e, a string) bool {
+		return normalizeLine(e) == normalizeLine(a)
+	})
+}
+
+// CaseInsensitive giống IgnoreWhitespace nhưng không phân biệt chữ hoa chữ thường
+type CaseInsensitive struct{}
+
+func (CaseInsensitive) Compare(expected, actual string) bool {
+	return compareLines(expected, actual, func(e, a string) bool {
+		return strings.EqualFold(normalizeLine(e), normalizeLine(a))
+	})
+}
+
+// Numeric so sánh từng token, các token là số được phép lệch trong sai số cho phép.
+// Hai số bằng nhau nếu lệch không quá AbsoluteEpsilon hoặc không quá
+// RelativeEpsilon nhân với giá trị tuyệt đối lớn hơn.
+type Numeric struct {
+	AbsoluteEpsilon float64
+	RelativeEpsilon float64
+}
+
+func (n Numeric) Compare(expected, actual string) bool {
+	expectedTokens := strings.Fields(expected)
+	actualTokens := strings.Fields(actual)
+	if len(expectedTokens) != len(actualTokens) {
+		return false
+	}
+	for i := range expectedTokens {
+		if !n.equalToken(expectedTokens[i], actualTokens[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (n Numeric) equalToken(expected, actual string) bool {
+	if expected == actual {
+		return true
+	}
+	e, errE := strconv.ParseFloat(expected, 64)
+	a, errA := strconv.ParseFloat(actual, 64)
+	if errE != nil || errA != nil {
+		return false
+	}
+	if math.IsNaN(e) || math.IsNaN(a) {
+		return math.IsNaN(e) && math.IsNaN(a)
+	}
+	// Vô cực chỉ bằng vô cực cùng dấu, sai số tương đối không áp dụng được
+	if math.IsInf(e, 0) || math.IsInf(a, 0) {
+		return e == a
+	}
+	diff := math.Abs(e - a)
+	if diff <= n.AbsoluteEpsilon {
+		return true
+	}
+	return diff <= n.RelativeEpsilon*math.Max(math.Abs(e), math.Abs(a))
+}
+
+// Regex coi mỗi dòng expected là một biểu thức chính quy phải khớp toàn bộ dòng output tương ứng
+type Regex struct{}
+
+func (Regex) Compare(expected, actual string) bool {
+	return compareLines(expected, actual, func(e, a string) bool {
+		pattern, err := compileLine(e)
+		return err == nil && pattern.MatchString(a)
+	})
+}
+
+// Wildcard cho phép dòng expected chứa * (chuỗi bất kỳ) và ? (một ký tự bất kỳ)
+type Wildcard struct{}
+
+func (Wildcard) Compare(expected, actual string) bool {
+	return compareLines(expected, actual, func(e, a string) bool {
+		return wildcardPattern(e).MatchString(a)
+	})
+}
+
+// Unordered so sánh tập các dòng (có tính số lần lặp), không quan tâm thứ tự
+type Unordered struct{}
+
+func (Unordered) Compare(expected, actual string) bool {
+	expectedLines := nonEmptyNormalizedLines(expected)
+	actualLines := nonEmptyNormalizedLines(actual)
+	if len(expectedLines) != len(actualLines) {
+		return false
+	}
+	sort.Strings(expectedLines)
+	sort.Strings(actualLines)
+	for i := range expectedLines {
+		if expectedLines[i] != actualLines[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// compareLines so sánh từng cặp dòng sau khi chuẩn hóa bằng splitLines
+func compareLines(expected, actual string, equal func(e, a string) bool) bool {
+	expectedLines := splitLines(expected)
+	actualLines := splitLines(actual)
+	if len(expectedLines) != len(actualLines) {
+		return false
+	}
+	for i := range expectedLines {
+		if !equal(expectedLines[i], actualLines[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func nonEmptyNormalizedLines(s string) []string {
+	var lines []string
+	for _, line := range splitLines(s) {
+		if line = normalizeLine(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func compileLine(line string) (*regexp.Regexp, error) {
+	return regexp.Compile("^(?:" + line + ")$")
+}
+
+func wildcardPattern(line string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, r := range line {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
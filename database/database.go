@@ -40,9 +40,56 @@ func ConnectDb() {
 	db.Logger = logger.Default.LogMode(logger.Info)
 
 	log.Println("AutoMigrate")
-	db.AutoMigrate(&models.User{}, &models.Post{}, &models.Comment{}, &models.Testcase{}, &models.StudentRunTestcase{}, &models.Interaction{}, &models.PostHasTag{}, &models.Tag{}, &models.TeacherVerifyPost{}, &models.PostInteraction{})
+	if err := migrateTestcaseIDs(db); err != nil {
+		log.Fatal("Failed to migrate testcases. \n", err)
+	}
+	db.AutoMigrate(&models.User{}, &models.Assignment{}, &models.Post{}, &models.Comment{}, &models.Testcase{}, &models.StudentRunTestcase{}, &models.Interaction{}, &models.PostHasTag{}, &models.Tag{}, &models.TeacherVerifyPost{}, &models.PostInteraction{}, &models.Run{}, &models.CourseLimit{}, &models.StudentFile{}, &models.StudentUpload{}, &models.StudentUploadFile{}, &models.JobeFile{}, &models.HarnessVersion{}, &models.HarnessFile{}, &models.RunCacheEntry{}, &models.ReferenceSolution{}, &models.ReferenceFile{}, &models.UploadFingerprint{}, &models.StabilityCheck{})
+	if err := backfillVerdicts(db); err != nil {
+		log.Println("Failed to backfill verdicts: ", err)
+	}
 
 	DB = Dbinstance{
 		Db: db,
 	}
 }
+
+// migrateTestcaseIDs chuyển khóa chính của bảng testcases từ post_id sang id
+// để một post có nhiều testcase. Testcase cũ giữ id bằng post_id nên file input
+// đã upload lên Jobe theo post_id vẫn dùng được.
+func migrateTestcaseIDs(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable("testcases") || migrator.HasColumn("testcases", "id") {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		statements := []string{
+			`ALTER TABLE testcases ADD COLUMN id uuid`,
+			`UPDATE testcases SET id = post_id`,
+			`ALTER TABLE testcases ALTER COLUMN id SET NOT NULL`,
+			`ALTER TABLE testcases DROP CONSTRAINT IF EXISTS testcases_pkey`,
+			`ALTER TABLE testcases ADD PRIMARY KEY (id)`,
+		}
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// backfillVerdicts suy ra verdict cho các lần chạy cũ từ tiền tố của log
+func backfillVerdicts(db *gorm.DB) error {
+	return db.Exec(`UPDATE student_run_testcases SET verdict = CASE
+		WHEN log LIKE 'Compilation error: %' OR log = 'Execution failed: 11' THEN 'CE'
+		WHEN log LIKE 'Runtime error: %' OR log = 'Execution failed: 12' THEN 'RE'
+		WHEN log = 'Execution failed: 13' THEN 'TLE'
+		WHEN log = 'Execution failed: 17' THEN 'MLE'
+		WHEN log = 'Execution failed: 19' THEN 'ILLEGAL'
+		WHEN log = 'Execution failed: 21' THEN 'OVERLOAD'
+		WHEN log LIKE 'Execution failed: %' THEN 'SANDBOX_ERROR'
+		WHEN score = 1 THEN 'AC'
+		ELSE 'WA' END
+		WHERE verdict IS NULL OR verdict = ''`).Error
+}
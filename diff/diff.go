@@ -0,0 +1,205 @@
+// Package diff tính khác biệt theo dòng giữa hai văn bản, dùng cho output của testcase và các phiên bản code.
+package diff
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tison2810/be-go-tc/models"
+)
+
+const (
+	ContextLines     = 3       // Số dòng ngữ cảnh quanh mỗi hunk
+	MaxUnifiedLines  = 200     // Unified diff dài hơn sẽ bị cắt bớt
+	maxLCSCells      = 4000000 // Giới hạn bảng LCS để không tốn quá nhiều bộ nhớ với output lớn
+	truncatedMessage = "... diff truncated"
+)
+
+// op là một dòng trong kịch bản chỉnh sửa: ' ' giữ nguyên, '-' chỉ có trong expected, '+' chỉ có trong actual.
+// expected/actual là vị trí (bắt đầu từ 0) của dòng trong mỗi bên tại thời điểm đó.
+type op struct {
+	kind     byte
+	text     string
+	expected int
+	actual   int
+}
+
+// Compute trả về diff giữa expected và actual, nil nếu hai bên giống nhau
+func Compute(expected, actual string) *models.OutputDiff {
+	expectedLines := splitLines(expected)
+	actualLines := splitLines(actual)
+
+	line, column, ok := firstDifference(expectedLines, actualLines)
+	if !ok {
+		return nil
+	}
+
+	result := &models.OutputDiff{
+		Unified:     unified(editScript(expectedLines, actualLines), "expected", "actual"),
+		FirstLine:   line,
+		FirstColumn: column,
+	}
+	if line <= len(expectedLines) {
+		result.ExpectedLine = expectedLines[line-1]
+	}
+	if line <= len(actualLines) {
+		result.ActualLine = actualLines[line-1]
+	}
+	return result
+}
+
+// Unified trả về unified diff giữa hai văn bản với nhãn cho trước, chuỗi rỗng nếu giống nhau
+func Unified(from, to, fromLabel, toLabel string) string {
+	return unified(editScript(splitLines(from), splitLines(to)), fromLabel, toLabel)
+}
+
+func splitLines(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// firstDifference trả về dòng và cột (bắt đầu từ 1) đầu tiên mà hai bên khác nhau
+func firstDifference(expected, actual []string) (int, int, bool) {
+	for i := 0; i < len(expected) || i < len(actual); i++ {
+		if i >= len(expected) || i >= len(actual) {
+			return i + 1, 1, true
+		}
+		if expected[i] == actual[i] {
+			continue
+		}
+		e, a := []rune(expected[i]), []rune(actual[i])
+		column := 0
+		for column < len(e) && column < len(a) && e[column] == a[column] {
+			column++
+		}
+		return i + 1, column + 1, true
+	}
+	return 0, 0, false
+}
+
+// editScript tính kịch bản chỉnh sửa bằng LCS sau khi bỏ phần đầu và cuối giống nhau
+func editScript(expected, actual []string) []op {
+	prefix := 0
+	for prefix < len(expected) && prefix < len(actual) && expected[prefix] == actual[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(expected)-prefix && suffix < len(actual)-prefix &&
+		expected[len(expected)-1-suffix] == actual[len(actual)-1-suffix] {
+		suffix++
+	}
+
+	ops := make([]op, 0, len(expected)+len(actual))
+	for i := 0; i < prefix; i++ {
+		ops = append(ops, op{kind: ' ', text: expected[i], expected: i, actual: i})
+	}
+	ops = append(ops, middle(expected[prefix:len(expected)-suffix], actual[prefix:len(actual)-suffix], prefix)...)
+	for i := suffix; i > 0; i-- {
+		e, a := len(expected)-i, len(actual)-i
+		ops = append(ops, op{kind: ' ', text: expected[e], expected: e, actual: a})
+	}
+	return ops
+}
+
+func middle(expected, actual []string, offset int) []op {
+	n, m := len(expected), len(actual)
+	var ops []op
+
+	if n*m > maxLCSCells {
+		// Output quá lớn, coi toàn bộ phần giữa là bị thay thế
+		for i, line := range expected {
+			ops = append(ops, op{kind: '-', text: line, expected: offset + i, actual: offset})
+		}
+		for j, line := range actual {
+			ops = append(ops, op{kind: '+', text: line, expected: offset + n, actual: offset + j})
+		}
+		return ops
+	}
+
+	lcs := make([][]int32, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if expected[i] == actual[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && expected[i] == actual[j]:
+			ops = append(ops, op{kind: ' ', text: expected[i], expected: offset + i, actual: offset + j})
+			i++
+			j++
+		case j >= m || (i < n && lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, op{kind: '-', text: expected[i], expected: offset + i, actual: offset + j})
+			i++
+		default:
+			ops = append(ops, op{kind: '+', text: actual[j], expected: offset + i, actual: offset + j})
+			j++
+		}
+	}
+	return ops
+}
+
+// unified ghép kịch bản chỉnh sửa thành unified diff với ContextLines dòng ngữ cảnh
+func unified(ops []op, fromLabel, toLabel string) string {
+	var changes []int
+	for i, o := range ops {
+		if o.kind != ' ' {
+			changes = append(changes, i)
+		}
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+
+	lines := []string{"--- " + fromLabel, "+++ " + toLabel}
+	for k := 0; k < len(changes); {
+		start := max(changes[k]-ContextLines, 0)
+		last := changes[k]
+		for k++; k < len(changes) && changes[k]-last <= 2*ContextLines; k++ {
+			last = changes[k]
+		}
+		end := min(last+ContextLines+1, len(ops))
+
+		expectedCount, actualCount := 0, 0
+		for _, o := range ops[start:end] {
+			if o.kind != '+' {
+				expectedCount++
+			}
+			if o.kind != '-' {
+				actualCount++
+			}
+		}
+		lines = append(lines, fmt.Sprintf("@@ -%s +%s @@",
+			hunkRange(ops[start].expected, expectedCount), hunkRange(ops[start].actual, actualCount)))
+		for _, o := range ops[start:end] {
+			lines = append(lines, string(o.kind)+o.text)
+		}
+	}
+
+	if len(lines) > MaxUnifiedLines {
+		lines = append(lines[:MaxUnifiedLines], truncatedMessage)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// hunkRange định dạng vị trí hunk theo quy ước của diff: hunk rỗng dùng dòng đứng trước nó
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
@@ -0,0 +1,108 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestComputeFirstDifference(t *testing.T) {
+	tests := []struct {
+		name         string
+		expected     string
+		actual       string
+		line, column int
+		expectedLine string
+		actualLine   string
+	}{
+		{"changed character", "1 2 3\n4 5 6", "1 2 3\n4 7 6", 2, 3, "4 5 6", "4 7 6"},
+		{"first line", "abc", "abd", 1, 3, "abc", "abd"},
+		{"longer actual line", "ab", "abc", 1, 3, "ab", "abc"},
+		{"missing line", "a\nb\nc", "a\nb", 3, 1, "c", ""},
+		{"extra line", "a", "a\nb", 2, 1, "", "b"},
+		{"unicode column", "Tổng: 5", "Tổng: 6", 1, 7, "Tổng: 5", "Tổng: 6"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Compute(tt.expected, tt.actual)
+			if got == nil {
+				t.Fatal("Compute returned nil for different outputs")
+			}
+			if got.FirstLine != tt.line || got.FirstColumn != tt.column {
+				t.Errorf("first difference = %d:%d, want %d:%d", got.FirstLine, got.FirstColumn, tt.line, tt.column)
+			}
+			if got.ExpectedLine != tt.expectedLine || got.ActualLine != tt.actualLine {
+				t.Errorf("lines = %q / %q, want %q / %q", got.ExpectedLine, got.ActualLine, tt.expectedLine, tt.actualLine)
+			}
+		})
+	}
+}
+
+func TestComputeEqual(t *testing.T) {
+	for _, pair := range [][2]string{{"", ""}, {"a\nb", "a\nb"}, {"a\nb\n", "a\r\nb"}} {
+		if got := Compute(pair[0], pair[1]); got != nil {
+			t.Errorf("Compute(%q, %q) = %+v, want nil", pair[0], pair[1], got)
+		}
+	}
+}
+
+func TestUnified(t *testing.T) {
+	got := Unified("a\nb\nc\nd", "a\nB\nc\nd\ne", "expected", "actual")
+	want := strings.Join([]string{
+		"--- expected",
+		"+++ actual",
+		"@@ -1,4 +1,5 @@",
+		" a",
+		"-b",
+		"+B",
+		" c",
+		" d",
+		"+e",
+	}, "\n")
+	if got != want {
+		t.Errorf("Unified =\n%s\nwant\n%s", got, want)
+	}
+
+	if got := Unified("same\n", "same", "a", "b"); got != "" {
+		t.Errorf("Unified of equal texts = %q, want empty", got)
+	}
+}
+
+func TestUnifiedSeparateHunks(t *testing.T) {
+	var from, to []string
+	for i := 1; i <= 20; i++ {
+		from = append(from, fmt.Sprint(i))
+		to = append(to, fmt.Sprint(i))
+	}
+	to[1], to[17] = "two", "eighteen"
+
+	got := Unified(strings.Join(from, "\n"), strings.Join(to, "\n"), "e", "a")
+	hunks := strings.Count(got, "\n@@ ")
+	if hunks != 2 {
+		t.Fatalf("got %d hunks, want 2:\n%s", hunks, got)
+	}
+	for _, header := range []string{"@@ -1,5 +1,5 @@", "@@ -15,6 +15,6 @@"} {
+		if !strings.Contains(got, header) {
+			t.Errorf("missing hunk header %q in:\n%s", header, got)
+		}
+	}
+}
+
+func TestUnifiedEmptySide(t *testing.T) {
+	got := Unified("", "x\ny", "expected", "actual")
+	if !strings.Contains(got, "@@ -0,0 +1,2 @@") {
+		t.Errorf("diff against empty text has wrong hunk header:\n%s", got)
+	}
+}
+
+func TestUnifiedTruncated(t *testing.T) {
+	var from, to []string
+	for i := 0; i < MaxUnifiedLines; i++ {
+		from = append(from, fmt.Sprint("e", i))
+		to = append(to, fmt.Sprint("a", i))
+	}
+	got := strings.Split(Unified(strings.Join(from, "\n"), strings.Join(to, "\n"), "e", "a"), "\n")
+	if len(got) != MaxUnifiedLines+1 || got[len(got)-1] != truncatedMessage {
+		t.Errorf("truncated diff has %d lines ending with %q", len(got), got[len(got)-1])
+	}
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/tison2810/be-go-tc/database"
+	"github.com/tison2810/be-go-tc/models"
+	"github.com/tison2810/be-go-tc/services"
+	"gorm.io/gorm"
+)
+
+// requireTeacher kiểm tra user hiện tại là giảng viên, nếu không thì ghi response lỗi và trả về false
+func requireTeacher(c *fiber.Ctx, action string) (string, bool) {
+	email, ok := c.Locals("email").(string)
+	if !ok || email == "" {
+		c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "User email not found in context",
+		})
+		return "", false
+	}
+
+	role, ok := c.Locals("role").(string)
+	if !ok || role != "teacher" {
+		c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Only teachers can " + action,
+		})
+		return "", false
+	}
+	return email, true
+}
+
+// assignmentParam lấy assignment theo param id, tự trả về 404 nếu không tìm thấy
+func assignmentParam(c *fiber.Ctx) (models.Assignment, bool) {
+	assignment, err := services.GetAssignmentByParam(c.Params("id"))
+	if err != nil {
+		c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Assignment not found",
+		})
+		return models.Assignment{}, false
+	}
+	return assignment, true
+}
+
+// GetAssignments trả về danh sách assignment
+func GetAssignments(c *fiber.Ctx) error {
+	assignments, err := services.ListAssignments()
+	if err != nil {
+		log.Printf("Failed to fetch assignments: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch assignments",
+		})
+	}
+	return c.Status(fiber.StatusOK).JSON(assignments)
+}
+
+// GetAssignment trả về cấu hình của một assignment
+func GetAssignment(c *fiber.Ctx) error {
+	assignmentID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid assignment ID",
+		})
+	}
+
+	assignment, err := services.GetAssignment(assignmentID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Assignment not found",
+			})
+		}
+		log.Printf("Failed to fetch assignment: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch assignment",
+		})
+	}
+	return c.Status(fiber.StatusOK).JSON(assignment)
+}
+
+// CreateAssignment cho phép giảng viên tạo assignment mới
+func CreateAssignment(c *fiber.Ctx) error {
+	email, ok := requireTeacher(c, "manage assignments")
+	if !ok {
+		return nil
+	}
+
+	assignment := new(models.Assignment)
+	if err := c.BodyParser(assignment); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Failed to parse JSON: " + err.Error(),
+		})
+	}
+	if err := services.ValidateAssignment(assignment); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	assignment.ID = uuid.New()
+	assignment.CreatedBy = email
+	if err := database.DB.Db.Create(assignment).Error; err != nil {
+		log.Printf("Failed to create assignment: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create assignment",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(assignment)
+}
+
+// UpdateAssignment cho phép giảng viên sửa cấu hình assignment
+func UpdateAssignment(c *fiber.Ctx) error {
+	if _, ok := requireTeacher(c, "manage assignments"); !ok {
+		return nil
+	}
+
+	assignmentID, err := uuid.Parse(c.Params("id"))
+	if err != nil || assignmentID == uuid.Nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid assignment ID",
+		})
+	}
+
+	var existing models.Assignment
+	if err := database.DB.Db.First(&existing, "id = ?", assignmentID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Assignment not found",
+		})
+	}
+
+	assignment := new(models.Assignment)
+	if err := c.BodyParser(assignment); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Failed to parse JSON: " + err.Error(),
+		})
+	}
+	if err := services.ValidateAssignment(assignment); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	assignment.ID = existing.ID
+	assignment.CreatedBy = existing.CreatedBy
+	assignment.CreatedAt = existing.CreatedAt
+	if err := database.DB.Db.Save(assignment).Error; err != nil {
+		log.Printf("Failed to update assignment: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update assignment",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(assignment)
+}
+
+// DeleteAssignment xóa assignment chưa có post nào tham chiếu
+func DeleteAssignment(c *fiber.Ctx) error {
+	if _, ok := requireTeacher(c, "manage assignments"); !ok {
+		return nil
+	}
+
+	assignmentID, err := uuid.Parse(c.Params("id"))
+	if err != nil || assignmentID == uuid.Nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid assignment ID",
+		})
+	}
+
+	inUse, err := services.IsAssignmentInUse(assignmentID)
+	if err != nil {
+		log.Printf("Failed to check assignment usage: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete assignment",
+		})
+	}
+	if inUse {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Assignment is referenced by posts",
+		})
+	}
+
+	result := database.DB.Db.Delete(&models.Assignment{}, "id = ?", assignmentID)
+	if result.Error != nil {
+		log.Printf("Failed to delete assignment: %v", result.Error)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete assignment",
+		})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Assignment not found",
+		})
+	}
+
+	return c.Status(fiber.StatusNoContent).Send(nil)
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/tison2810/be-go-tc/database"
+	"github.com/tison2810/be-go-tc/models"
+	"github.com/tison2810/be-go-tc/services"
+)
+
+// GetSuspectedExpected liệt kê các testcase của post mà đa số sinh viên cùng ra một output khác expected,
+// chỉ tác giả hoặc giáo viên được xem
+func GetSuspectedExpected(c *fiber.Ctx) error {
+	email, ok := c.Locals("email").(string)
+	if !ok || email == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "User email not found in context",
+		})
+	}
+	role, _ := c.Locals("role").(string)
+
+	postID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid post ID",
+		})
+	}
+	var post models.Post
+	if err := database.DB.Db.Preload("Testcases", services.OrderTestcases).First(&post, "id = ?", postID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Post not found",
+		})
+	}
+	if post.UserMail != email && role != "teacher" {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Only the author or a teacher can view this report",
+		})
+	}
+
+	suspects, err := services.SuspectedWrongExpected(post)
+	if err != nil {
+		log.Printf("Failed to build suspected expected report: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to build suspected expected report",
+		})
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"min_students": services.MinConsensusStudents,
+		"min_ratio":    services.ConsensusRatio,
+		"testcases":    suspects,
+	})
+}
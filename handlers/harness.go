@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/tison2810/be-go-tc/services"
+	"gorm.io/gorm"
+)
+
+// harnessAssignmentID lấy assignment từ param id, uuid.Nil là assignment mặc định
+func harnessAssignmentID(c *fiber.Ctx) (uuid.UUID, bool) {
+	assignment, ok := assignmentParam(c)
+	return assignment.ID, ok
+}
+
+// GetHarnessVersions trả về các phiên bản harness của assignment
+func GetHarnessVersions(c *fiber.Ctx) error {
+	if _, ok := requireTeacher(c, "manage harness files"); !ok {
+		return nil
+	}
+	assignmentID, ok := harnessAssignmentID(c)
+	if !ok {
+		return nil
+	}
+
+	versions, err := services.ListHarnessVersions(assignmentID)
+	if err != nil {
+		log.Printf("Failed to fetch harness versions: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch harness versions",
+		})
+	}
+	return c.Status(fiber.StatusOK).JSON(versions)
+}
+
+// CreateHarnessVersion tạo phiên bản harness mới từ các file trong key files của form-data.
+// Gửi activate=true để dùng phiên bản này ngay.
+func CreateHarnessVersion(c *fiber.Ctx) error {
+	email, ok := requireTeacher(c, "manage harness files")
+	if !ok {
+		return nil
+	}
+	assignmentID, ok := harnessAssignmentID(c)
+	if !ok {
+		return nil
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Failed to parse form: " + err.Error(),
+		})
+	}
+	var uploads []services.HarnessUpload
+	for _, fileHeader := range form.File["files"] {
+		file, err := fileHeader.Open()
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Failed to open file " + fileHeader.Filename,
+			})
+		}
+		content, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Failed to read file " + fileHeader.Filename,
+			})
+		}
+		uploads = append(uploads, services.HarnessUpload{FileName: fileHeader.Filename, Content: content})
+	}
+
+	harness, err := services.CreateHarnessVersion(c.UserContext(), assignmentID, email, c.FormValue("note"), uploads)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if c.FormValue("activate") == "true" {
+		if harness, err = services.ActivateHarnessVersion(c.UserContext(), assignmentID, harness.Version); err != nil {
+			log.Printf("Failed to activate harness: %v", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Harness was created but could not be activated",
+			})
+		}
+	}
+	return c.Status(fiber.StatusCreated).JSON(harness)
+}
+
+// ActivateHarnessVersion đặt phiên bản harness làm bản đang dùng.
+// Gửi query regrade=true để chạy lại bài của sinh viên với harness mới.
+func ActivateHarnessVersion(c *fiber.Ctx) error {
+	if _, ok := requireTeacher(c, "manage harness files"); !ok {
+		return nil
+	}
+	assignmentID, ok := harnessAssignmentID(c)
+	if !ok {
+		return nil
+	}
+	version, err := strconv.Atoi(c.Params("version"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid version",
+		})
+	}
+
+	harness, err := services.ActivateHarnessVersion(c.UserContext(), assignmentID, version)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Harness version not found",
+			})
+		}
+		log.Printf("Failed to activate harness: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to activate harness",
+		})
+	}
+
+	response := fiber.Map{"harness": harness}
+	if c.Query("regrade") == "true" {
+		queued, err := services.RegradeAssignment(assignmentID)
+		if err != nil {
+			log.Printf("Failed to regrade assignment: %v", err)
+		}
+		response["regrade_queued"] = queued
+	}
+	return c.Status(fiber.StatusOK).JSON(response)
+}
+
+// RegradeAssignment chạy lại bài của sinh viên với harness đang dùng của assignment
+func RegradeAssignment(c *fiber.Ctx) error {
+	if _, ok := requireTeacher(c, "regrade assignments"); !ok {
+		return nil
+	}
+	assignmentID, ok := harnessAssignmentID(c)
+	if !ok {
+		return nil
+	}
+
+	queued, err := services.RegradeAssignment(assignmentID)
+	if err != nil {
+		log.Printf("Failed to regrade assignment: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":  "Failed to regrade assignment",
+			"queued": queued,
+		})
+	}
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"queued": queued,
+	})
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/tison2810/be-go-tc/models"
+	"github.com/tison2810/be-go-tc/services"
+)
+
+// runHistoryFilter đọc page, page_size và verdict từ query
+func runHistoryFilter(c *fiber.Ctx) (services.RunHistoryFilter, error) {
+	filter := services.RunHistoryFilter{
+		Page:     c.QueryInt("page", 1),
+		PageSize: c.QueryInt("page_size", services.DefaultRunHistoryPageSize),
+	}
+	if verdict := models.Verdict(strings.ToUpper(c.Query("verdict"))); verdict != "" {
+		if !verdict.IsValid() {
+			return filter, errors.New("invalid verdict")
+		}
+		filter.Verdict = verdict
+	}
+	return filter, nil
+}
+
+// GetUserRuns trả về lịch sử chạy testcase của user hiện tại, có thể lọc theo post_id và verdict
+func GetUserRuns(c *fiber.Ctx) error {
+	email, ok := c.Locals("email").(string)
+	if !ok || email == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "User email not found in context",
+		})
+	}
+
+	filter, err := runHistoryFilter(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if postIDStr := c.Query("post_id"); postIDStr != "" {
+		postID, err := uuid.Parse(postIDStr)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid post_id",
+			})
+		}
+		filter.PostID = &postID
+	}
+
+	return runHistoryResponse(c, email, filter)
+}
+
+// GetPostRuns trả về các lần chạy của user hiện tại trên một post
+func GetPostRuns(c *fiber.Ctx) error {
+	email, ok := c.Locals("email").(string)
+	if !ok || email == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "User email not found in context",
+		})
+	}
+
+	postID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid post ID",
+		})
+	}
+	filter, err := runHistoryFilter(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	filter.PostID = &postID
+
+	return runHistoryResponse(c, email, filter)
+}
+
+func runHistoryResponse(c *fiber.Ctx, email string, filter services.RunHistoryFilter) error {
+	page, err := services.ListStudentRuns(email, filter)
+	if err != nil {
+		log.Printf("Failed to fetch run history: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch run history",
+		})
+	}
+	return c.Status(fiber.StatusOK).JSON(page)
+}
+
+// GetBestResults trả về lần nộp tốt nhất của user hiện tại trên từng post đã chạy
+func GetBestResults(c *fiber.Ctx) error {
+	email, ok := c.Locals("email").(string)
+	if !ok || email == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "User email not found in context",
+		})
+	}
+
+	var postIDs []uuid.UUID
+	if postIDStr := c.Query("post_id"); postIDStr != "" {
+		postID, err := uuid.Parse(postIDStr)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid post_id",
+			})
+		}
+		postIDs = []uuid.UUID{postID}
+	}
+
+	results, err := services.GetBestResults(email, postIDs)
+	if err != nil {
+		log.Printf("Failed to fetch best results: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch best results",
+		})
+	}
+	return c.Status(fiber.StatusOK).JSON(results)
+}
+
+// GetPostBestResult trả về lần nộp tốt nhất của user hiện tại trên một post
+func GetPostBestResult(c *fiber.Ctx) error {
+	email, ok := c.Locals("email").(string)
+	if !ok || email == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "User email not found in context",
+		})
+	}
+
+	postID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid post ID",
+		})
+	}
+
+	results, err := services.GetBestResults(email, []uuid.UUID{postID})
+	if err != nil {
+		log.Printf("Failed to fetch best result: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch best result",
+		})
+	}
+	if len(results) == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "No runs on this post yet",
+		})
+	}
+	return c.Status(fiber.StatusOK).JSON(results[0])
+}
@@ -1,31 +1,48 @@
 package handlers
 
 import (
-	"bytes"
-	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"mime/multipart"
 	"net/http"
-	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
+	"github.com/tison2810/be-go-tc/jobe"
 	"github.com/tison2810/be-go-tc/models"
 	"github.com/tison2810/be-go-tc/services"
+	"gorm.io/gorm"
 )
 
+var jobePool *jobe.Pool
+
+func init() {
+	jobePool = services.JobePool()
+}
+
+// GetJobeNodes trả về trạng thái các Jobe server trong Pool cho giáo viên
+func GetJobeNodes(c *fiber.Ctx) error {
+	if _, ok := requireTeacher(c, "view Jobe nodes"); !ok {
+		return nil
+	}
+	return c.Status(fiber.StatusOK).JSON(jobePool.Nodes())
+}
+
 func CheckJobeLanguages(c *fiber.Ctx) error {
-	url := "http://jobe:80/jobe/index.php/restapi/languages"
-	resp, err := http.Get(url)
+	languages, err := jobePool.Languages(c.UserContext())
 	if err != nil {
 		log.Println("Error connecting to Jobe Server:", err)
 		return c.Status(fiber.StatusInternalServerError).SendString("Cannot connect to Jobe Server")
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	pairs := make([][]string, 0, len(languages))
+	for _, language := range languages {
+		pairs = append(pairs, []string{language.Name, language.Version})
+	}
+	body, err := json.Marshal(pairs)
 	if err != nil {
 		log.Println("Error reading response:", err)
 		return c.Status(fiber.StatusInternalServerError).SendString("Error reading Jobe response")
@@ -34,257 +51,188 @@ func CheckJobeLanguages(c *fiber.Ctx) error {
 	return c.SendString(fmt.Sprintf("Supported Languages: %s", body))
 }
 
-// func generateFileID() string {
-// 	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-// 	const length = 8
-// 	var seededRand = rand.New(rand.NewSource(time.Now().UnixNano()))
-
-// 	b := make([]byte, length)
-// 	for i := range b {
-// 		b[i] = charset[seededRand.Intn(len(charset))]
-// 	}
-// 	return string(b)
-// }
-
-func UploadSingleFileToJobeHandler(c *fiber.Ctx) error {
-	fileID := c.Params("id")
-	file, err := c.FormFile("file")
+// readFormFile đọc toàn bộ nội dung của file trong form-data theo key
+func readFormFile(c *fiber.Ctx, formFileKey string) ([]byte, error) {
+	file, err := c.FormFile(formFileKey)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(models.FileUploadResponse{
-			Success: false,
-			Error:   err.Error(),
-		})
+		return nil, err
 	}
+	return readFileHeader(file)
+}
 
+func readFileHeader(file *multipart.FileHeader) ([]byte, error) {
 	src, err := file.Open()
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(models.FileUploadResponse{
-			Success: false,
-			Error:   "Không thể mở file",
-		})
+		return nil, err
 	}
 	defer src.Close()
+	return io.ReadAll(src)
+}
 
-	fileContents, err := io.ReadAll(src)
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(models.FileUploadResponse{
-			Success: false,
-			Error:   fmt.Sprintf("Không thể đọc nội dung file: %v", err),
-		})
-	}
-
-	// fileID := generateFileID()
-	// fileID := "systemmainh"
-
-	base64Contents := base64.StdEncoding.EncodeToString(fileContents)
-
-	requestData := models.UploadFileRequest{
-		FileContents: base64Contents,
-	}
-	jsonData, err := json.Marshal(requestData)
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(models.FileUploadResponse{
-			Success: false,
-			Error:   fmt.Sprintf("Lỗi khi marshal JSON: %v", err),
-		})
-	}
-	jobeServerURL := "http://jobe:80/jobe/index.php/restapi"
-	url := fmt.Sprintf("%s/files/%s", jobeServerURL, fileID)
-	log.Printf("Sending request to Jobe: %s", url) // Log URL
-
-	req, err := http.NewRequest(http.MethodPut, url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(models.FileUploadResponse{
-			Success: false,
-			Error:   fmt.Sprintf("Lỗi khi tạo request: %v", err),
-		})
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "application/json")
+// runErrorResponse chuyển lỗi khi gửi run tới Jobe thành SubmitRunResponse
+func runErrorResponse(c *fiber.Ctx, err error) error {
+	response := runError(err)
+	return c.Status(response.Status).JSON(response)
+}
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(models.FileUploadResponse{
-			Success: false,
-			Error:   fmt.Sprintf("Lỗi khi gửi request tới Jobe: %v", err),
-		})
+// runError ánh xạ lỗi của Jobe sang status và thông báo lỗi
+func runError(err error) models.SubmitRunResponse {
+	log.Printf("Jobe run failed: %v", err)
+	var statusErr *jobe.StatusError
+	switch {
+	case errors.Is(err, jobe.ErrQueued): // 202: Job queued
+		return models.SubmitRunResponse{
+			Status: http.StatusAccepted,
+			Result: "Job queued for later execution",
+		}
+	case errors.Is(err, jobe.ErrBadRequest): // 400: Bad request
+		return models.SubmitRunResponse{
+			Status: http.StatusBadRequest,
+			Error:  "Bad request - invalid run_spec or missing parameters",
+		}
+	case errors.Is(err, jobe.ErrNotFound): // 404: Not found
+		return models.SubmitRunResponse{
+			Status: http.StatusNotFound,
+			Error:  "Missing file",
+		}
+	case errors.Is(err, jobe.ErrInvalidResponse):
+		return models.SubmitRunResponse{
+			Status: http.StatusInternalServerError,
+			Error:  fmt.Sprintf("Error parsing Jobe response: %v", err),
+		}
+	case errors.As(err, &statusErr):
+		return models.SubmitRunResponse{
+			Status: http.StatusInternalServerError,
+			Error:  fmt.Sprintf("Unexpected response code from Jobe: %d", statusErr.StatusCode),
+		}
+	default:
+		return models.SubmitRunResponse{
+			Status: http.StatusInternalServerError,
+			Error:  fmt.Sprintf("Error sending request to Jobe: %v", err),
+		}
 	}
-	defer resp.Body.Close()
-
-	log.Printf("Jobe response status: %d", resp.StatusCode)
+}
 
-	switch resp.StatusCode {
-	case http.StatusNoContent: // 204
-		return c.JSON(models.FileUploadResponse{
-			Success: true,
-			FileID:  fileID,
-			Message: "File uploaded successfully to Jobe",
-		})
-	case http.StatusBadRequest: // 400
-		return c.Status(fiber.StatusBadRequest).JSON(models.FileUploadResponse{
-			Success: false,
-			Error:   "Bad request - invalid file contents or missing parameters",
-		})
-	case http.StatusNotFound: // 404
-		return c.Status(fiber.StatusNotFound).JSON(models.FileUploadResponse{
-			Success: false,
-			Error:   "Jobe server endpoint not found",
-		})
-	case http.StatusInternalServerError: // 500
-		return c.Status(fiber.StatusInternalServerError).JSON(models.FileUploadResponse{
-			Success: false,
-			Error:   "Jobe server failed to write file to cache",
-		})
-	default:
-		return c.Status(fiber.StatusInternalServerError).JSON(models.FileUploadResponse{
+// UploadSingleFileToJobeHandler upload file vào phạm vi user, testcase hoặc system.
+// File ID trên Jobe do server sinh ra và được trả về trong file_id.
+func UploadSingleFileToJobeHandler(c *fiber.Ctx) error {
+	access, ok := fileAccess(c)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(models.FileUploadResponse{
 			Success: false,
-			Error:   fmt.Sprintf("Unexpected response code from Jobe: %d", resp.StatusCode),
+			Error:   "User email not found in context",
 		})
 	}
-}
 
-func UploadFileToJobeHandler(c *fiber.Ctx) error {
-	// Lấy key của file từ context hoặc mặc định là "file"
-	formFileKey := c.Locals("form_file_key")
-	if formFileKey == nil {
-		formFileKey = "file"
+	var testcaseID *uuid.UUID
+	if value := c.Query("testcase_id"); value != "" {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.FileUploadResponse{
+				Success: false,
+				Error:   "Invalid testcase_id",
+			})
+		}
+		testcaseID = &id
 	}
 
-	// Lấy file từ form-data
-	file, err := c.FormFile(formFileKey.(string))
+	fileContents, err := readFormFile(c, "file")
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(models.FileUploadResponse{
 			Success: false,
-			Error:   fmt.Sprintf("Không thể đọc file từ key '%s'", formFileKey),
+			Error:   err.Error(),
 		})
 	}
 
-	// Đọc nội dung file
-	src, err := file.Open()
+	file, err := services.PutScopedFile(c.UserContext(), access, c.Params("scope"), testcaseID, c.Params("name"), fileContents)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(models.FileUploadResponse{
-			Success: false,
-			Error:   "Không thể mở file",
-		})
+		return scopedFileErrorResponse(c, err)
 	}
-	defer src.Close()
 
-	fileContents, err := io.ReadAll(src)
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(models.FileUploadResponse{
-			Success: false,
-			Error:   fmt.Sprintf("Không thể đọc nội dung file: %v", err),
-		})
-	}
-
-	// Lấy fileID từ context, nếu không có thì tạo mới
-	fileID := c.Locals("file_id")
-	// if fileID == nil {
-	// 	fileID = generateFileID() // Tạo ID mặc định nếu không được cung cấp
-	// }
-	// fileID := "z1234567"
-
-	base64Contents := base64.StdEncoding.EncodeToString(fileContents)
+	return c.JSON(models.FileUploadResponse{
+		Success: true,
+		FileID:  file.ID,
+		Message: "File uploaded successfully to Jobe",
+	})
+}
 
-	requestData := models.UploadFileRequest{
-		FileContents: base64Contents,
-	}
-	jsonData, err := json.Marshal(requestData)
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(models.FileUploadResponse{
-			Success: false,
-			Error:   fmt.Sprintf("Lỗi khi marshal JSON: %v", err),
-		})
+func CheckFile(c *fiber.Ctx) error {
+	access, ok := fileAccess(c)
+	if !ok {
+		return c.Status(http.StatusUnauthorized).SendString("User email not found in context")
 	}
-	jobeServerURL := "http://jobe:80/jobe/index.php/restapi"
-	url := fmt.Sprintf("%s/files/%s", jobeServerURL, fileID)
-	log.Printf("Sending request to Jobe: %s", url) // Log URL
 
-	req, err := http.NewRequest(http.MethodPut, url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(models.FileUploadResponse{
-			Success: false,
-			Error:   fmt.Sprintf("Lỗi khi tạo request: %v", err),
-		})
+	fileID := c.Params("id")
+	if _, err := services.GetScopedFile(access, fileID); err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return c.Status(http.StatusNotFound).SendString("File not found")
+		case errors.Is(err, services.ErrFileForbidden):
+			return c.Status(http.StatusForbidden).SendString("You are not authorized to access this file")
+		default:
+			log.Println("Failed to fetch file:", err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Failed to fetch file")
+		}
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "application/json")
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
+	exists, err := jobePool.HeadFile(c.UserContext(), fileID)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(models.FileUploadResponse{
-			Success: false,
-			Error:   fmt.Sprintf("Lỗi khi gửi request tới Jobe: %v", err),
-		})
+		var statusErr *jobe.StatusError
+		switch {
+		case errors.Is(err, jobe.ErrBadRequest):
+			return c.Status(http.StatusBadRequest).SendString("Missing fileID in url")
+		case errors.As(err, &statusErr):
+			return c.Status(http.StatusBadGateway).SendString(fmt.Sprintf("Unexpected response code from Jobe: %d", statusErr.StatusCode))
+		default:
+			log.Println("Error connecting to Jobe Server:", err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Cannot connect to Jobe Server")
+		}
 	}
-	defer resp.Body.Close()
-
-	log.Printf("Jobe response status: %d", resp.StatusCode)
-
-	switch resp.StatusCode {
-	case http.StatusNoContent: // 204
-		return c.JSON(models.FileUploadResponse{
-			Success: true,
-			FileID:  fileID.(string),
-			Message: "File uploaded successfully to Jobe",
-		})
-	case http.StatusBadRequest: // 400
-		return c.Status(fiber.StatusBadRequest).JSON(models.FileUploadResponse{
-			Success: false,
-			Error:   "Bad request - invalid file contents or missing parameters",
-		})
-	case http.StatusNotFound: // 404
-		return c.Status(fiber.StatusNotFound).JSON(models.FileUploadResponse{
-			Success: false,
-			Error:   "Jobe server endpoint not found",
-		})
-	case http.StatusInternalServerError: // 500
-		return c.Status(fiber.StatusInternalServerError).JSON(models.FileUploadResponse{
-			Success: false,
-			Error:   "Jobe server failed to write file to cache",
-		})
-	default:
-		return c.Status(fiber.StatusInternalServerError).JSON(models.FileUploadResponse{
-			Success: false,
-			Error:   fmt.Sprintf("Unexpected response code from Jobe: %d", resp.StatusCode),
-		})
+	if !exists {
+		return c.Status(http.StatusNotFound).SendString("File not found in Jobe cache")
 	}
+	return c.Status(http.StatusNoContent).SendString("File exists in Jobe cache")
 }
 
-func CheckFile(c *fiber.Ctx) error {
-	fileID := c.Params("id")
-	url := fmt.Sprintf("http://jobe:80/jobe/index.php/restapi/files/%s", fileID)
-	resp, err := http.Head(url)
-	if err != nil {
-		log.Println("Error connecting to Jobe Server:", err)
-		return c.Status(fiber.StatusInternalServerError).SendString("Cannot connect to Jobe Server")
+// fileAccess lấy người dùng hiện tại để kiểm tra quyền với file của proxy
+func fileAccess(c *fiber.Ctx) (services.FileAccess, bool) {
+	email, ok := c.Locals("email").(string)
+	if !ok || email == "" {
+		return services.FileAccess{}, false
 	}
-	defer resp.Body.Close()
-	switch resp.StatusCode {
-	case http.StatusNoContent: // 204
-		return c.Status(http.StatusNoContent).SendString("File exists in Jobe cache")
-	case http.StatusBadRequest: // 400
-		return c.Status(http.StatusBadRequest).SendString("Missing fileID in url")
-	case http.StatusNotFound: // 404
-		return c.Status(http.StatusNotFound).SendString("File not found in Jobe cache")
+	role, _ := c.Locals("role").(string)
+	return services.FileAccess{Email: email, Role: role}, true
+}
+
+// scopedFileErrorResponse trả về lỗi khi file không được lưu vào phạm vi yêu cầu
+func scopedFileErrorResponse(c *fiber.Ctx, err error) error {
+	status := fiber.StatusInternalServerError
+	switch {
+	case errors.Is(err, services.ErrFileForbidden):
+		status = fiber.StatusForbidden
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		status = fiber.StatusNotFound
+	case errors.Is(err, services.ErrInvalidScope), errors.Is(err, services.ErrInvalidFileName),
+		errors.Is(err, services.ErrMissingTestcase):
+		status = fiber.StatusBadRequest
 	default:
-		return c.Status(http.StatusBadGateway).SendString(fmt.Sprintf("Unexpected response code from Jobe: %d", resp.StatusCode))
+		log.Printf("Failed to store scoped file: %v", err)
 	}
+	return c.Status(status).JSON(models.FileUploadResponse{
+		Success: false,
+		Error:   err.Error(),
+	})
 }
 
+// SubmitRun gửi nguyên run_spec trong body tới Jobe và chấm với testcase đầu tiên của post trong query post_id.
+// run_spec do client tự tạo nên file_list có thể trỏ tới file của bất kỳ ai, vì vậy chỉ giáo viên được gọi.
 func SubmitRun(c *fiber.Ctx) error {
-	jobeServerURL := "http://jobe:80/jobe/index.php/restapi"
-	// postIDStr := c.Query("post_id")
-	// studentMail := c.Locals("user_mail").(string)
-	postIDStr := "98436fc5-954a-4648-ac0b-684bb8362fa4"
-	// postID := uuid.Parse(postIDStr)
-	// studentMail := "son.nguyenthai@hcmut.edu.vn"
+	email, ok := requireTeacher(c, "submit raw run specs")
+	if !ok {
+		return nil
+	}
 
-	postID, err := uuid.Parse(postIDStr)
+	postID, err := uuid.Parse(c.Query("post_id"))
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(models.SubmitRunResponse{
 			Status: http.StatusBadRequest,
@@ -299,105 +247,41 @@ func SubmitRun(c *fiber.Ctx) error {
 			Error:  fmt.Sprintf("Invalid run_spec format: %v", err),
 		})
 	}
+	log.Printf("Sending request to Jobe with run_spec: %+v", runSpec)
 
-	requestData := models.SubmitRunRequest{
-		RunSpec: runSpec,
-	}
-	fmt.Print(requestData)
-	jsonData, err := json.Marshal(requestData)
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(models.SubmitRunResponse{
-			Status: http.StatusInternalServerError,
-			Error:  fmt.Sprintf("Error marshaling JSON: %v", err),
+	testcases, err := services.GetTestcasesByPostID(postID)
+	if err != nil || len(testcases) == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(models.SubmitRunResponse{
+			Status: http.StatusNotFound,
+			Error:  "Testcase not found",
 		})
 	}
-	log.Printf("Sending request to Jobe with data: %s", string(jsonData))
+	submissionID := uuid.New()
 
-	// Gửi request POST tới Jobe server
-	url := fmt.Sprintf("%s/runs", jobeServerURL)
-	fmt.Printf("Sending request to Jobe: %s", url)
-	fmt.Print(jsonData)
-	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(models.SubmitRunResponse{
-			Status: http.StatusInternalServerError,
-			Error:  fmt.Sprintf("Error creating request: %v", err),
-		})
+	jobeResult, err := jobePool.Run(c.UserContext(), runSpec)
+	if services.IsJobeBusy(jobeResult, err) {
+		return enqueueRunResponse(c, testcases[0], email, submissionID, runSpec)
 	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "application/json")
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(models.SubmitRunResponse{
-			Status: http.StatusInternalServerError,
-			Error:  fmt.Sprintf("Error sending request to Jobe: %v", err),
-		})
+		return runErrorResponse(c, err)
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	// Gọi CheckRunResult để kiểm tra và lưu kết quả
+	postService := services.NewPostService()
+	source := services.RunSourceForSpec(email, submissionID, runSpec)
+	studentRun, err := postService.CheckRunResult(testcases[0], email, source, jobeResult)
 	if err != nil {
-		log.Printf("Error reading Jobe response body: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(models.SubmitRunResponse{
 			Status: http.StatusInternalServerError,
-			Error:  fmt.Sprintf("Error reading Jobe response: %v", err),
+			Error:  fmt.Sprintf("Error checking run result: %v", err),
 		})
 	}
 
-	log.Printf("Jobe response status: %d", resp.StatusCode)
-	log.Printf("Jobe response body: %s", string(body))
-
-	// log.Printf("Jobe response status: %d", resp.StatusCode)
-	// log.Printf("Jobe body: %d", resp.Body)
-	postService := services.NewPostService()
-	// Xử lý response từ Jobe
-	switch resp.StatusCode {
-	case http.StatusOK:
-		var jobeResult models.JobeRunResult
-		if err := json.Unmarshal(body, &jobeResult); err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(models.SubmitRunResponse{
-				Status: http.StatusInternalServerError,
-				Error:  fmt.Sprintf("Error parsing Jobe response: %v, body: %s", err, string(body))})
-		}
-
-		// Gọi CheckRunResult để kiểm tra và lưu kết quả
-		studentRun, err := postService.CheckRunResult(postID, c.Locals("email").(string), string(body))
-		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(models.SubmitRunResponse{
-				Status: http.StatusInternalServerError,
-				Error:  fmt.Sprintf("Error checking run result: %v", err),
-			})
-		}
-
-		// Trả về chỉ stdout trong Result
-		return c.JSON(models.SubmitRunResponse{
-			Status: http.StatusOK,
-			Result: jobeResult.Stdout, // Chỉ lấy stdout
-			Score:  studentRun.Score,
-			Log:    studentRun.Log,
-		})
-	case http.StatusAccepted: // 202: Job queued for later execution
-		return c.Status(http.StatusAccepted).JSON(models.SubmitRunResponse{
-			Status: http.StatusAccepted,
-			Result: "Job queued for later execution",
-		})
-	case http.StatusBadRequest: // 400: Bad request
-		return c.Status(fiber.StatusBadRequest).JSON(models.SubmitRunResponse{
-			Status: http.StatusBadRequest,
-			Error:  "Bad request - invalid run_spec or missing parameters",
-		})
-	case http.StatusNotFound: // 404: Not found
-		return c.Status(fiber.StatusNotFound).JSON(models.SubmitRunResponse{
-			Status: http.StatusNotFound,
-			Error:  "Jobe server endpoint not found",
-		})
-	default:
-		return c.Status(fiber.StatusInternalServerError).JSON(models.SubmitRunResponse{
-			Status: http.StatusInternalServerError,
-			Error:  fmt.Sprintf("Unexpected response code from Jobe: %d", resp.StatusCode),
-		})
-	}
+	// Trả về chỉ stdout trong Result
+	return c.JSON(models.SubmitRunResponse{
+		Status: http.StatusOK,
+		Result: jobeResult.Stdout, // Chỉ lấy stdout
+		Score:  studentRun.Score,
+		Log:    studentRun.Log,
+	})
 }
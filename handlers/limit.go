@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/tison2810/be-go-tc/database"
+	"github.com/tison2810/be-go-tc/models"
+	"github.com/tison2810/be-go-tc/services"
+)
+
+// GetCourseLimit trả về giới hạn thời gian và bộ nhớ chung của khóa học
+func GetCourseLimit(c *fiber.Ctx) error {
+	limit, err := services.GetCourseLimit()
+	if err != nil {
+		log.Printf("Failed to fetch course limits: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch course limits",
+		})
+	}
+	return c.Status(fiber.StatusOK).JSON(limit)
+}
+
+// UpdateCourseLimit cho phép giáo viên đặt giới hạn mặc định và tối đa của khóa học
+func UpdateCourseLimit(c *fiber.Ctx) error {
+	email, ok := requireTeacher(c, "manage course limits")
+	if !ok {
+		return nil
+	}
+
+	limit := new(models.CourseLimit)
+	if err := c.BodyParser(limit); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Failed to parse JSON: " + err.Error(),
+		})
+	}
+
+	if err := services.ValidateCourseLimit(limit); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	limit.UpdatedBy = email
+	if err := database.DB.Db.Save(limit).Error; err != nil {
+		log.Printf("Failed to update course limits: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update course limits",
+		})
+	}
+	return c.Status(fiber.StatusOK).JSON(limit)
+}
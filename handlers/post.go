@@ -1,10 +1,9 @@
 package handlers
 
 import (
-	"fmt"
+	"errors"
 	"log"
 	"math/rand/v2"
-	"net/http"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -24,25 +23,50 @@ func init() {
 
 func CreatePost(c *fiber.Ctx) error {
 	post, err := services.CreatePostFormData(c)
+	if errors.Is(err, services.ErrGenerateExpectedForbidden) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
 
+	// Post có testcase không qua được lời giải mẫu bị ẩn cho tới khi tác giả sửa testcase và kiểm tra lại.
+	// Kết quả kiểm tra nằm trên từng testcase nên vẫn còn khi post đồng thời bị ẩn vì giống post khác.
+	referenceFailures := services.ReferenceFailures(post.Testcases)
+
 	similarPosts, err := flaskClient.CallSimilarPost(post.ID.String())
 	if err != nil {
 		log.Printf("Failed to call Flask similar post API: %v", err)
-		return c.Status(fiber.StatusCreated).JSON(post)
+		if len(referenceFailures) == 0 {
+			return c.Status(fiber.StatusCreated).JSON(post)
+		}
 	}
 
 	if len(similarPosts) > 0 {
 		if err := database.DB.Db.Model(&models.Post{}).Where("id = ?", post.ID).UpdateColumn("post_status", "similar_hidden").Error; err != nil {
 			log.Printf("Failed to delete post when duplicate: %v", err)
 		}
-		return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		response := fiber.Map{
 			"post":          post,
 			"similar_posts": similarPosts,
+		}
+		if len(referenceFailures) > 0 {
+			response["reference_failures"] = referenceFailures
+		}
+		return c.Status(fiber.StatusAccepted).JSON(response)
+	}
+
+	if len(referenceFailures) > 0 {
+		if err := database.DB.Db.Model(&models.Post{}).Where("id = ?", post.ID).UpdateColumn("post_status", "reference_hidden").Error; err != nil {
+			log.Printf("Failed to hide post failing reference: %v", err)
+		}
+		return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+			"post":               post,
+			"reference_failures": referenceFailures,
 		})
 	}
 
@@ -51,15 +75,38 @@ func CreatePost(c *fiber.Ctx) error {
 	})
 }
 
+// PostAnyway đăng post bị ẩn vì giống post khác. Post còn testcase không qua lời giải mẫu
+// không được đăng cho tới khi tác giả sửa testcase và kiểm tra lại qua /post/:id/validate.
 func PostAnyway(c *fiber.Ctx) error {
 	id := c.Params("id")
-	if err := database.DB.Db.Model(&models.Post{}).Where("id = ?", id).UpdateColumn("post_status", "similar").Error; err != nil {
+
+	var post models.Post
+	if err := database.DB.Db.Preload("Testcases", services.OrderTestcases).
+		Select("id", "post_status").First(&post, "id = ?", id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Post not found",
+		})
+	}
+	if failures := services.ReferenceFailures(post.Testcases); len(failures) > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":              "Some testcases fail the reference solution, fix them and validate the post again",
+			"reference_failures": failures,
+		})
+	}
+
+	// Post chỉ bị ẩn vì không qua lời giải mẫu được đăng như post bình thường khi đã qua
+	status := "similar"
+	if post.PostStatus == "reference_hidden" {
+		status = "active"
+	}
+
+	if err := database.DB.Db.Model(&models.Post{}).Where("id = ?", id).UpdateColumn("post_status", status).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to update post status",
 		})
 	}
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
-		"message": "Post status updated to similar",
+		"message": "Post status updated to " + status,
 	})
 }
 
@@ -188,7 +235,7 @@ func GetAllPostsID(c *fiber.Ctx) error {
 
 //	func GetAllPosts(c *fiber.Ctx) error {
 //		var posts []models.Post
-//		database.DB.Db.Preload("Testcase").Find(&posts)
+//		database.DB.Db.Preload("Testcases", services.OrderTestcases).Find(&posts)
 //		return c.Status(fiber.StatusOK).JSON(posts)
 //	}
 type PostWithType struct {
@@ -209,7 +256,7 @@ func GetAllPosts(c *fiber.Ctx) error {
 
 	// Lấy tất cả bài đăng
 	var posts []models.Post
-	if err := database.DB.Db.Preload("Testcase").Where("post_status IN (?)", []string{"active", "similar"}).Find(&posts).Error; err != nil {
+	if err := database.DB.Db.Preload("Testcases", services.OrderTestcases).Where("post_status IN (?)", []string{"active", "similar"}).Find(&posts).Error; err != nil {
 		log.Printf("Failed to fetch posts: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch posts",
@@ -270,6 +317,7 @@ func GetAllPosts(c *fiber.Ctx) error {
 				VerifiedTeacherMail: stat.VerifiedTeacherMail,
 				Views:               stat.Views, // Lấy từ models.PostStats
 				Runs:                stat.Runs,  // Lấy từ models.PostStats
+				Passed:              stat.Passed(),
 			},
 		})
 	}
@@ -308,12 +356,11 @@ func GetPost(c *fiber.Ctx) error {
 		})
 	}
 
-	var testcase models.Testcase
-	if err := database.DB.Db.Where("post_id = ?", postID).First(&testcase).Error; err == nil {
-		post.Testcase = &testcase
-	} else {
-		post.Testcase = nil // Nếu không có testcase
+	testcases, err := services.GetTestcasesByPostID(postID)
+	if err != nil {
+		log.Printf("Failed to fetch testcases for post %s: %v", postID, err)
 	}
+	post.Testcases = testcases
 
 	// Lấy thông tin user để tạo Author
 	var user models.User
@@ -360,6 +407,7 @@ func GetPost(c *fiber.Ctx) error {
 			VerifiedTeacherMail: stat.VerifiedTeacherMail,
 			Views:               stat.Views, // Lấy từ PostStats
 			Runs:                stat.Runs,  // Lấy từ PostStats
+			Passed:              stat.Passed(),
 		},
 	}
 
@@ -369,7 +417,7 @@ func GetPost(c *fiber.Ctx) error {
 // func UpdatePost(c *fiber.Ctx) error {
 // 	id := c.Params("id")
 // 	post := new(models.Post)
-// 	result := database.DB.Db.Where("id = ?", id).Preload("Testcase").First(&post)
+// 	result := database.DB.Db.Where("id = ?", id).Preload("Testcases", services.OrderTestcases).First(&post)
 // 	if result.Error != nil {
 // 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 // 			"error": "Post not found",
@@ -429,7 +477,7 @@ func GetPost(c *fiber.Ctx) error {
 
 // 	// Tìm bài đăng hiện tại
 // 	post := new(models.Post)
-// 	result := database.DB.Db.Where("id = ?", postID).Preload("Testcase").First(post)
+// 	result := database.DB.Db.Where("id = ?", postID).Preload("Testcases", services.OrderTestcases).First(post)
 // 	if result.Error != nil {
 // 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 // 			"error": "Post not found",
@@ -622,7 +670,7 @@ func GetPostForStudent(c *fiber.Ctx) error {
 	}
 
 	var allPosts []models.Post
-	if err := database.DB.Db.Preload("Testcase").
+	if err := database.DB.Db.Preload("Testcases", services.OrderTestcases).
 		Where("post_status IN (?)", []string{"active", "similar"}).
 		Find(&allPosts).Error; err != nil {
 		log.Printf("Failed to fetch all posts: %v", err)
@@ -735,6 +783,7 @@ func GetPostForStudent(c *fiber.Ctx) error {
 				VerifiedTeacherMail: stat.VerifiedTeacherMail,
 				Views:               stat.Views, // Lấy từ PostStats
 				Runs:                stat.Runs,  // Lấy từ PostStats
+				Passed:              stat.Passed(),
 			},
 		})
 	}
@@ -857,7 +906,7 @@ func SearchPosts(c *fiber.Ctx) error {
 	}
 
 	var searchPosts []models.Post
-	if err := database.DB.Db.Preload("Testcase").
+	if err := database.DB.Db.Preload("Testcases", services.OrderTestcases).
 		Where("title LIKE ? OR description LIKE ?", "%"+query+"%", "%"+query+"%").
 		Where("post_status IN (?)", []string{"active", "similar"}).
 		Find(&searchPosts).Error; err != nil {
@@ -954,6 +1003,7 @@ func SearchPosts(c *fiber.Ctx) error {
 				VerifiedTeacherMail: stat.VerifiedTeacherMail,
 				Views:               stat.Views,
 				Runs:                stat.Runs,
+				Passed:              stat.Passed(),
 			},
 		})
 	}
@@ -982,61 +1032,54 @@ func CheckFileExist(c *fiber.Ctx) error {
 		})
 	}
 
-	hFile := student.Maso + "h"
-	cppFile := student.Maso + "cpp"
-
-	jobeBaseURL := "http://jobe:80/jobe/index.php/restapi/files/"
-
-	// Hàm helper để kiểm tra file trên Jobe
-	checkFileOnJobe := func(fileID string) (int, string, error) {
-		url := fmt.Sprintf("%s%s", jobeBaseURL, fileID)
-		resp, err := http.Head(url)
-		if err != nil {
-			log.Printf("Error connecting to Jobe Server for file %s: %v", fileID, err)
-			return fiber.StatusInternalServerError, "Cannot connect to Jobe Server", err
-		}
-		defer resp.Body.Close()
-
-		switch resp.StatusCode {
-		case http.StatusNoContent: // 204
-			return fiber.StatusNoContent, "File exists in Jobe cache", nil
-		case http.StatusBadRequest: // 400
-			return fiber.StatusBadRequest, "Missing fileID in url", nil
-		case http.StatusNotFound: // 202
-			return fiber.StatusAccepted, "File not found in Jobe cache", nil
-		default:
-			return fiber.StatusBadGateway, fmt.Sprintf("Unexpected response code from Jobe: %d", resp.StatusCode), nil
-		}
+	assignment, err := services.GetAssignmentByParam(c.Query("assignment_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Assignment not found",
+		})
 	}
 
-	// Kiểm tra file .h
-	hStatus, hMessage, hErr := checkFileOnJobe(hFile)
-	if hErr != nil {
-		return c.Status(hStatus).SendString(hMessage)
+	fileIDs := services.StudentFileIDs(student.Maso, assignment)
+	storedFiles, err := services.GetStudentFiles(fileIDs)
+	if err != nil {
+		log.Printf("Failed to fetch student files: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch student files",
+		})
 	}
 
-	// Kiểm tra file .cpp
-	cppStatus, cppMessage, cppErr := checkFileOnJobe(cppFile)
-	if cppErr != nil {
-		return c.Status(cppStatus).SendString(cppMessage)
+	// Kiểm tra từng file sinh viên phải nộp theo assignment đã được lưu trên hệ thống chưa
+	allExist, allMissing := true, true
+	fileStatuses := fiber.Map{}
+	for i, studentFile := range assignment.StudentFiles {
+		stored, ok := storedFiles[fileIDs[i]]
+		if !ok {
+			// File không bắt buộc chưa nộp không làm bài nộp thiếu file
+			allExist = allExist && studentFile.Optional
+			fileStatuses[studentFile.FormKey] = map[string]interface{}{
+				"status":  fiber.StatusNotFound,
+				"message": "File has not been uploaded",
+			}
+			continue
+		}
+		allMissing = false
+		fileStatuses[studentFile.FormKey] = map[string]interface{}{
+			"status":      fiber.StatusNoContent,
+			"message":     "File is stored",
+			"file_name":   stored.FileName,
+			"sha256":      stored.SHA256,
+			"size":        stored.Size,
+			"uploaded_at": stored.UploadedAt,
+		}
 	}
 
 	// Xử lý kết quả
-	if hStatus == fiber.StatusNoContent && cppStatus == fiber.StatusNoContent {
-		return c.Status(fiber.StatusNoContent).SendString("Both files exist in Jobe cache")
-	} else if hStatus == fiber.StatusNotFound && cppStatus == fiber.StatusNotFound {
-		return c.Status(fiber.StatusNotFound).SendString("Both files not found in Jobe cache")
+	if allExist {
+		return c.Status(fiber.StatusNoContent).SendString("All files are stored")
+	} else if allMissing {
+		return c.Status(fiber.StatusNotFound).SendString("No files have been uploaded")
 	} else {
-		return c.Status(fiber.StatusPartialContent).JSON(fiber.Map{
-			"h_file": map[string]interface{}{
-				"status":  hStatus,
-				"message": hMessage,
-			},
-			"cpp_file": map[string]interface{}{
-				"status":  cppStatus,
-				"message": cppMessage,
-			},
-		})
+		return c.Status(fiber.StatusPartialContent).JSON(fileStatuses)
 	}
 }
 
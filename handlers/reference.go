@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/tison2810/be-go-tc/database"
+	"github.com/tison2810/be-go-tc/models"
+	"github.com/tison2810/be-go-tc/services"
+	"gorm.io/gorm"
+)
+
+// GetReferenceSolution trả về thông tin lời giải mẫu của assignment, không kèm nội dung file
+func GetReferenceSolution(c *fiber.Ctx) error {
+	if _, ok := requireTeacher(c, "manage reference solutions"); !ok {
+		return nil
+	}
+	assignment, ok := assignmentParam(c)
+	if !ok {
+		return nil
+	}
+
+	reference, err := services.GetReferenceSolution(assignment.ID)
+	if err != nil {
+		log.Printf("Failed to fetch reference solution: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch reference solution",
+		})
+	}
+	if reference == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Assignment has no reference solution",
+		})
+	}
+	return c.Status(fiber.StatusOK).JSON(reference)
+}
+
+// UploadReferenceSolution lưu lời giải mẫu của assignment, mỗi file sinh viên gửi theo form key của assignment
+func UploadReferenceSolution(c *fiber.Ctx) error {
+	email, ok := requireTeacher(c, "manage reference solutions")
+	if !ok {
+		return nil
+	}
+	assignment, ok := assignmentParam(c)
+	if !ok {
+		return nil
+	}
+
+	var uploads []services.ReferenceUpload
+	for _, studentFile := range assignment.StudentFiles {
+		fileHeader, err := c.FormFile(studentFile.FormKey)
+		if err != nil && studentFile.Optional {
+			continue
+		}
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Missing file " + studentFile.FormKey,
+			})
+		}
+		content, err := readFormFile(c, studentFile.FormKey)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Failed to read file " + studentFile.FormKey,
+			})
+		}
+		uploads = append(uploads, services.ReferenceUpload{
+			FormKey:  studentFile.FormKey,
+			FileName: fileHeader.Filename,
+			Content:  content,
+		})
+	}
+
+	reference, err := services.SaveReferenceSolution(c.UserContext(), assignment, email, uploads)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return c.Status(fiber.StatusOK).JSON(reference)
+}
+
+// DeleteReferenceSolution xóa lời giải mẫu của assignment
+func DeleteReferenceSolution(c *fiber.Ctx) error {
+	if _, ok := requireTeacher(c, "manage reference solutions"); !ok {
+		return nil
+	}
+	assignment, ok := assignmentParam(c)
+	if !ok {
+		return nil
+	}
+
+	if err := services.DeleteReferenceSolution(assignment.ID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Assignment has no reference solution",
+			})
+		}
+		log.Printf("Failed to delete reference solution: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete reference solution",
+		})
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Reference solution deleted",
+	})
+}
+
+// ValidatePost chạy lại các testcase của post với lời giải mẫu, chỉ tác giả hoặc giáo viên được gọi
+func ValidatePost(c *fiber.Ctx) error {
+	email, ok := c.Locals("email").(string)
+	if !ok || email == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "User email not found in context",
+		})
+	}
+	role, _ := c.Locals("role").(string)
+
+	postID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid post ID",
+		})
+	}
+	var post models.Post
+	if err := database.DB.Db.Preload("Testcases", services.OrderTestcases).First(&post, "id = ?", postID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Post not found",
+		})
+	}
+	if post.UserMail != email && role != "teacher" {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Only the author or a teacher can validate this post",
+		})
+	}
+
+	assignment, err := services.GetAssignmentForPost(postID)
+	if err != nil {
+		log.Printf("Failed to fetch assignment: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch assignment",
+		})
+	}
+	checks, err := services.ValidatePostTestcases(c.UserContext(), assignment, &post)
+	if err != nil {
+		log.Printf("Failed to validate post: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to validate post",
+		})
+	}
+	if checks == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Assignment has no reference solution",
+		})
+	}
+
+	// Post bị ẩn vì không qua lời giải mẫu được hiện lại khi mọi testcase đã qua
+	failures := services.ReferenceFailures(post.Testcases)
+	if post.PostStatus == "reference_hidden" && len(failures) == 0 {
+		if err := database.DB.Db.Model(&post).UpdateColumn("post_status", "active").Error; err != nil {
+			log.Printf("Failed to publish validated post: %v", err)
+		}
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"checks":   checks,
+		"failures": failures,
+	})
+}
+
+// PreviewExpected chạy lời giải mẫu với các testcase trong form-data của POST /create
+// và trả về output để tác giả xem trước khi dùng generate_expected. Code của testcase được biên dịch
+// cùng lời giải mẫu và có thể in ra mã nguồn của nó, vì vậy chỉ giáo viên được gọi.
+func PreviewExpected(c *fiber.Ctx) error {
+	if _, ok := requireTeacher(c, "preview reference output"); !ok {
+		return nil
+	}
+	previews, err := services.PreviewExpectedFormData(c)
+	if err != nil {
+		if errors.Is(err, services.ErrNoReferenceSolution) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return c.Status(fiber.StatusOK).JSON(previews)
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/tison2810/be-go-tc/models"
+	"github.com/tison2810/be-go-tc/services"
+	"gorm.io/gorm"
+)
+
+// enqueueRunResponse lưu run vào hàng đợi và trả về run_id để client polling qua GET /runs/:id
+func enqueueRunResponse(c *fiber.Ctx, testcase models.Testcase, studentMail string, submissionID uuid.UUID, runSpec models.RunSpec) error {
+	run, err := services.EnqueueRun(testcase, studentMail, submissionID, runSpec)
+	if err != nil {
+		log.Printf("Failed to enqueue run: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.SubmitRunResponse{
+			Status: http.StatusInternalServerError,
+			Error:  fmt.Sprintf("Error queueing run: %v", err),
+		})
+	}
+	return c.Status(fiber.StatusAccepted).JSON(models.SubmitRunResponse{
+		Status:       http.StatusAccepted,
+		Result:       "Job queued for later execution",
+		RunID:        run.ID.String(),
+		SubmissionID: submissionID.String(),
+	})
+}
+
+// GetRunStatus trả về trạng thái của một run trong hàng đợi
+func GetRunStatus(c *fiber.Ctx) error {
+	email, ok := c.Locals("email").(string)
+	if !ok || email == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "User email not found in context",
+		})
+	}
+
+	runID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid run ID",
+		})
+	}
+
+	run, err := services.GetRun(runID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Run not found",
+			})
+		}
+		log.Printf("Failed to fetch run: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch run",
+		})
+	}
+
+	role, _ := c.Locals("role").(string)
+	if run.StudentMail != email && role != "teacher" {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "You are not authorized to view this run",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(run)
+}
+
+// GetRunQueueStats trả về độ sâu hàng đợi run và số run đang gửi tới Jobe, chỉ giáo viên được xem
+func GetRunQueueStats(c *fiber.Ctx) error {
+	if _, ok := requireTeacher(c, "view the run queue"); !ok {
+		return nil
+	}
+	stats, err := services.GetRunQueueStats()
+	if err != nil {
+		log.Printf("Failed to fetch run queue stats: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch run queue stats",
+		})
+	}
+	return c.Status(fiber.StatusOK).JSON(stats)
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/tison2810/be-go-tc/services"
+	"gorm.io/gorm"
+)
+
+// GetSimilarityReport liệt kê các cặp sinh viên có code giống nhau trong assignment.
+// Query threshold (0..1, mặc định 0.6) là độ giống tối thiểu, form_key giới hạn file được so sánh.
+func GetSimilarityReport(c *fiber.Ctx) error {
+	if _, ok := requireTeacher(c, "view similarity reports"); !ok {
+		return nil
+	}
+	assignment, ok := assignmentParam(c)
+	if !ok {
+		return nil
+	}
+
+	threshold := services.DefaultSimilarityThreshold
+	if value := c.Query("threshold"); value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || parsed <= 0 || parsed > 1 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "threshold must be a number in (0, 1]",
+			})
+		}
+		threshold = parsed
+	}
+
+	pairs, err := services.SimilarityReport(assignment.ID, c.Query("form_key"), threshold)
+	if err != nil {
+		log.Printf("Failed to build similarity report: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to build similarity report",
+		})
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"threshold": threshold,
+		"pairs":     pairs,
+	})
+}
+
+// GetSimilarityPair trả về file form_key của hai sinh viên student_a, student_b kèm các vùng giống nhau
+func GetSimilarityPair(c *fiber.Ctx) error {
+	if _, ok := requireTeacher(c, "view similarity reports"); !ok {
+		return nil
+	}
+	assignment, ok := assignmentParam(c)
+	if !ok {
+		return nil
+	}
+
+	// Chỉ so sánh được từng file nên form_key là bắt buộc, khác với báo cáo
+	formKey, studentA, studentB := c.Query("form_key"), c.Query("student_a"), c.Query("student_b")
+	if formKey == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "form_key is required",
+		})
+	}
+	if studentA == "" || studentB == "" || studentA == studentB {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Two different students (student_a, student_b) are required",
+		})
+	}
+
+	detail, err := services.SimilarityPairDetail(assignment.ID, formKey, studentA, studentB)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Upload not found for both students",
+		})
+	}
+	if err != nil {
+		log.Printf("Failed to compare uploads: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to compare uploads",
+		})
+	}
+	return c.Status(fiber.StatusOK).JSON(detail)
+}
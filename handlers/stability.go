@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/tison2810/be-go-tc/database"
+	"github.com/tison2810/be-go-tc/models"
+	"github.com/tison2810/be-go-tc/services"
+	"gorm.io/gorm"
+)
+
+// CheckPostStability bắt đầu chạy nền việc chạy lặp lại các testcase của post để tìm testcase cho output
+// khác nhau giữa các lần chạy, kết quả lấy bằng GetPostStability. Query runs (mặc định 5, tối đa 10) là số lần
+// chạy mỗi testcase, student là email sinh viên có bài nộp được dùng thay cho lời giải mẫu.
+func CheckPostStability(c *fiber.Ctx) error {
+	email, ok := requireTeacher(c, "check testcase stability")
+	if !ok {
+		return nil
+	}
+
+	postID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid post ID",
+		})
+	}
+	runs := services.DefaultStabilityRuns
+	if value := c.Query("runs"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 2 || parsed > services.MaxStabilityRuns {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "runs must be an integer from 2 to " + strconv.Itoa(services.MaxStabilityRuns),
+			})
+		}
+		runs = parsed
+	}
+
+	var post models.Post
+	if err := database.DB.Db.Preload("Testcases", services.OrderTestcases).First(&post, "id = ?", postID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Post not found",
+		})
+	}
+	assignment, err := services.GetAssignmentForPost(postID)
+	if err != nil {
+		log.Printf("Failed to fetch assignment: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch assignment",
+		})
+	}
+
+	check, err := services.StartStabilityCheck(assignment, &post, c.Query("student"), email, runs)
+	switch {
+	case errors.Is(err, services.ErrStabilityTooManyRuns):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "runs × testcases must be at most " + strconv.Itoa(services.MaxStabilityTotalRuns),
+		})
+	case errors.Is(err, services.ErrStabilityCheckRunning):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Stability check already running",
+			"check": check,
+		})
+	case errors.Is(err, services.ErrNoReferenceSolution):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Assignment has no reference solution",
+		})
+	case errors.Is(err, services.ErrStudentNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Student not found",
+		})
+	case err != nil:
+		log.Printf("Failed to start stability check: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to start stability check",
+		})
+	}
+	return c.Status(fiber.StatusAccepted).JSON(check)
+}
+
+// GetPostStability trả về lần kiểm tra ổn định gần nhất của post, report có khi state là done
+func GetPostStability(c *fiber.Ctx) error {
+	if _, ok := requireTeacher(c, "check testcase stability"); !ok {
+		return nil
+	}
+
+	postID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid post ID",
+		})
+	}
+	check, err := services.GetStabilityCheck(postID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Post has no stability check",
+		})
+	}
+	if err != nil {
+		log.Printf("Failed to fetch stability check: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch stability check",
+		})
+	}
+	return c.Status(fiber.StatusOK).JSON(check)
+}
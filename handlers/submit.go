@@ -1,12 +1,14 @@
 package handlers
 
 import (
-	"bytes"
+	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
+	"path"
 	"strings"
 	"time"
 
@@ -14,6 +16,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/tison2810/be-go-tc/database"
 	"github.com/tison2810/be-go-tc/models"
+	"github.com/tison2810/be-go-tc/policy"
 	"github.com/tison2810/be-go-tc/services"
 	"github.com/tison2810/be-go-tc/utils"
 	"gorm.io/gorm"
@@ -32,134 +35,187 @@ func generateFileID(tokenString string) string {
 }
 
 func UploadTwoFilesHandler(c *fiber.Ctx) error {
-	const maxFileSize = 200 * 1024
-	// Lấy file .cpp
-	cppFile, err := c.FormFile("cpp_file")
+	assignment, err := services.GetAssignmentByParam(c.FormValue("assignment_id"))
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(models.FileUploadResponse{
 			Success: false,
-			Error:   "Không thể đọc file .cpp từ request",
-		})
-	}
-	if !strings.HasSuffix(cppFile.Filename, ".cpp") {
-		return c.Status(fiber.StatusBadRequest).JSON(models.FileUploadResponse{
-			Success: false,
-			Error:   "File cpp_file phải có đuôi .cpp",
+			Error:   fmt.Sprintf("Không tìm thấy assignment: %v", err),
 		})
 	}
 
-	if cppFile.Size > maxFileSize {
+	entries, err := readUploadEntries(c, assignment)
+	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(models.FileUploadResponse{
 			Success: false,
-			Error:   fmt.Sprintf("File .cpp vượt quá kích thước tối đa 200KB (kích thước hiện tại: %d bytes)", cppFile.Size),
+			Error:   err.Error(),
 		})
 	}
 
-	// Lấy file .h
-	hFile, err := c.FormFile("h_file")
+	// Ghép file với danh sách file của assignment, file thiếu, thừa hoặc quá lớn làm upload bị từ chối
+	baseID := generateFileID(c.Locals("token").(string))
+	studentMail, _ := c.Locals("email").(string)
+	files, err := services.MatchUploadFiles(assignment, baseID, entries)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(models.FileUploadResponse{
 			Success: false,
-			Error:   "Không thể đọc file .h từ request",
+			Error:   fmt.Sprintf("File upload không hợp lệ: %v", err),
 		})
 	}
-	if !strings.HasSuffix(hFile.Filename, ".h") {
-		return c.Status(fiber.StatusBadRequest).JSON(models.FileUploadResponse{
-			Success: false,
-			Error:   "File h_file phải có đuôi .h",
+
+	// Kiểm tra tĩnh theo luật của assignment, vi phạm mức error làm upload bị từ chối
+	var violations []models.PolicyViolation
+	for _, file := range files {
+		violations = append(violations, policy.Check(assignment.PolicyRules, file.FormKey, file.FileName, file.Content)...)
+	}
+	if policy.HasErrors(violations) {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(models.FileUploadResponse{
+			Success:    false,
+			Error:      "Code vi phạm quy định của bài tập",
+			Violations: violations,
 		})
 	}
 
-	if hFile.Size > maxFileSize {
-		return c.Status(fiber.StatusBadRequest).JSON(models.FileUploadResponse{
+	// Lưu thành một phiên bản mới trước để có thể upload lại khi Jobe xóa file khỏi cache
+	upload, err := services.CreateUpload(studentMail, assignment.ID, files, violations)
+	if err != nil {
+		log.Printf("Failed to store student upload: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.FileUploadResponse{
 			Success: false,
-			Error:   fmt.Sprintf("File .h vượt quá kích thước tối đa 200KB (kích thước hiện tại: %d bytes)", hFile.Size),
+			Error:   "Không thể lưu file",
 		})
 	}
 
-	baseID := generateFileID(c.Locals("token").(string))
-	cppFileID := fmt.Sprintf("%scpp", baseID) // [xxxxxxx]cpp
-	hFileID := fmt.Sprintf("%sh", baseID)     // [xxxxxxx]h
+	result := fiber.Map{
+		"success": true,
+		"message": "Tất cả file đã được upload thành công",
+		"version": upload.Version,
+		"files":   upload.Files,
+	}
+	if len(violations) > 0 {
+		result["warnings"] = violations
+	}
+	for _, file := range upload.Files {
+		// File đã được lưu nên lỗi từ Jobe không làm upload thất bại, file sẽ được upload lại trước khi chạy
+		if err := jobePool.PutFile(c.UserContext(), file.FileID, file.Content); err != nil {
+			log.Printf("Failed to push student file %s to Jobe, will retry before run: %v", file.FileID, err)
+		}
+		result[file.FormKey+"_id"] = file.FileID
+		result[file.FormKey+"_sha256"] = file.SHA256
+	}
 
-	c.Locals("form_file_key", "cpp_file")
-	c.Locals("file_id", cppFileID)
-	if err := UploadFileToJobeHandler(c); err != nil {
-		return err
+	// Trả về kết quả thành công cho tất cả file
+	return c.JSON(result)
+}
+
+// readUploadEntries đọc file gửi theo form key của assignment, nhiều file theo key files và file zip theo key archive
+func readUploadEntries(c *fiber.Ctx, assignment models.Assignment) ([]services.UploadEntry, error) {
+	var entries []services.UploadEntry
+	for _, studentFile := range assignment.StudentFiles {
+		file, err := c.FormFile(studentFile.FormKey)
+		if err != nil {
+			continue
+		}
+		if file.Size > assignment.MaxFileSize {
+			return nil, fmt.Errorf("File %s vượt quá kích thước tối đa %dKB (kích thước hiện tại: %d bytes)", studentFile.FileName, assignment.MaxFileSize/1024, file.Size)
+		}
+		content, err := readFormFile(c, studentFile.FormKey)
+		if err != nil {
+			return nil, fmt.Errorf("Không thể đọc file từ key '%s'", studentFile.FormKey)
+		}
+		entries = append(entries, services.UploadEntry{FormKey: studentFile.FormKey, Name: file.Filename, Content: content})
 	}
 
-	// Reset response
-	// c.Response().Reset()
+	form, err := c.MultipartForm()
+	if err != nil {
+		return nil, fmt.Errorf("Không thể đọc form-data: %v", err)
+	}
+	for _, file := range form.File[services.ExtraFilesFormKey] {
+		if file.Size > assignment.MaxFileSize {
+			return nil, fmt.Errorf("File %s vượt quá kích thước tối đa %dKB (kích thước hiện tại: %d bytes)", file.Filename, assignment.MaxFileSize/1024, file.Size)
+		}
+		content, err := readFileHeader(file)
+		if err != nil {
+			return nil, fmt.Errorf("Không thể đọc file %s", file.Filename)
+		}
+		entries = append(entries, services.UploadEntry{Name: path.Base(strings.ReplaceAll(file.Filename, `\`, "/")), Content: content})
+	}
 
-	c.Locals("form_file_key", "h_file")
-	c.Locals("file_id", hFileID)
-	if err := UploadFileToJobeHandler(c); err != nil {
-		return err
+	if archives := form.File[services.ArchiveFormKey]; len(archives) > 0 {
+		if len(archives) > 1 {
+			return nil, errors.New("Chỉ được gửi một file zip")
+		}
+		content, err := readFileHeader(archives[0])
+		if err != nil {
+			return nil, fmt.Errorf("Không thể đọc file %s", archives[0].Filename)
+		}
+		archiveEntries, err := services.ReadArchive(content, assignment.MaxFileSize)
+		if err != nil {
+			return nil, fmt.Errorf("File zip không hợp lệ: %v", err)
+		}
+		entries = append(entries, archiveEntries...)
 	}
 
-	// Trả về kết quả thành công cho cả hai file
-	return c.JSON(fiber.Map{
-		"success":     true,
-		"message":     "Cả hai file đã được upload thành công",
-		"cpp_file_id": cppFileID,
-		"h_file_id":   hFileID,
-	})
+	if len(entries) == 0 {
+		return nil, errors.New("Không có file nào trong request")
+	}
+	return entries, nil
 }
 
-func RunCode(c *fiber.Ctx) error {
+// runCodeRequest là một lượt chạy code đã được kiểm tra và ghi nhận vào post, user và post_interactions
+type runCodeRequest struct {
+	studentMail string
+	studentID   string
+	testcases   []models.Testcase
+	assignment  models.Assignment
+	mode        models.RunMode
+}
 
-	jobeServerURL := "http://jobe:80/jobe/index.php/restapi"
+var errPostNotRunnable = errors.New("post not found or has been deleted")
+
+// prepareRunCode kiểm tra request chạy code của post và ghi nhận lượt chạy.
+// Trả về false nếu request không hợp lệ, khi đó response lỗi đã được gửi cho client.
+func prepareRunCode(c *fiber.Ctx) (*runCodeRequest, bool) {
+	fail := func(status int, message string) (*runCodeRequest, bool) {
+		c.Status(status).JSON(models.SubmitRunResponse{Status: status, Error: message})
+		return nil, false
+	}
 
 	// Lấy email từ Locals (do AuthMiddleware cung cấp)
 	studentMail, ok := c.Locals("email").(string)
 	if !ok || studentMail == "" {
-		return c.Status(fiber.StatusUnauthorized).JSON(models.SubmitRunResponse{
-			Status: http.StatusUnauthorized,
-			Error:  "User email not found in context",
-		})
+		return fail(http.StatusUnauthorized, "User email not found in context")
 	}
 
 	// Lấy studentID từ database
 	studentID, err := services.GetMaso(database.DB.Db, studentMail)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(models.SubmitRunResponse{
-			Status: http.StatusInternalServerError,
-			Error:  fmt.Sprintf("Error getting student ID: %v", err),
-		})
+		return fail(http.StatusInternalServerError, fmt.Sprintf("Error getting student ID: %v", err))
+	}
+
+	mode, err := services.ParseRunMode(c.Query("mode"))
+	if err != nil {
+		return fail(http.StatusBadRequest, err.Error())
 	}
 
 	// Lấy post_id từ param
 	postIDStr := c.Params("id")
 	if postIDStr == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(models.SubmitRunResponse{
-			Status: http.StatusBadRequest,
-			Error:  "Missing post_id in URL parameter",
-		})
+		return fail(http.StatusBadRequest, "Missing post_id in URL parameter")
 	}
 	postID, err := uuid.Parse(postIDStr)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(models.SubmitRunResponse{
-			Status: http.StatusBadRequest,
-			Error:  "Invalid post_id",
-		})
+		return fail(http.StatusBadRequest, "Invalid post_id")
 	}
 
 	// Lấy testcase từ database
-	testcase, err := services.GetTestcaseByPostID(postID)
+	testcases, err := services.GetTestcasesByPostID(postID)
 	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(models.SubmitRunResponse{
-			Status: http.StatusNotFound,
-			Error:  fmt.Sprintf("Error retrieving testcase: %v", err),
-		})
+		return fail(http.StatusNotFound, fmt.Sprintf("Error retrieving testcase: %v", err))
+	}
+	if len(testcases) == 0 {
+		return fail(http.StatusNotFound, "Error retrieving testcase: post has no testcase")
 	}
 
-	// mainCode := testcase.Code
-
-	headers := `#include "main.h"
-	#include "tc.h"
-	#include "hcmcampaign.h"
-
-	`
-	mainCode := headers + testcase.Code
 	var postType int
 	suggestedPosts, err := flaskClient.CallSuggest(studentMail)
 	if err != nil {
@@ -180,10 +236,7 @@ func RunCode(c *fiber.Ctx) error {
 		// Tăng Runs trong Post
 		var post models.Post
 		if err := tx.First(&post, "id = ? AND post_status IN (?)", postID, []string{"active", "similar"}).Error; err != nil {
-			return c.Status(fiber.StatusNotFound).JSON(models.SubmitRunResponse{
-				Status: http.StatusNotFound,
-				Error:  "Post not found or has been deleted",
-			})
+			return errPostNotRunnable
 		}
 		post.Runs++
 		if postType == 1 {
@@ -222,178 +275,120 @@ func RunCode(c *fiber.Ctx) error {
 		return nil
 	})
 
+	if errors.Is(err, errPostNotRunnable) {
+		return fail(http.StatusNotFound, "Post not found or has been deleted")
+	}
 	if err != nil {
-		if _, ok := err.(*fiber.Error); ok {
-			return err
-		}
 		log.Printf("Failed to process run interaction: %v", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(models.SubmitRunResponse{
-			Status: http.StatusInternalServerError,
-			Error:  "Failed to process run interaction",
-		})
+		return fail(http.StatusInternalServerError, "Failed to process run interaction")
 	}
 
-	// Tạo config filename (cố định hoặc dùng post_id tùy ý)
-	configFileName := "config.txt" // Giữ cố định như trong code của bạn
-	fileName := strings.ReplaceAll(postID.String(), "-", "")
-	// Tạo RunSpec với cấu hình cứng
-	// 	runSpec := models.RunSpec{
-	// 		LanguageID: "cpp",
-	// 		SourceCode: fmt.Sprintf(`#include "hcmcampaign.h"
-
-	// using namespace std;
-
-	// void g_satc_01() {
-	//     cout << "----- Sample Testcase 01 -----" << endl;
-	//     Configuration* config = new Configuration("%s");
-	//     cout << config->str() << endl;
-	//     delete config;
-	// }
-
-	// int main(int argc, const char * argv[]) {
-	//     g_satc_01();
-	//     return 0;
-	// }`, configFileName),
-	// 		SourceFilename: "main.cpp",
-	// 		Input:          "",
-	// 		FileList: [][]interface{}{
-	// 			{fmt.Sprintf("%scpp", studentID), "hcmcampaign.cpp"}, // Dùng studentID cho file_id
-	// 			{fmt.Sprintf("%sh", studentID), "hcmcampaign.h"},     // Dùng studentID cho file_id
-	// 			{"systemmainh", "main.h"},
-	// 			{"configtxt", configFileName}, // File config cố định
-	// 		},
-	// 		Parameters: map[string]interface{}{
-	// 			"max_execution_time": 5,
-	// 			"max_memory_usage":   1000000,
-	// 			"compileargs":        []string{"-I .", "-std=c++11"},
-	// 			"linkargs":           []string{"hcmcampaign.cpp"},
-	// 			"args":               []string{configFileName},
-	// 		},
-	// 		Debug: true,
-	// 	}
-
-	runSpec := models.RunSpec{
-		LanguageID:     "cpp",
-		SourceCode:     mainCode,
-		SourceFilename: "tc.cpp",
-		Input:          "",
-		FileList: [][]interface{}{
-			{fmt.Sprintf("%scpp", studentID), "hcmcampaign.cpp"}, // Dùng studentID cho file_id
-			{fmt.Sprintf("%sh", studentID), "hcmcampaign.h"},     // Dùng studentID cho file_id
-			{"systemmainh", "main.h"},
-			{"systemmaincpp", "main.cpp"},
-			{"systemtch", "tc.h"},
-			{fileName, configFileName}, // File config cố định
-		},
-		Parameters: map[string]interface{}{
-			"max_execution_time": 5,
-			"max_memory_usage":   1000000,
-			"compileargs":        []string{"-I .", "-std=c++11"},
-			"linkargs":           []string{"hcmcampaign.cpp", "main.cpp"},
-			"args":               []string{configFileName},
-		},
-		Debug: true,
-	}
-
-	requestData := models.SubmitRunRequest{
-		RunSpec: runSpec,
-	}
-	log.Printf("RunSpec: %+v", requestData)
-
-	// Chuyển thành JSON
-	jsonData, err := json.Marshal(requestData)
+	// Lấy cấu hình build của assignment mà post tham chiếu
+	assignment, err := services.GetAssignmentForPost(postID)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(models.SubmitRunResponse{
-			Status: http.StatusInternalServerError,
-			Error:  fmt.Sprintf("Error marshaling JSON: %v", err),
-		})
+		return fail(http.StatusInternalServerError, fmt.Sprintf("Error retrieving assignment: %v", err))
 	}
 
-	log.Printf("Sending request to Jobe with data: %s", string(jsonData))
+	return &runCodeRequest{
+		studentMail: studentMail,
+		studentID:   studentID,
+		testcases:   testcases,
+		assignment:  assignment,
+		mode:        mode,
+	}, true
+}
 
-	url := fmt.Sprintf("%s/runs", jobeServerURL)
-	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(models.SubmitRunResponse{
-			Status: http.StatusInternalServerError,
-			Error:  fmt.Sprintf("Error creating request: %v", err),
-		})
+func RunCode(c *fiber.Ctx) error {
+	req, ok := prepareRunCode(c)
+	if !ok {
+		return nil
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "application/json")
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
+	// Chạy tất cả testcase của post và tính điểm tổng
+	postService := services.NewPostService()
+	response, err := postService.RunSubmission(c.UserContext(), req.assignment, req.studentMail, req.studentID, req.testcases, req.mode)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(models.SubmitRunResponse{
-			Status: http.StatusInternalServerError,
-			Error:  fmt.Sprintf("Error sending request to Jobe: %v", err),
-		})
+		return runErrorResponse(c, err)
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Printf("Error reading Jobe response body: %v", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(models.SubmitRunResponse{
-			Status: http.StatusInternalServerError,
-			Error:  fmt.Sprintf("Error reading Jobe response: %v", err),
-		})
-	}
-
-	log.Printf("Jobe response status: %d", resp.StatusCode)
-	log.Printf("Jobe response body: %s", string(body))
+	return c.Status(response.Status).JSON(response)
+}
 
-	// Khởi tạo PostService
-	postService := services.NewPostService()
+const (
+	streamRunTimeout   = 2 * time.Minute  // Thời gian tối đa của một lần chạy qua stream
+	streamRunKeepAlive = 15 * time.Second // Gửi comment định kỳ để proxy không đóng kết nối
+)
 
-	// Xử lý response từ Jobe
-	switch resp.StatusCode {
-	case http.StatusOK:
-		var jobeResult models.JobeRunResult
-		if err := json.Unmarshal(body, &jobeResult); err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(models.SubmitRunResponse{
-				Status: http.StatusInternalServerError,
-				Error:  fmt.Sprintf("Error parsing Jobe response: %v, body: %s", err, string(body)),
-			})
-		}
+// StreamRunCode chạy code như RunCode nhưng gửi tiến trình qua Server-Sent Events.
+// Mỗi event có tên là stage của RunEvent, event cuối là result chứa SubmitRunResponse hoặc error.
+func StreamRunCode(c *fiber.Ctx) error {
+	req, ok := prepareRunCode(c)
+	if !ok {
+		return nil
+	}
 
-		// Gọi CheckRunResult để kiểm tra và lưu kết quả
-		studentRun, err := postService.CheckRunResult(postID, studentMail, string(body))
-		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(models.SubmitRunResponse{
-				Status: http.StatusInternalServerError,
-				Error:  fmt.Sprintf("Error checking run result: %v", err),
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	// Stream writer chạy sau khi handler trả về nên không được dùng c bên trong
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		ctx, cancel := context.WithTimeout(context.Background(), streamRunTimeout)
+		defer cancel()
+
+		events := make(chan models.RunEvent, 8)
+		go func() {
+			defer close(events)
+			// Vòng lặp bên dưới luôn đọc hết events nên gửi không bị chặn mãi
+			progressCtx := services.WithRunProgress(ctx, func(event models.RunEvent) {
+				events <- event
 			})
+			response, err := services.NewPostService().RunSubmission(progressCtx, req.assignment, req.studentMail, req.studentID, req.testcases, req.mode)
+			final := models.RunEvent{Stage: models.RunStageResult, Result: response}
+			if err != nil {
+				result := runError(err)
+				final = models.RunEvent{Stage: models.RunStageError, Error: result.Error, Result: &result}
+			}
+			events <- final
+		}()
+
+		keepAlive := time.NewTicker(streamRunKeepAlive)
+		defer keepAlive.Stop()
+		for {
+			var err error
+			select {
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+				err = writeRunEvent(w, event)
+			case <-keepAlive.C:
+				if _, err = w.WriteString(": keep-alive\n\n"); err == nil {
+					err = w.Flush()
+				}
+			}
+			if err != nil {
+				// Client đã ngắt kết nối, hủy các bước còn lại của lần chạy
+				log.Printf("Run stream closed by client: %v", err)
+				cancel()
+				for range events {
+				}
+				return
+			}
 		}
+	})
+	return nil
+}
 
-		// Trả về chỉ stdout trong Result
-		return c.JSON(models.SubmitRunResponse{
-			Status: http.StatusOK,
-			Result: jobeResult.Stdout,
-			Score:  studentRun.Score,
-			Log:    studentRun.Log,
-		})
-	case http.StatusAccepted: // 202: Job queued
-		return c.Status(fiber.StatusAccepted).JSON(models.SubmitRunResponse{
-			Status: http.StatusAccepted,
-			Result: "Job queued for later execution",
-		})
-	case http.StatusBadRequest: // 400: Bad request
-		return c.Status(fiber.StatusBadRequest).JSON(models.SubmitRunResponse{
-			Status: http.StatusBadRequest,
-			Error:  "Bad request - invalid run_spec or missing parameters",
-		})
-	case http.StatusNotFound: // 404: Not found
-		return c.Status(fiber.StatusNotFound).JSON(models.SubmitRunResponse{
-			Status: http.StatusNotFound,
-			Error:  "Missing file",
-		})
-	default:
-		return c.Status(fiber.StatusInternalServerError).JSON(models.SubmitRunResponse{
-			Status: http.StatusInternalServerError,
-			Error:  fmt.Sprintf("Unexpected response code from Jobe: %d", resp.StatusCode),
-		})
+// writeRunEvent ghi một event theo định dạng Server-Sent Events
+func writeRunEvent(w *bufio.Writer, event models.RunEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Stage, data); err != nil {
+		return err
 	}
+	return w.Flush()
 }
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/tison2810/be-go-tc/services"
+	"gorm.io/gorm"
+)
+
+// GetUserUploads trả về lịch sử các phiên bản code mà sinh viên đã upload
+func GetUserUploads(c *fiber.Ctx) error {
+	userMail, ok := c.Locals("email").(string)
+	if !ok || userMail == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "User email not found in context",
+		})
+	}
+
+	uploads, err := services.ListUploads(userMail)
+	if err != nil {
+		log.Printf("Failed to fetch uploads: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch uploads",
+		})
+	}
+	return c.Status(fiber.StatusOK).JSON(uploads)
+}
+
+// DiffUserUploads so sánh hai phiên bản upload qua query from và to
+func DiffUserUploads(c *fiber.Ctx) error {
+	userMail, ok := c.Locals("email").(string)
+	if !ok || userMail == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "User email not found in context",
+		})
+	}
+
+	from, errFrom := strconv.Atoi(c.Query("from"))
+	to, errTo := strconv.Atoi(c.Query("to"))
+	if errFrom != nil || errTo != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Query parameters from and to must be version numbers",
+		})
+	}
+
+	diffs, err := services.DiffUploads(userMail, from, to)
+	if err != nil {
+		return uploadErrorResponse(c, err, "Failed to diff uploads")
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"from":  from,
+		"to":    to,
+		"files": diffs,
+	})
+}
+
+// RestoreUserUpload đặt một phiên bản cũ làm bản đang dùng và upload lại lên Jobe
+func RestoreUserUpload(c *fiber.Ctx) error {
+	userMail, ok := c.Locals("email").(string)
+	if !ok || userMail == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "User email not found in context",
+		})
+	}
+
+	version, err := strconv.Atoi(c.Params("version"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid version",
+		})
+	}
+
+	upload, err := services.RestoreUpload(c.UserContext(), userMail, version)
+	if err != nil {
+		return uploadErrorResponse(c, err, "Failed to restore upload")
+	}
+	return c.Status(fiber.StatusOK).JSON(upload)
+}
+
+func uploadErrorResponse(c *fiber.Ctx, err error, message string) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Upload version not found",
+		})
+	}
+	log.Printf("%s: %v", message, err)
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": message,
+	})
+}
@@ -42,7 +42,7 @@ func GetLikedPosts(c *fiber.Ctx) error {
 	// Truy vấn các bài post tương ứng
 	var posts []models.Post
 	if err := database.DB.Db.Where("id IN ? AND post_status IN (?)", postIDs, []string{"active", "similar"}).
-		Preload("Testcase").Find(&posts).Error; err != nil {
+		Preload("Testcases", services.OrderTestcases).Find(&posts).Error; err != nil {
 		log.Printf("Failed to fetch posts: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch posts",
@@ -97,6 +97,7 @@ func GetLikedPosts(c *fiber.Ctx) error {
 				VerifiedTeacherMail: stat.VerifiedTeacherMail,
 				Views:               stat.Views,
 				Runs:                stat.Runs,
+				Passed:              stat.Passed(),
 			},
 		})
 	}
@@ -116,7 +117,7 @@ func GetUserPosts(c *fiber.Ctx) error {
 	// Truy vấn các bài post của người dùng
 	var posts []models.Post
 	if err := database.DB.Db.Where("user_mail = ? AND post_status IN (?)", userMail, []string{"active", "similar"}).
-		Preload("Testcase").Find(&posts).Error; err != nil {
+		Preload("Testcases", services.OrderTestcases).Find(&posts).Error; err != nil {
 		log.Printf("Failed to fetch user posts: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch user posts",
@@ -182,6 +183,7 @@ func GetUserPosts(c *fiber.Ctx) error {
 				VerifiedTeacherMail: stat.VerifiedTeacherMail,
 				Views:               stat.Views,
 				Runs:                stat.Runs,
+				Passed:              stat.Passed(),
 			},
 		})
 	}
@@ -226,7 +228,7 @@ func GetCommentedPosts(c *fiber.Ctx) error {
 	// Truy vấn các bài post tương ứng
 	var posts []models.Post
 	if err := database.DB.Db.Where("id IN ? AND post_status IN (?)", postIDs, []string{"active", "similar"}).
-		Preload("Testcase").Find(&posts).Error; err != nil {
+		Preload("Testcases", services.OrderTestcases).Find(&posts).Error; err != nil {
 		log.Printf("Failed to fetch commented posts: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch commented posts",
@@ -281,6 +283,7 @@ func GetCommentedPosts(c *fiber.Ctx) error {
 				VerifiedTeacherMail: stat.VerifiedTeacherMail,
 				Views:               stat.Views,
 				Runs:                stat.Runs,
+				Passed:              stat.Passed(),
 			},
 		})
 	}
@@ -325,7 +328,7 @@ func GetUserComments(c *fiber.Ctx) error {
 	// Truy vấn các bài post tương ứng
 	var posts []models.Post
 	if err := database.DB.Db.Where("id IN ? AND post_status IN (?)", postIDs, []string{"active", "similar"}).
-		Preload("Testcase").Find(&posts).Error; err != nil {
+		Preload("Testcases", services.OrderTestcases).Find(&posts).Error; err != nil {
 		log.Printf("Failed to fetch posts: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch posts",
@@ -396,6 +399,7 @@ func GetUserComments(c *fiber.Ctx) error {
 				VerifiedTeacherMail: stat.VerifiedTeacherMail,
 				Views:               stat.Views,
 				Runs:                stat.Runs,
+				Passed:              stat.Passed(),
 			},
 		}
 
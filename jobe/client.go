@@ -0,0 +1,161 @@
+package jobe
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/tison2810/be-go-tc/models"
+)
+
+// DefaultBaseURL là địa chỉ REST API của Jobe trong docker-compose
+const DefaultBaseURL = "http://jobe:80/jobe/index.php/restapi"
+
+// Client là struct để gọi REST API của một Jobe server
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// Language là một ngôn ngữ được Jobe hỗ trợ
+type Language struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// NewClient tạo Client với base URL dạng http://host/jobe/index.php/restapi
+func NewClient(baseURL string) *Client {
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// NewClientFromEnv tạo Client từ biến môi trường JOBE_URL, mặc định là DefaultBaseURL
+func NewClientFromEnv() *Client {
+	baseURL := os.Getenv("JOBE_URL")
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return NewClient(baseURL)
+}
+
+// BaseURL trả về base URL mà Client đang dùng
+func (c *Client) BaseURL() string {
+	return c.baseURL
+}
+
+// PutFile upload nội dung file vào file cache của Jobe với ID cho trước
+func (c *Client) PutFile(ctx context.Context, fileID string, contents []byte) error {
+	body := models.UploadFileRequest{
+		FileContents: base64.StdEncoding.EncodeToString(contents),
+	}
+	status, respBody, err := c.do(ctx, http.MethodPut, "/files/"+url.PathEscape(fileID), body)
+	if err != nil {
+		return fmt.Errorf("jobe: put file %s: %w", fileID, err)
+	}
+	if status != http.StatusNoContent {
+		return &StatusError{Op: "put file " + fileID, StatusCode: status, Body: respBody}
+	}
+	return nil
+}
+
+// HeadFile kiểm tra file có tồn tại trong file cache của Jobe hay không
+func (c *Client) HeadFile(ctx context.Context, fileID string) (bool, error) {
+	status, respBody, err := c.do(ctx, http.MethodHead, "/files/"+url.PathEscape(fileID), nil)
+	if err != nil {
+		return false, fmt.Errorf("jobe: head file %s: %w", fileID, err)
+	}
+	switch status {
+	case http.StatusNoContent:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, &StatusError{Op: "head file " + fileID, StatusCode: status, Body: respBody}
+	}
+}
+
+// Run gửi một run_spec tới Jobe và trả về kết quả chạy.
+// Status 202 được trả về dưới dạng lỗi ErrQueued vì Jobe không cho phép lấy lại kết quả sau đó.
+func (c *Client) Run(ctx context.Context, spec models.RunSpec) (*models.JobeRunResult, error) {
+	status, respBody, err := c.do(ctx, http.MethodPost, "/runs", models.SubmitRunRequest{RunSpec: spec})
+	if err != nil {
+		return nil, fmt.Errorf("jobe: run: %w", err)
+	}
+	if status != http.StatusOK {
+		return nil, &StatusError{Op: "run", StatusCode: status, Body: respBody}
+	}
+
+	var result models.JobeRunResult
+	if err := json.Unmarshal([]byte(respBody), &result); err != nil {
+		return nil, fmt.Errorf("%w: %v, body: %s", ErrInvalidResponse, err, respBody)
+	}
+	return &result, nil
+}
+
+// Languages trả về danh sách ngôn ngữ mà Jobe hỗ trợ
+func (c *Client) Languages(ctx context.Context) ([]Language, error) {
+	status, respBody, err := c.do(ctx, http.MethodGet, "/languages", nil)
+	if err != nil {
+		return nil, fmt.Errorf("jobe: languages: %w", err)
+	}
+	if status != http.StatusOK {
+		return nil, &StatusError{Op: "languages", StatusCode: status, Body: respBody}
+	}
+
+	// Jobe trả về mảng các cặp [name, version]
+	var pairs [][]string
+	if err := json.Unmarshal([]byte(respBody), &pairs); err != nil {
+		return nil, fmt.Errorf("%w: %v, body: %s", ErrInvalidResponse, err, respBody)
+	}
+	languages := make([]Language, 0, len(pairs))
+	for _, pair := range pairs {
+		if len(pair) != 2 {
+			continue
+		}
+		languages = append(languages, Language{Name: pair[0], Version: pair[1]})
+	}
+	return languages, nil
+}
+
+// do gửi request JSON tới Jobe và trả về status code cùng body của response
+func (c *Client) do(ctx context.Context, method, path string, payload interface{}) (int, string, error) {
+	var reader io.Reader
+	if payload != nil {
+		jsonData, err := json.Marshal(payload)
+		if err != nil {
+			return 0, "", err
+		}
+		reader = bytes.NewReader(jsonData)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return 0, "", err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, "", err
+	}
+	return resp.StatusCode, strings.TrimSpace(string(body)), nil
+}
@@ -0,0 +1,184 @@
+package jobe_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/tison2810/be-go-tc/jobe"
+	"github.com/tison2810/be-go-tc/jobe/jobetest"
+	"github.com/tison2810/be-go-tc/models"
+)
+
+func runSpec(files ...string) models.RunSpec {
+	spec := models.RunSpec{LanguageID: "cpp", SourceCode: "int main() {}", SourceFilename: "main.cpp"}
+	for _, file := range files {
+		spec.FileList = append(spec.FileList, []interface{}{file, file + ".cpp"})
+	}
+	return spec
+}
+
+func TestPutAndHeadFile(t *testing.T) {
+	server := jobetest.NewServer()
+	defer server.Close()
+	client := server.JobeClient()
+	ctx := context.Background()
+
+	exists, err := client.HeadFile(ctx, "abc")
+	if err != nil || exists {
+		t.Fatalf("HeadFile before upload = %v, %v; want false, nil", exists, err)
+	}
+
+	contents := []byte("int x = 1;\n\x00binary")
+	if err := client.PutFile(ctx, "abc", contents); err != nil {
+		t.Fatalf("PutFile: %v", err)
+	}
+	if got, ok := server.File("abc"); !ok || string(got) != string(contents) {
+		t.Fatalf("stored file = %q, %v; want %q", got, ok, contents)
+	}
+
+	exists, err = client.HeadFile(ctx, "abc")
+	if err != nil || !exists {
+		t.Fatalf("HeadFile after upload = %v, %v; want true, nil", exists, err)
+	}
+
+	server.DeleteFile("abc")
+	if exists, _ := client.HeadFile(ctx, "abc"); exists {
+		t.Fatal("HeadFile after cache eviction = true, want false")
+	}
+}
+
+func TestRunStatusErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		want   error
+	}{
+		{"queued", http.StatusAccepted, jobe.ErrQueued},
+		{"bad request", http.StatusBadRequest, jobe.ErrBadRequest},
+		{"missing file", http.StatusNotFound, jobe.ErrNotFound},
+		{"server error", http.StatusInternalServerError, jobe.ErrServerError},
+		{"overloaded", http.StatusServiceUnavailable, jobe.ErrOverloaded},
+		{"unexpected", http.StatusTeapot, jobe.ErrUnexpectedStatus},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := jobetest.NewServer()
+			defer server.Close()
+			server.SetRunFunc(func(models.RunSpec, map[string][]byte) (int, models.JobeRunResult) {
+				return tt.status, models.JobeRunResult{}
+			})
+
+			result, err := server.JobeClient().Run(context.Background(), runSpec())
+			if result != nil {
+				t.Errorf("result = %+v, want nil", result)
+			}
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+			var statusErr *jobe.StatusError
+			if !errors.As(err, &statusErr) || statusErr.StatusCode != tt.status {
+				t.Errorf("err = %#v, want StatusError with status %d", err, tt.status)
+			}
+		})
+	}
+}
+
+func TestRunMissingFile(t *testing.T) {
+	server := jobetest.NewServer()
+	defer server.Close()
+	client := server.JobeClient()
+	ctx := context.Background()
+
+	_, err := client.Run(ctx, runSpec("student1cpp"))
+	if !errors.Is(err, jobe.ErrNotFound) {
+		t.Fatalf("run with missing file: err = %v, want ErrNotFound", err)
+	}
+
+	if err := client.PutFile(ctx, "student1cpp", []byte("void f() {}")); err != nil {
+		t.Fatalf("PutFile: %v", err)
+	}
+	result, err := client.Run(ctx, runSpec("student1cpp"))
+	if err != nil {
+		t.Fatalf("run after upload: %v", err)
+	}
+	if result.Outcome != jobe.OutcomeSuccess {
+		t.Errorf("outcome = %d, want %d", result.Outcome, jobe.OutcomeSuccess)
+	}
+	if runs := server.Runs(); len(runs) != 2 {
+		t.Errorf("server received %d runs, want 2", len(runs))
+	}
+}
+
+func TestRunOutcomes(t *testing.T) {
+	tests := []struct {
+		name    string
+		result  models.JobeRunResult
+		verdict models.Verdict
+	}{
+		{"success", models.JobeRunResult{Outcome: jobe.OutcomeSuccess, Stdout: "42\n"}, models.VerdictAccepted},
+		{"compile error", models.JobeRunResult{Outcome: jobe.OutcomeCompileError, Cmpinfo: "error"}, models.VerdictCompileError},
+		{"time limit", models.JobeRunResult{Outcome: jobe.OutcomeTimeLimit}, models.VerdictTimeLimit},
+		{"server overload", models.JobeRunResult{Outcome: jobe.OutcomeServerOverload}, models.VerdictOverload},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := jobetest.NewServer()
+			defer server.Close()
+			server.SetRunFunc(func(models.RunSpec, map[string][]byte) (int, models.JobeRunResult) {
+				return http.StatusOK, tt.result
+			})
+
+			result, err := server.JobeClient().Run(context.Background(), runSpec())
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			if *result != tt.result {
+				t.Errorf("result = %+v, want %+v", *result, tt.result)
+			}
+			if got := jobe.Verdict(result, true); got != tt.verdict {
+				t.Errorf("verdict = %s, want %s", got, tt.verdict)
+			}
+		})
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	server := jobetest.NewServer()
+	defer server.Close()
+	server.SetRunFunc(func(models.RunSpec, map[string][]byte) (int, models.JobeRunResult) {
+		time.Sleep(200 * time.Millisecond)
+		return http.StatusOK, models.JobeRunResult{Outcome: jobe.OutcomeSuccess}
+	})
+
+	client := server.JobeClient()
+	if got := client.HTTPTimeout(); got != 10*time.Second {
+		t.Fatalf("default timeout = %v, want 10s", got)
+	}
+	client.SetHTTPTimeout(50 * time.Millisecond)
+
+	_, err := client.Run(context.Background(), runSpec())
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Fatalf("err = %v, want a timeout error", err)
+	}
+	if errors.Is(err, jobe.ErrOverloaded) || errors.Is(err, jobe.ErrQueued) {
+		t.Errorf("timeout must not be reported as a Jobe status: %v", err)
+	}
+}
+
+func TestLanguages(t *testing.T) {
+	server := jobetest.NewServer()
+	defer server.Close()
+
+	languages, err := server.JobeClient().Languages(context.Background())
+	if err != nil {
+		t.Fatalf("Languages: %v", err)
+	}
+	if len(languages) != 3 || languages[1] != (jobe.Language{Name: "cpp", Version: "11.4.0"}) {
+		t.Errorf("languages = %+v", languages)
+	}
+}
@@ -0,0 +1,53 @@
+package jobe
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Các lỗi tương ứng với status code được mô tả trong tài liệu REST API của Jobe.
+// Dùng errors.Is để phân loại lỗi trả về từ Client.
+var (
+	ErrQueued           = errors.New("jobe: run queued for later execution")        // 202
+	ErrBadRequest       = errors.New("jobe: bad request")                           // 400
+	ErrNotFound         = errors.New("jobe: not found")                             // 404
+	ErrServerError      = errors.New("jobe: internal server error")                 // 500
+	ErrOverloaded       = errors.New("jobe: server overloaded")                     // 503
+	ErrUnexpectedStatus = errors.New("jobe: unexpected response status")            // các status khác
+	ErrInvalidResponse  = errors.New("jobe: response body could not be understood") // body không hợp lệ
+	ErrNoHealthyNode    = errors.New("jobe: no healthy node available")             // mọi node trong Pool đều bị loại
+	ErrTooManyRuns      = errors.New("jobe: too many runs in flight")               // Pool đã đạt giới hạn run đồng thời
+)
+
+// StatusError mô tả một response có status code không thành công từ Jobe
+type StatusError struct {
+	Op         string // Thao tác đang thực hiện, ví dụ "put file", "run"
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("jobe: %s: status %d", e.Op, e.StatusCode)
+	}
+	return fmt.Sprintf("jobe: %s: status %d: %s", e.Op, e.StatusCode, e.Body)
+}
+
+// Unwrap trả về lỗi sentinel ứng với status code để errors.Is hoạt động
+func (e *StatusError) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusAccepted:
+		return ErrQueued
+	case http.StatusBadRequest:
+		return ErrBadRequest
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusInternalServerError:
+		return ErrServerError
+	case http.StatusServiceUnavailable:
+		return ErrOverloaded
+	default:
+		return ErrUnexpectedStatus
+	}
+}
@@ -0,0 +1,13 @@
+package jobe
+
+import "time"
+
+// HTTPTimeout trả về timeout của http.Client bên trong, chỉ dùng trong test
+func (c *Client) HTTPTimeout() time.Duration {
+	return c.httpClient.Timeout
+}
+
+// SetHTTPTimeout đổi timeout để test không phải chờ đủ 10 giây
+func (c *Client) SetHTTPTimeout(timeout time.Duration) {
+	c.httpClient.Timeout = timeout
+}
@@ -0,0 +1,269 @@
+// Package jobetest cung cấp một Jobe server giả lập chạy trong bộ nhớ,
+// dùng để viết test cho các handler mà không cần sandbox Jobe thật.
+package jobetest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"github.com/tison2810/be-go-tc/jobe"
+	"github.com/tison2810/be-go-tc/models"
+)
+
+// APIPath là tiền tố đường dẫn REST API giống Jobe thật
+const APIPath = "/jobe/index.php/restapi"
+
+// Giới hạn Jobe dùng khi run_spec không đặt cputime hoặc memorylimit
+const (
+	DefaultCPUTime     = 5   // Giây
+	DefaultMemoryLimit = 200 // MB
+)
+
+// RunFunc quyết định status code và kết quả trả về cho một run_spec.
+// files chứa nội dung các file hiện có trong cache giả lập.
+type RunFunc func(spec models.RunSpec, files map[string][]byte) (int, models.JobeRunResult)
+
+// Usage là tài nguyên giả lập mà một run sử dụng
+type Usage struct {
+	CPUTime float64 // Giây
+	Memory  int     // MB
+}
+
+// UsageFunc trả về tài nguyên giả lập của run_spec, dùng để kiểm tra giới hạn như Jobe thật
+type UsageFunc func(spec models.RunSpec) Usage
+
+// Limits là các tham số giới hạn mà Jobe đọc từ parameters của run_spec.
+// Các tham số khác như max_execution_time bị Jobe bỏ qua.
+type Limits struct {
+	CPUTime     int      // cputime, giây
+	MemoryLimit int      // memorylimit, MB, 0 là không giới hạn
+	RunArgs     []string // runargs, tham số dòng lệnh của chương trình
+}
+
+// ParseLimits đọc giới hạn từ run_spec giống Jobe, dùng giá trị mặc định cho tham số không có
+func ParseLimits(spec models.RunSpec) Limits {
+	limits := Limits{CPUTime: DefaultCPUTime, MemoryLimit: DefaultMemoryLimit}
+	parameters, _ := spec.Parameters.(map[string]interface{})
+	if value, ok := number(parameters["cputime"]); ok {
+		limits.CPUTime = value
+	}
+	if value, ok := number(parameters["memorylimit"]); ok {
+		limits.MemoryLimit = value
+	}
+	switch args := parameters["runargs"].(type) {
+	case []string:
+		limits.RunArgs = args
+	case []interface{}:
+		for _, arg := range args {
+			if arg, ok := arg.(string); ok {
+				limits.RunArgs = append(limits.RunArgs, arg)
+			}
+		}
+	}
+	return limits
+}
+
+// number đọc số từ parameters, là float64 khi đã qua JSON
+func number(value interface{}) (int, bool) {
+	switch v := value.(type) {
+	case int:
+		return v, true
+	case float64:
+		return int(v), true
+	}
+	return 0, false
+}
+
+// Server là Jobe server giả lập
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	files     map[string][]byte
+	runs      []models.RunSpec
+	runFunc   RunFunc
+	usageFunc UsageFunc
+	languages [][]string
+}
+
+// NewServer khởi động một Jobe server giả lập, cần gọi Close khi dùng xong
+func NewServer() *Server {
+	s := &Server{
+		files:     make(map[string][]byte),
+		runFunc:   DefaultRun,
+		languages: [][]string{{"c", "11.4.0"}, {"cpp", "11.4.0"}, {"python3", "3.10.12"}},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// JobeClient trả về jobe.Client trỏ tới server giả lập
+func (s *Server) JobeClient() *jobe.Client {
+	return jobe.NewClient(s.URL + APIPath)
+}
+
+// SetRunFunc thay đổi cách server giả lập xử lý POST /runs
+func (s *Server) SetRunFunc(fn RunFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.runFunc = fn
+}
+
+// SetUsageFunc đặt tài nguyên giả lập của mỗi run. Run chạy thành công nhưng vượt cputime
+// có outcome 13, vượt memorylimit có outcome 17.
+func (s *Server) SetUsageFunc(fn UsageFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.usageFunc = fn
+}
+
+// SetFile đặt sẵn một file vào cache giả lập
+func (s *Server) SetFile(fileID string, contents []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[fileID] = contents
+}
+
+// File trả về nội dung file trong cache giả lập
+func (s *Server) File(fileID string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	contents, ok := s.files[fileID]
+	return contents, ok
+}
+
+// DeleteFile xóa file khỏi cache giả lập, mô phỏng việc Jobe dọn cache
+func (s *Server) DeleteFile(fileID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.files, fileID)
+}
+
+// Runs trả về các run_spec server đã nhận theo thứ tự
+func (s *Server) Runs() []models.RunSpec {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]models.RunSpec(nil), s.runs...)
+}
+
+// DefaultRun trả về 404 nếu thiếu file trong file_list, ngược lại chạy thành công với stdout rỗng
+func DefaultRun(spec models.RunSpec, files map[string][]byte) (int, models.JobeRunResult) {
+	for _, entry := range spec.FileList {
+		if len(entry) == 0 {
+			continue
+		}
+		fileID, _ := entry[0].(string)
+		if _, ok := files[fileID]; !ok {
+			return http.StatusNotFound, models.JobeRunResult{}
+		}
+	}
+	return http.StatusOK, models.JobeRunResult{Outcome: 15}
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	path, ok := strings.CutPrefix(r.URL.Path, APIPath)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch {
+	case path == "/languages" && r.Method == http.MethodGet:
+		s.mu.Lock()
+		languages := s.languages
+		s.mu.Unlock()
+		writeJSON(w, http.StatusOK, languages)
+	case strings.HasPrefix(path, "/files/"):
+		s.handleFile(w, r, strings.TrimPrefix(path, "/files/"))
+	case path == "/runs" && r.Method == http.MethodPost:
+		s.handleRun(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) handleFile(w http.ResponseWriter, r *http.Request, fileID string) {
+	if fileID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodHead:
+		if _, ok := s.File(fileID); ok {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	case http.MethodPut:
+		var req models.UploadFileRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		contents, err := base64.StdEncoding.DecodeString(req.FileContents)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		s.SetFile(fileID, contents)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleRun(w http.ResponseWriter, r *http.Request) {
+	var req models.SubmitRunRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if req.RunSpec.LanguageID == "" || req.RunSpec.SourceCode == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.runs = append(s.runs, req.RunSpec)
+	files := make(map[string][]byte, len(s.files))
+	for id, contents := range s.files {
+		files[id] = contents
+	}
+	runFunc, usageFunc := s.runFunc, s.usageFunc
+	s.mu.Unlock()
+
+	status, result := runFunc(req.RunSpec, files)
+	if status != http.StatusOK {
+		w.WriteHeader(status)
+		return
+	}
+	if usageFunc != nil && result.Outcome == jobe.OutcomeSuccess {
+		result = enforceLimits(result, usageFunc(req.RunSpec), ParseLimits(req.RunSpec))
+	}
+	writeJSON(w, status, result)
+}
+
+// enforceLimits đổi outcome của run thành công khi tài nguyên vượt giới hạn trong run_spec
+func enforceLimits(result models.JobeRunResult, usage Usage, limits Limits) models.JobeRunResult {
+	switch {
+	case usage.CPUTime > float64(limits.CPUTime):
+		result.Outcome = jobe.OutcomeTimeLimit
+	case limits.MemoryLimit > 0 && usage.Memory > limits.MemoryLimit:
+		result.Outcome = jobe.OutcomeMemoryLimit
+	default:
+		return result
+	}
+	result.Stdout = ""
+	return result
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
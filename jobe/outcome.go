@@ -0,0 +1,13 @@
+package jobe
+
+// Các giá trị outcome trong kết quả run của Jobe
+const (
+	OutcomeCompileError   = 11
+	OutcomeRuntimeError   = 12
+	OutcomeTimeLimit      = 13
+	OutcomeSuccess        = 15
+	OutcomeMemoryLimit    = 17
+	OutcomeIllegalSyscall = 19
+	OutcomeInternalError  = 20
+	OutcomeServerOverload = 21
+)
@@ -0,0 +1,505 @@
+package jobe
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tison2810/be-go-tc/models"
+)
+
+const (
+	// DefaultHealthCheckInterval là chu kỳ gọi /languages để kiểm tra các node
+	DefaultHealthCheckInterval = 15 * time.Second
+	healthCheckTimeout         = 5 * time.Second
+	// Số lỗi liên tiếp khi gọi một node trước khi node bị loại khỏi Pool
+	failureThreshold = 3
+	// Số run đồng thời mặc định trên mỗi node và thời gian chờ chỗ trống mặc định
+	defaultMaxInFlightPerNode = 8
+	defaultSlotWait           = 2 * time.Second
+)
+
+// FileSource trả về nội dung file theo file ID để upload lại lên node đang thiếu file.
+// ok là false nếu nguồn không biết file đó.
+type FileSource func(ctx context.Context, fileID string) (contents []byte, ok bool, err error)
+
+// NodeStatus là trạng thái của một node trong Pool
+type NodeStatus struct {
+	BaseURL     string    `json:"base_url"`
+	Healthy     bool      `json:"healthy"`
+	Outstanding int64     `json:"outstanding"` // Số run đang chạy trên node
+	LastError   string    `json:"last_error,omitempty"`
+	LastCheck   time.Time `json:"last_check"`
+}
+
+type node struct {
+	client      *Client
+	outstanding atomic.Int64
+
+	mu        sync.Mutex
+	healthy   bool
+	failures  int
+	lastError string
+	lastCheck time.Time
+}
+
+// Pool phân phối request tới nhiều Jobe server. Run được gửi tới node khỏe có ít run
+// đang chạy nhất, node lỗi liên tiếp sẽ bị loại cho tới khi health check thành công.
+// Vì mỗi Jobe có file cache riêng, file được upload lên mọi node và được upload lại
+// từ các FileSource khi node chạy job báo thiếu file.
+type Pool struct {
+	nodes []*node
+	next  atomic.Uint64 // Xoay vòng node bắt đầu khi nhiều node cùng tải
+
+	mu      sync.RWMutex
+	sources []FileSource
+
+	// slots giới hạn số run đang gửi tới Jobe trên toàn Pool, nil là không giới hạn
+	slots    chan struct{}
+	slotWait time.Duration
+}
+
+// NewPool tạo Pool từ các Client, cần ít nhất một Client
+func NewPool(clients ...*Client) *Pool {
+	pool := &Pool{}
+	for _, client := range clients {
+		pool.nodes = append(pool.nodes, &node{client: client, healthy: true})
+	}
+	return pool
+}
+
+// NewPoolFromEnv tạo Pool từ biến môi trường JOBE_URLS (các URL cách nhau bởi dấu phẩy),
+// nếu không có thì dùng JOBE_URL hoặc DefaultBaseURL như NewClientFromEnv
+func NewPoolFromEnv() *Pool {
+	var clients []*Client
+	for _, baseURL := range strings.Split(os.Getenv("JOBE_URLS"), ",") {
+		if baseURL = strings.TrimSpace(baseURL); baseURL != "" {
+			clients = append(clients, NewClient(baseURL))
+		}
+	}
+	if len(clients) == 0 {
+		clients = append(clients, NewClientFromEnv())
+	}
+	pool := NewPool(clients...)
+
+	// JOBE_MAX_IN_FLIGHT giới hạn số run đồng thời, 0 là không giới hạn
+	limit := defaultMaxInFlightPerNode * len(clients)
+	if value, err := strconv.Atoi(os.Getenv("JOBE_MAX_IN_FLIGHT")); err == nil && value >= 0 {
+		limit = value
+	}
+	wait := defaultSlotWait
+	if value, err := time.ParseDuration(os.Getenv("JOBE_SLOT_WAIT")); err == nil && value >= 0 {
+		wait = value
+	}
+	pool.SetConcurrency(limit, wait)
+	return pool
+}
+
+// SetConcurrency giới hạn số run gửi tới Jobe cùng lúc trên toàn Pool. Run chờ chỗ trống
+// tối đa wait rồi trả về ErrTooManyRuns. limit <= 0 là không giới hạn.
+// Chỉ gọi trước khi Pool được dùng.
+func (p *Pool) SetConcurrency(limit int, wait time.Duration) {
+	if limit <= 0 {
+		p.slots = nil
+		return
+	}
+	p.slots = make(chan struct{}, limit)
+	p.slotWait = wait
+}
+
+// InFlight trả về số run đang gửi tới Jobe và giới hạn hiện tại, giới hạn 0 là không giới hạn
+func (p *Pool) InFlight() (int, int) {
+	if p.slots == nil {
+		var total int64
+		for _, n := range p.nodes {
+			total += n.outstanding.Load()
+		}
+		return int(total), 0
+	}
+	return len(p.slots), cap(p.slots)
+}
+
+// AddFileSource thêm nguồn file dùng khi upload lại, các nguồn được hỏi theo thứ tự thêm vào
+func (p *Pool) AddFileSource(source FileSource) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sources = append(p.sources, source)
+}
+
+// Nodes trả về trạng thái hiện tại của các node
+func (p *Pool) Nodes() []NodeStatus {
+	statuses := make([]NodeStatus, 0, len(p.nodes))
+	for _, n := range p.nodes {
+		n.mu.Lock()
+		statuses = append(statuses, NodeStatus{
+			BaseURL:     n.client.BaseURL(),
+			Healthy:     n.healthy,
+			Outstanding: n.outstanding.Load(),
+			LastError:   n.lastError,
+			LastCheck:   n.lastCheck,
+		})
+		n.mu.Unlock()
+	}
+	return statuses
+}
+
+// StartHealthChecks kiểm tra các node theo chu kỳ cho tới khi ctx bị hủy
+func (p *Pool) StartHealthChecks(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			p.CheckHealth(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// CheckHealth gọi /languages trên mọi node, node lỗi bị loại và node trả lời được đưa lại vào Pool
+func (p *Pool) CheckHealth(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, n := range p.nodes {
+		wg.Add(1)
+		go func(n *node) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+			defer cancel()
+			_, err := n.client.Languages(checkCtx)
+			n.setHealth(err)
+		}(n)
+	}
+	wg.Wait()
+}
+
+// PutFile upload file lên mọi node khỏe, chỉ trả về lỗi nếu không node nào nhận được file
+func (p *Pool) PutFile(ctx context.Context, fileID string, contents []byte) error {
+	nodes := p.healthyNodes()
+	if len(nodes) == 0 {
+		return ErrNoHealthyNode
+	}
+
+	errs := make([]error, len(nodes))
+	var wg sync.WaitGroup
+	for i, n := range nodes {
+		wg.Add(1)
+		go func(i int, n *node) {
+			defer wg.Done()
+			errs[i] = n.client.PutFile(ctx, fileID, contents)
+			n.record(ctx, errs[i])
+		}(i, n)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err == nil {
+			return nil
+		}
+	}
+	return errs[0]
+}
+
+// HeadFile kiểm tra file có trên ít nhất một node khỏe hoặc có thể upload lại từ nguồn file
+func (p *Pool) HeadFile(ctx context.Context, fileID string) (bool, error) {
+	nodes := p.healthyNodes()
+	if len(nodes) == 0 {
+		return false, ErrNoHealthyNode
+	}
+
+	var firstErr error
+	for _, n := range nodes {
+		exists, err := n.client.HeadFile(ctx, fileID)
+		n.record(ctx, err)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if exists {
+			return true, nil
+		}
+	}
+
+	if _, ok, err := p.lookupFile(ctx, fileID); err == nil && ok {
+		return true, nil
+	}
+	return false, firstErr
+}
+
+// Languages trả về danh sách ngôn ngữ của node khỏe đầu tiên trả lời được
+func (p *Pool) Languages(ctx context.Context) ([]Language, error) {
+	err := ErrNoHealthyNode
+	for _, n := range p.healthyNodes() {
+		var languages []Language
+		languages, err = n.client.Languages(ctx)
+		n.record(ctx, err)
+		if err == nil {
+			return languages, nil
+		}
+	}
+	return nil, err
+}
+
+// Run chạy run_spec trên node khỏe có ít run đang chạy nhất. Nếu node lỗi hoặc quá tải,
+// run được thử lại trên các node còn lại; khi mọi node đều bận, kết quả của lần thử
+// cuối được trả về để người gọi đưa vào hàng đợi.
+func (p *Pool) Run(ctx context.Context, spec models.RunSpec) (*models.JobeRunResult, error) {
+	release, err := p.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	tried := make(map[*node]bool)
+	var lastResult *models.JobeRunResult
+	lastErr := ErrNoHealthyNode
+
+	for {
+		n := p.pick(tried)
+		if n == nil {
+			return lastResult, lastErr
+		}
+		tried[n] = true
+
+		result, err := p.runOn(ctx, n, spec)
+		n.record(ctx, err)
+		if !retryOnOtherNode(result, err) || ctx.Err() != nil {
+			return result, err
+		}
+		lastResult, lastErr = result, err
+	}
+}
+
+// acquire chờ chỗ trống trong giới hạn run đồng thời, trả về hàm giải phóng chỗ
+func (p *Pool) acquire(ctx context.Context) (func(), error) {
+	if p.slots == nil {
+		return func() {}, nil
+	}
+	release := func() { <-p.slots }
+	select {
+	case p.slots <- struct{}{}:
+		return release, nil
+	default:
+	}
+
+	timer := time.NewTimer(p.slotWait)
+	defer timer.Stop()
+	select {
+	case p.slots <- struct{}{}:
+		return release, nil
+	case <-timer.C:
+		return nil, ErrTooManyRuns
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// runOn chạy run_spec trên một node, upload lại các file bị thiếu nếu Jobe trả về 404
+func (p *Pool) runOn(ctx context.Context, n *node, spec models.RunSpec) (*models.JobeRunResult, error) {
+	n.outstanding.Add(1)
+	defer n.outstanding.Add(-1)
+
+	result, err := n.client.Run(ctx, spec)
+	if errors.Is(err, ErrNotFound) && len(p.restoreFiles(ctx, n, FileListIDs(spec.FileList))) > 0 {
+		result, err = n.client.Run(ctx, spec)
+	}
+	return result, err
+}
+
+// EnsureFiles kiểm tra bằng HEAD từng node khỏe còn giữ các file hay không
+// và upload lại từ nguồn file những file bị thiếu. Trả về các file đã được upload lại lên ít nhất một node.
+func (p *Pool) EnsureFiles(ctx context.Context, fileIDs ...string) ([]string, error) {
+	nodes := p.healthyNodes()
+	if len(nodes) == 0 {
+		return nil, ErrNoHealthyNode
+	}
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		restored = make(map[string]bool)
+	)
+	for _, n := range nodes {
+		wg.Add(1)
+		go func(n *node) {
+			defer wg.Done()
+			ids := p.restoreFiles(ctx, n, fileIDs)
+			mu.Lock()
+			for _, id := range ids {
+				restored[id] = true
+			}
+			mu.Unlock()
+		}(n)
+	}
+	wg.Wait()
+
+	var result []string
+	for _, fileID := range fileIDs {
+		if restored[fileID] {
+			result = append(result, fileID)
+		}
+	}
+	return result, nil
+}
+
+// restoreFiles upload lại các file mà node đang thiếu, trả về các file đã được upload
+func (p *Pool) restoreFiles(ctx context.Context, n *node, fileIDs []string) []string {
+	var restored []string
+	for _, fileID := range fileIDs {
+		exists, err := n.client.HeadFile(ctx, fileID)
+		if err != nil || exists {
+			continue
+		}
+		contents, ok, err := p.lookupFile(ctx, fileID)
+		if err != nil || !ok {
+			log.Printf("jobe: file %s missing on %s and no source has it: %v", fileID, n.client.BaseURL(), err)
+			continue
+		}
+		if err := n.client.PutFile(ctx, fileID, contents); err != nil {
+			log.Printf("jobe: failed to restore file %s on %s: %v", fileID, n.client.BaseURL(), err)
+			continue
+		}
+		restored = append(restored, fileID)
+	}
+	return restored
+}
+
+// FileListIDs lấy file ID từ các phần tử [file_id, file_name] của file_list
+func FileListIDs(fileList [][]interface{}) []string {
+	ids := make([]string, 0, len(fileList))
+	for _, entry := range fileList {
+		if len(entry) == 0 {
+			continue
+		}
+		if fileID, ok := entry[0].(string); ok {
+			ids = append(ids, fileID)
+		}
+	}
+	return ids
+}
+
+// lookupFile tìm nội dung file trong các FileSource
+func (p *Pool) lookupFile(ctx context.Context, fileID string) ([]byte, bool, error) {
+	p.mu.RLock()
+	sources := p.sources
+	p.mu.RUnlock()
+
+	for _, source := range sources {
+		contents, ok, err := source(ctx, fileID)
+		if err != nil {
+			return nil, false, err
+		}
+		if ok {
+			return contents, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+// pick chọn node khỏe chưa thử có ít run đang chạy nhất
+func (p *Pool) pick(tried map[*node]bool) *node {
+	start := int(p.next.Add(1))
+	var best *node
+	for i := range p.nodes {
+		n := p.nodes[(start+i)%len(p.nodes)]
+		if tried[n] || !n.isHealthy() {
+			continue
+		}
+		if best == nil || n.outstanding.Load() < best.outstanding.Load() {
+			best = n
+		}
+	}
+	return best
+}
+
+func (p *Pool) healthyNodes() []*node {
+	var nodes []*node
+	for _, n := range p.nodes {
+		if n.isHealthy() {
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes
+}
+
+// retryOnOtherNode cho biết kết quả có nên được thử lại trên node khác hay không
+func retryOnOtherNode(result *models.JobeRunResult, err error) bool {
+	if err == nil {
+		return result.Outcome == OutcomeServerOverload
+	}
+	return errors.Is(err, ErrQueued) || errors.Is(err, ErrOverloaded) || IsNodeFailure(err)
+}
+
+// IsNodeFailure cho biết lỗi là do node (mất kết nối, lỗi 500) chứ không phải do request
+func IsNodeFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, ErrServerError) {
+		return true
+	}
+	// Các lỗi sentinel còn lại đều là response hợp lệ của Jobe hoặc do chính Pool trả về
+	for _, sentinel := range []error{ErrQueued, ErrBadRequest, ErrNotFound, ErrOverloaded, ErrUnexpectedStatus,
+		ErrInvalidResponse, ErrNoHealthyNode, ErrTooManyRuns} {
+		if errors.Is(err, sentinel) {
+			return false
+		}
+	}
+	return true
+}
+
+func (n *node) isHealthy() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.healthy
+}
+
+// record cập nhật số lỗi liên tiếp của node và loại node khi vượt ngưỡng.
+// Lỗi do ctx của người gọi bị hủy không được tính.
+func (n *node) record(ctx context.Context, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if !IsNodeFailure(err) {
+		n.failures = 0
+		return
+	}
+	n.failures++
+	n.lastError = err.Error()
+	if n.healthy && n.failures >= failureThreshold {
+		n.healthy = false
+		log.Printf("jobe: ejecting node %s after %d failures: %v", n.client.BaseURL(), n.failures, err)
+	}
+}
+
+// setHealth cập nhật trạng thái node theo kết quả health check
+func (n *node) setHealth(err error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.lastCheck = time.Now()
+	if err != nil {
+		n.lastError = err.Error()
+		if n.healthy {
+			log.Printf("jobe: ejecting node %s, health check failed: %v", n.client.BaseURL(), err)
+		}
+		n.healthy = false
+		return
+	}
+	if !n.healthy {
+		log.Printf("jobe: node %s is healthy again", n.client.BaseURL())
+	}
+	n.healthy = true
+	n.failures = 0
+	n.lastError = ""
+}
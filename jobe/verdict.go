@@ -0,0 +1,33 @@
+package jobe
+
+import "github.com/tison2810/be-go-tc/models"
+
+// Verdict suy ra verdict từ kết quả run. matched cho biết stdout có khớp expected hay không,
+// chỉ được dùng khi chương trình chạy thành công.
+func Verdict(result *models.JobeRunResult, matched bool) models.Verdict {
+	if result.Cmpinfo != "" || result.Outcome == OutcomeCompileError {
+		return models.VerdictCompileError
+	}
+	switch result.Outcome {
+	case OutcomeSuccess:
+		if result.Stderr != "" {
+			return models.VerdictRuntimeError
+		}
+		if matched {
+			return models.VerdictAccepted
+		}
+		return models.VerdictWrongAnswer
+	case OutcomeRuntimeError:
+		return models.VerdictRuntimeError
+	case OutcomeTimeLimit:
+		return models.VerdictTimeLimit
+	case OutcomeMemoryLimit:
+		return models.VerdictMemoryLimit
+	case OutcomeIllegalSyscall:
+		return models.VerdictIllegal
+	case OutcomeServerOverload:
+		return models.VerdictOverload
+	default:
+		return models.VerdictSandboxError
+	}
+}
@@ -0,0 +1,84 @@
+package jobe_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/tison2810/be-go-tc/jobe"
+	"github.com/tison2810/be-go-tc/jobe/jobetest"
+	"github.com/tison2810/be-go-tc/models"
+)
+
+func TestVerdict(t *testing.T) {
+	tests := []struct {
+		name    string
+		result  models.JobeRunResult
+		matched bool
+		want    models.Verdict
+	}{
+		{"compile error", models.JobeRunResult{Outcome: jobe.OutcomeCompileError, Cmpinfo: "error: expected ';'"}, false, models.VerdictCompileError},
+		{"compile error without cmpinfo", models.JobeRunResult{Outcome: jobe.OutcomeCompileError}, false, models.VerdictCompileError},
+		{"cmpinfo wins over success", models.JobeRunResult{Outcome: jobe.OutcomeSuccess, Cmpinfo: "ld: undefined reference"}, true, models.VerdictCompileError},
+		{"cmpinfo wins over runtime error", models.JobeRunResult{Outcome: jobe.OutcomeRuntimeError, Cmpinfo: "warning treated as error"}, false, models.VerdictCompileError},
+		{"runtime error", models.JobeRunResult{Outcome: jobe.OutcomeRuntimeError, Stderr: "Segmentation fault"}, false, models.VerdictRuntimeError},
+		{"runtime error ignores match", models.JobeRunResult{Outcome: jobe.OutcomeRuntimeError}, true, models.VerdictRuntimeError},
+		{"time limit", models.JobeRunResult{Outcome: jobe.OutcomeTimeLimit}, true, models.VerdictTimeLimit},
+		{"accepted", models.JobeRunResult{Outcome: jobe.OutcomeSuccess, Stdout: "42\n"}, true, models.VerdictAccepted},
+		{"wrong answer", models.JobeRunResult{Outcome: jobe.OutcomeSuccess, Stdout: "41\n"}, false, models.VerdictWrongAnswer},
+		{"success with stderr", models.JobeRunResult{Outcome: jobe.OutcomeSuccess, Stdout: "42\n", Stderr: "terminate called after throwing"}, true, models.VerdictRuntimeError},
+		{"success with stderr and wrong output", models.JobeRunResult{Outcome: jobe.OutcomeSuccess, Stderr: "warning"}, false, models.VerdictRuntimeError},
+		{"memory limit", models.JobeRunResult{Outcome: jobe.OutcomeMemoryLimit}, false, models.VerdictMemoryLimit},
+		{"illegal system call", models.JobeRunResult{Outcome: jobe.OutcomeIllegalSyscall}, false, models.VerdictIllegal},
+		{"internal error", models.JobeRunResult{Outcome: jobe.OutcomeInternalError}, true, models.VerdictSandboxError},
+		{"server overload", models.JobeRunResult{Outcome: jobe.OutcomeServerOverload}, true, models.VerdictOverload},
+		{"unknown outcome", models.JobeRunResult{Outcome: 99}, true, models.VerdictSandboxError},
+		{"missing outcome", models.JobeRunResult{}, true, models.VerdictSandboxError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := tt.result
+			if got := jobe.Verdict(&result, tt.matched); got != tt.want {
+				t.Errorf("Verdict(%+v, %v) = %s, want %s", tt.result, tt.matched, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerdictBusyOutcomes(t *testing.T) {
+	// Outcome 21 là Jobe quá tải chứ không phải lỗi của code, Pool thử lại trên node khác
+	overloaded := jobetest.NewServer()
+	defer overloaded.Close()
+	overloaded.SetRunFunc(func(models.RunSpec, map[string][]byte) (int, models.JobeRunResult) {
+		return http.StatusOK, models.JobeRunResult{Outcome: jobe.OutcomeServerOverload}
+	})
+	spec := models.RunSpec{LanguageID: "cpp", SourceCode: "int main() {}"}
+
+	result, err := jobe.NewPool(overloaded.JobeClient()).Run(context.Background(), spec)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if got := jobe.Verdict(result, true); got != models.VerdictOverload {
+		t.Errorf("Verdict with only an overloaded node = %s, want %s", got, models.VerdictOverload)
+	}
+
+	healthy := jobetest.NewServer()
+	defer healthy.Close()
+	result, err = jobe.NewPool(overloaded.JobeClient(), healthy.JobeClient()).Run(context.Background(), spec)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if got := jobe.Verdict(result, true); got != models.VerdictAccepted {
+		t.Errorf("Verdict with a healthy node = %s, want %s", got, models.VerdictAccepted)
+	}
+
+	// 202 và 503 là lỗi, không có kết quả để suy ra verdict
+	for _, status := range []int{http.StatusAccepted, http.StatusServiceUnavailable} {
+		overloaded.SetRunFunc(func(models.RunSpec, map[string][]byte) (int, models.JobeRunResult) {
+			return status, models.JobeRunResult{}
+		})
+		if _, err := jobe.NewPool(overloaded.JobeClient()).Run(context.Background(), spec); err == nil {
+			t.Errorf("status %d: Run returned no error", status)
+		}
+	}
+}
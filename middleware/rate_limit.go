@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"math"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	defaultStudentRunsPerMinute = 6
+	defaultTeacherRunsPerMinute = 60
+	// Bucket đầy và không được dùng quá lâu sẽ bị xóa để map không tăng mãi
+	bucketIdleTimeout = 10 * time.Minute
+)
+
+// tokenBucket chứa tối đa capacity token, được nạp lại đều rate token mỗi giây
+type tokenBucket struct {
+	tokens   float64
+	capacity float64
+	rate     float64
+	updated  time.Time
+}
+
+// take lấy một token, nếu hết thì trả về thời gian phải chờ tới khi có token
+func (b *tokenBucket) take(now time.Time) (bool, time.Duration) {
+	b.tokens = math.Min(b.capacity, b.tokens+now.Sub(b.updated).Seconds()*b.rate)
+	b.updated = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// runLimiter giữ token bucket của từng người dùng theo email
+type runLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	quotas    map[string]int // Số run mỗi phút theo role
+	lastSweep time.Time
+}
+
+// RunRateLimit giới hạn số lần chạy code của mỗi người dùng bằng token bucket.
+// Quota theo role đọc từ RUN_RATE_STUDENT và RUN_RATE_TEACHER (số run mỗi phút,
+// cũng là số run tối đa được chạy dồn một lúc). Request vượt quota nhận 429 kèm Retry-After.
+// Phải dùng sau AuthMiddleware, các route dùng chung một handler sẽ dùng chung quota.
+func RunRateLimit() fiber.Handler {
+	limiter := &runLimiter{
+		buckets: make(map[string]*tokenBucket),
+		quotas: map[string]int{
+			"student": runQuotaFromEnv("RUN_RATE_STUDENT", defaultStudentRunsPerMinute),
+			"teacher": runQuotaFromEnv("RUN_RATE_TEACHER", defaultTeacherRunsPerMinute),
+		},
+		lastSweep: time.Now(),
+	}
+
+	return func(c *fiber.Ctx) error {
+		email, _ := c.Locals("email").(string)
+		role, _ := c.Locals("role").(string)
+		ok, wait := limiter.allow(email, role, time.Now())
+		if !ok {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+				"status": fiber.StatusTooManyRequests,
+				"error":  "Too many runs, please wait before running again",
+			})
+		}
+		return c.Next()
+	}
+}
+
+func (l *runLimiter) allow(email, role string, now time.Time) (bool, time.Duration) {
+	quota, ok := l.quotas[role]
+	if !ok {
+		quota = l.quotas["student"]
+	}
+	if quota <= 0 {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now)
+
+	key := role + ":" + email
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{
+			tokens:   float64(quota),
+			capacity: float64(quota),
+			rate:     float64(quota) / 60,
+			updated:  now,
+		}
+		l.buckets[key] = bucket
+	}
+	return bucket.take(now)
+}
+
+// sweep xóa các bucket đã nạp đầy và không được dùng trong bucketIdleTimeout
+func (l *runLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < bucketIdleTimeout {
+		return
+	}
+	l.lastSweep = now
+	for key, bucket := range l.buckets {
+		if now.Sub(bucket.updated) >= bucketIdleTimeout {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// runQuotaFromEnv đọc số run mỗi phút từ biến môi trường, 0 là không giới hạn
+func runQuotaFromEnv(key string, fallback int) int {
+	quota, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || quota < 0 {
+		return fallback
+	}
+	return quota
+}
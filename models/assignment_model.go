@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AssignmentStudentFile mô tả một file sinh viên phải upload cho assignment
+type AssignmentStudentFile struct {
+	FormKey    string   `json:"form_key"`           // Key trong form-data, ví dụ cpp_file
+	FileName   string   `json:"file_name"`          // Tên file khi chạy trên Jobe, ví dụ hcmcampaign.cpp
+	IDSuffix   string   `json:"id_suffix"`          // File ID trên Jobe kết thúc bằng id_suffix, xem services.StudentFileID
+	Extensions []string `json:"extensions"`         // Các đuôi file được chấp nhận, ví dụ [".cpp"]
+	Optional   bool     `json:"optional,omitempty"` // Sinh viên có thể không nộp file này
+}
+
+// AssignmentSystemFile là file hệ thống (harness) đã có sẵn trên Jobe
+type AssignmentSystemFile struct {
+	FileID   string `json:"file_id"`
+	FileName string `json:"file_name"`
+}
+
+// Assignment là cấu hình build/chạy của một bài tập lớn
+type Assignment struct {
+	ID               uuid.UUID               `json:"id" gorm:"type:uuid;primaryKey"`
+	Name             string                  `json:"name" gorm:"type:varchar(255);not null"`
+	Subject          string                  `json:"subject" gorm:"type:varchar(255);not null;default:KTLT"`
+	LanguageID       string                  `json:"language_id" gorm:"type:varchar(50);not null"`
+	SourceFilename   string                  `json:"source_filename" gorm:"type:varchar(255)"`
+	Headers          string                  `json:"headers" gorm:"type:text"` // Đoạn #include chèn trước code của testcase
+	StudentFiles     []AssignmentStudentFile `json:"student_files" gorm:"type:text;serializer:json"`
+	SystemFiles      []AssignmentSystemFile  `json:"system_files" gorm:"type:text;serializer:json"`
+	CompileArgs      []string                `json:"compile_args" gorm:"type:text;serializer:json"`
+	LinkArgs         []string                `json:"link_args" gorm:"type:text;serializer:json"`
+	InputFilename    string                  `json:"input_filename" gorm:"type:varchar(255)"`      // Tên file chứa input của testcase, ví dụ config.txt
+	MaxExecutionTime int                     `json:"max_execution_time" gorm:"type:int;default:0"` // 0 là dùng mặc định của khóa học
+	MaxMemoryUsage   int                     `json:"max_memory_usage" gorm:"type:int;default:0"`   // 0 là dùng mặc định của khóa học
+	MaxFileSize      int64                   `json:"max_file_size" gorm:"type:bigint;default:204800"`
+	PolicyRules      []PolicyRule            `json:"policy_rules" gorm:"type:text;serializer:json"`     // Luật kiểm tra tĩnh khi sinh viên upload
+	ExtraExtensions  []string                `json:"extra_extensions" gorm:"type:text;serializer:json"` // Đuôi của file nộp thêm ngoài danh sách student_files, rỗng là không cho nộp thêm
+	MaxExtraFiles    int                     `json:"max_extra_files" gorm:"type:int;default:0"`         // 0 là dùng mặc định
+	CreatedBy        string                  `json:"created_by" gorm:"type:varchar(100)"`
+	CreatedAt        time.Time               `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt        time.Time               `json:"updated_at" gorm:"autoUpdateTime"`
+}
@@ -0,0 +1,17 @@
+package models
+
+// SuspectedExpected là testcase mà đa số sinh viên chạy xong cùng ra một output khác expected,
+// nhiều khả năng expected của testcase bị sai
+type SuspectedExpected struct {
+	TestcaseID       string      `json:"testcase_id"`
+	Name             string      `json:"name,omitempty"`
+	Position         int         `json:"position"`
+	Expected         string      `json:"expected"`
+	MajorityOutput   string      `json:"majority_output"`
+	Truncated        bool        `json:"truncated,omitempty"` // MajorityOutput đã bị cắt bớt khi lưu
+	AgreeingStudents int         `json:"agreeing_students"`   // Số sinh viên ra đúng MajorityOutput
+	FinishedStudents int         `json:"finished_students"`   // Số sinh viên có lần chạy mới nhất chạy xong (AC/WA)
+	TotalStudents    int         `json:"total_students"`      // Số sinh viên đã chạy testcase
+	Agreement        float64     `json:"agreement"`           // AgreeingStudents / FinishedStudents
+	Diff             *OutputDiff `json:"diff,omitempty"`      // Khác biệt giữa expected và MajorityOutput
+}
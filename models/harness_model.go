@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// HarnessVersion là một phiên bản bộ file hệ thống (main.h, main.cpp, tc.h, ...) của assignment.
+// Mỗi assignment có tối đa một phiên bản active, nội dung phiên bản không bị sửa sau khi tạo.
+type HarnessVersion struct {
+	ID           uuid.UUID `json:"id" gorm:"type:uuid;primaryKey"`
+	AssignmentID uuid.UUID `json:"assignment_id" gorm:"type:uuid;not null;uniqueIndex:idx_harness_version"` // uuid.Nil là assignment mặc định
+	Version      int       `json:"version" gorm:"type:int;not null;uniqueIndex:idx_harness_version"`
+	Active       bool      `json:"active" gorm:"not null;default:false"`
+	Note         string    `json:"note,omitempty" gorm:"type:text"`
+	CreatedBy    string    `json:"created_by" gorm:"type:varchar(100)"`
+	CreatedAt    time.Time `json:"created_at" gorm:"autoCreateTime"`
+
+	Files []HarnessFile `json:"files" gorm:"foreignKey:HarnessID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+}
+
+// HarnessFile là một file trong phiên bản harness, FileID trên Jobe do server sinh ra
+type HarnessFile struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey"`
+	HarnessID uuid.UUID `json:"harness_id" gorm:"type:uuid;not null;index"`
+	FileID    string    `json:"file_id" gorm:"type:varchar(100);not null;uniqueIndex"`
+	FileName  string    `json:"file_name" gorm:"type:varchar(255);not null"`
+	Content   []byte    `json:"-" gorm:"type:bytea;not null"`
+	SHA256    string    `json:"sha256" gorm:"column:sha256;type:char(64);not null"`
+	Size      int64     `json:"size" gorm:"type:bigint"`
+}
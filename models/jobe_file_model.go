@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Các phạm vi của file được upload qua proxy /jobe/files
+const (
+	FileScopeUser     = "user"     // File riêng của một người dùng
+	FileScopeTestcase = "testcase" // File gắn với một testcase, chỉ tác giả post hoặc giáo viên được ghi
+	FileScopeSystem   = "system"   // File hệ thống dùng chung, chỉ giáo viên được ghi
+)
+
+// JobeFile là file được upload qua proxy, ID trên Jobe do server sinh ra nên
+// người gọi không thể ghi đè file của phạm vi khác
+type JobeFile struct {
+	ID         string     `json:"file_id" gorm:"type:varchar(100);primaryKey"`
+	Scope      string     `json:"scope" gorm:"type:varchar(20);not null;uniqueIndex:idx_jobe_file_name"`
+	Owner      string     `json:"owner,omitempty" gorm:"type:varchar(100);not null;default:'';uniqueIndex:idx_jobe_file_name"` // Mail của user hoặc ID testcase, rỗng với file hệ thống
+	Name       string     `json:"name" gorm:"type:varchar(255);not null;uniqueIndex:idx_jobe_file_name"`
+	TestcaseID *uuid.UUID `json:"testcase_id,omitempty" gorm:"type:uuid"`
+	Content    []byte     `json:"-" gorm:"type:bytea;not null"`
+	SHA256     string     `json:"sha256" gorm:"column:sha256;type:char(64);not null"`
+	Size       int64      `json:"size" gorm:"type:bigint"`
+	CreatedBy  string     `json:"created_by" gorm:"type:varchar(100)"`
+	CreatedAt  time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt  time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+
+	Testcase *Testcase `json:"-" gorm:"foreignKey:TestcaseID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+}
@@ -1,10 +1,11 @@
 package models
 
 type FileUploadResponse struct {
-	Success bool   `json:"success"`
-	FileID  string `json:"file_id,omitempty"`
-	Message string `json:"message,omitempty"`
-	Error   string `json:"error,omitempty"`
+	Success    bool              `json:"success"`
+	FileID     string            `json:"file_id,omitempty"`
+	Message    string            `json:"message,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	Violations []PolicyViolation `json:"violations,omitempty"` // Vi phạm luật kiểm tra tĩnh của assignment
 }
 
 type UploadFileRequest struct {
@@ -35,11 +36,48 @@ type SubmitRunRequest struct {
 
 // SubmitRunResponse biểu diễn response từ Jobe server
 type SubmitRunResponse struct {
-	Status int    `json:"status"`
-	Result string `json:"result,omitempty"`
-	Error  string `json:"error,omitempty"`
-	Score  int    `json:"score"`
-	Log    string `json:"log,omitempty"`
+	Status        int              `json:"status"`
+	Result        string           `json:"result,omitempty"`
+	Error         string           `json:"error,omitempty"`
+	Score         int              `json:"score"`             // 1 nếu pass toàn bộ testcase
+	Verdict       Verdict          `json:"verdict,omitempty"` // Verdict của testcase đại diện
+	Log           string           `json:"log,omitempty"`
+	Diff          *OutputDiff      `json:"diff,omitempty"`   // Diff của testcase đại diện
+	RunID         string           `json:"run_id,omitempty"` // ID của run trong hàng đợi khi Jobe trả về 202
+	SubmissionID  string           `json:"submission_id,omitempty"`
+	UploadID      string           `json:"upload_id,omitempty"`  // Phiên bản code đã chạy
+	HarnessID     string           `json:"harness_id,omitempty"` // Phiên bản harness đã dùng
+	Mode          RunMode          `json:"mode,omitempty"`
+	WeightedScore float64          `json:"weighted_score"`
+	MaxScore      float64          `json:"max_score"`
+	Cases         []TestcaseResult `json:"cases,omitempty"`
+}
+
+// TestcaseResult là kết quả chạy của một testcase trong SubmitRunResponse
+type TestcaseResult struct {
+	TestcaseID string             `json:"testcase_id"`
+	Name       string             `json:"name,omitempty"`
+	Position   int                `json:"position"`
+	Weight     float64            `json:"weight"`
+	State      string             `json:"state"` // done hoặc queued
+	Score      int                `json:"score"`
+	Verdict    Verdict            `json:"verdict,omitempty"`
+	Result     string             `json:"result,omitempty"`
+	Log        string             `json:"log,omitempty"`
+	Diff       *OutputDiff        `json:"diff,omitempty"`
+	RunID      string             `json:"run_id,omitempty"`
+	Cached     bool               `json:"cached,omitempty"`   // Kết quả lấy từ cache, không chạy lại trên Jobe
+	Findings   []SanitizerFinding `json:"findings,omitempty"` // Lỗi bộ nhớ khi chạy ở chế độ memcheck
+	Limits     RunLimits          `json:"limits"`             // Giới hạn đã gửi cho Jobe, dùng để giải thích TLE/MLE
+}
+
+// OutputDiff là khác biệt theo dòng giữa expected và stdout của một testcase
+type OutputDiff struct {
+	Unified      string `json:"unified"`
+	FirstLine    int    `json:"first_line"`   // Dòng đầu tiên khác nhau, bắt đầu từ 1
+	FirstColumn  int    `json:"first_column"` // Ký tự đầu tiên khác nhau trên dòng đó, bắt đầu từ 1
+	ExpectedLine string `json:"expected_line"`
+	ActualLine   string `json:"actual_line"`
 }
 
 type JobeRunResult struct {
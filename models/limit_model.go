@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// CourseLimitID là ID của dòng duy nhất trong bảng course_limits
+const CourseLimitID = 1
+
+// CourseLimit là giới hạn chạy chung của khóa học: giá trị mặc định khi testcase và
+// assignment không đặt giới hạn, và mức tối đa do giáo viên quy định
+type CourseLimit struct {
+	ID                      int       `json:"-" gorm:"type:int;primaryKey"`
+	DefaultMaxExecutionTime int       `json:"default_max_execution_time" gorm:"type:int;not null;default:5"`
+	DefaultMaxMemoryUsage   int       `json:"default_max_memory_usage" gorm:"type:int;not null;default:1000000"`
+	MaxExecutionTime        int       `json:"max_execution_time" gorm:"type:int;not null;default:30"`
+	MaxMemoryUsage          int       `json:"max_memory_usage" gorm:"type:int;not null;default:4000000"`
+	UpdatedBy               string    `json:"updated_by,omitempty" gorm:"type:varchar(100)"`
+	UpdatedAt               time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// RunLimits là giới hạn thực tế được gửi cho Jobe khi chạy một testcase,
+// gửi đi dưới dạng tham số cputime (giây) và memorylimit (MB)
+type RunLimits struct {
+	MaxExecutionTime int `json:"max_execution_time"` // Giây
+	MaxMemoryUsage   int `json:"max_memory_usage"`   // KB, 0 là không giới hạn
+}
@@ -0,0 +1,38 @@
+package models
+
+// Các loại luật kiểm tra tĩnh code của sinh viên, xem package policy
+const (
+	PolicyForbiddenInclude    = "forbidden_include"    // Pattern là tên header, ví dụ bits/stdc++.h
+	PolicyForbiddenIdentifier = "forbidden_identifier" // Pattern là tên, thêm "(" ở cuối để chỉ cấm lời gọi hàm, ví dụ system(
+	PolicyRequiredSignature   = "required_signature"   // Pattern là khai báo phải có, khoảng trắng không quan trọng
+	PolicyMaxLines            = "max_lines"            // Limit là số dòng tối đa
+	PolicyMaxSize             = "max_size"             // Limit là số byte tối đa
+)
+
+// Mức độ của luật: error làm upload bị từ chối, warning chỉ đánh dấu upload
+const (
+	PolicySeverityError   = "error"
+	PolicySeverityWarning = "warning"
+)
+
+// PolicyRule là một luật kiểm tra tĩnh của assignment
+type PolicyRule struct {
+	Type     string   `json:"type"`
+	Pattern  string   `json:"pattern,omitempty"`
+	Limit    int      `json:"limit,omitempty"`
+	Files    []string `json:"files,omitempty"` // Form key của các file áp dụng luật, để trống là mọi file
+	Severity string   `json:"severity"`
+	Message  string   `json:"message,omitempty"` // Thông báo cho sinh viên, để trống sẽ dùng thông báo mặc định
+}
+
+// PolicyViolation là một vi phạm luật trong file của sinh viên
+type PolicyViolation struct {
+	Rule     string `json:"rule"`
+	Pattern  string `json:"pattern,omitempty"`
+	Severity string `json:"severity"`
+	FormKey  string `json:"form_key"`
+	FileName string `json:"file_name"`
+	Line     int    `json:"line,omitempty"` // Bắt đầu từ 1, 0 nếu vi phạm không gắn với dòng nào
+	Column   int    `json:"column,omitempty"`
+	Message  string `json:"message"`
+}
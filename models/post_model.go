@@ -7,32 +7,60 @@ import (
 )
 
 type Post struct {
-	ID             uuid.UUID    `json:"id" gorm:"type:uuid;primaryKey"`
-	UserMail       string       `json:"mail" gorm:"type:varchar(100);not null"`
-	Subject        string       `json:"subject" gorm:"type:varchar(255);not null"`
-	Title          string       `json:"title" gorm:"type:varchar(255);not null"`
-	Description    string       `json:"description" gorm:"type:text;not null"`
-	LastModified   time.Time    `json:"last_modified" gorm:"autoCreateTime"`
-	CreatedAt      time.Time    `json:"created_at" gorm:"autoCreateTime"`
-	Trace          string       `json:"-" gorm:"type:varchar(255)"`
-	PostStatus     string       `json:"-" gorm:"type:string;default:active"`
-	Views          int          `json:"-" gorm:"type:int;default:0"`
-	ViewsByRandom  int          `json:"-" gorm:"type:int;default:0"`
-	ViewsBySuggest int          `json:"-" gorm:"type:int;default:0"`
-	ViewsBySearch  int          `json:"-" gorm:"type:int;default:0"`
-	ViewsByRelated int          `json:"-" gorm:"type:int;default:0"`
-	Runs           int          `json:"-" gorm:"type:int;default:0"`
-	RunsBySuggest  int          `json:"-" gorm:"type:int;default:0"`
-	Testcase       *Testcase    `json:"testcase" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
-	Tags           []PostHasTag `json:"tags" gorm:"foreignKey:PostID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	ID                 uuid.UUID    `json:"id" gorm:"type:uuid;primaryKey"`
+	UserMail           string       `json:"mail" gorm:"type:varchar(100);not null"`
+	Subject            string       `json:"subject" gorm:"type:varchar(255);not null"`
+	Title              string       `json:"title" gorm:"type:varchar(255);not null"`
+	Description        string       `json:"description" gorm:"type:text;not null"`
+	LastModified       time.Time    `json:"last_modified" gorm:"autoCreateTime"`
+	CreatedAt          time.Time    `json:"created_at" gorm:"autoCreateTime"`
+	Trace              string       `json:"-" gorm:"type:varchar(255)"`
+	PostStatus         string       `json:"-" gorm:"type:string;default:active"`
+	Views              int          `json:"-" gorm:"type:int;default:0"`
+	ViewsByRandom      int          `json:"-" gorm:"type:int;default:0"`
+	ViewsBySuggest     int          `json:"-" gorm:"type:int;default:0"`
+	ViewsBySearch      int          `json:"-" gorm:"type:int;default:0"`
+	ViewsByRelated     int          `json:"-" gorm:"type:int;default:0"`
+	Runs               int          `json:"-" gorm:"type:int;default:0"`
+	RunsBySuggest      int          `json:"-" gorm:"type:int;default:0"`
+	AssignmentID       *uuid.UUID   `json:"assignment_id,omitempty" gorm:"type:uuid"`
+	Flaky              bool         `json:"flaky" gorm:"default:false"` // Có testcase cho output khác nhau khi chạy lặp lại
+	StabilityCheckedAt *time.Time   `json:"stability_checked_at,omitempty"`
+	Assignment         *Assignment  `json:"-" gorm:"foreignKey:AssignmentID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL"`
+	Testcases          []Testcase   `json:"testcases" gorm:"foreignKey:PostID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	Tags               []PostHasTag `json:"tags" gorm:"foreignKey:PostID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
 }
 
+// Testcase là một bộ input/expected/code của post, một post có thể có nhiều testcase theo thứ tự Position
 type Testcase struct {
-	PostID   uuid.UUID `json:"post_id" gorm:"type:uuid;primaryKey"`
+	ID       uuid.UUID `json:"id" gorm:"type:uuid;primaryKey"`
+	PostID   uuid.UUID `json:"post_id" gorm:"type:uuid;not null;index"`
+	Position int       `json:"position" gorm:"type:int;not null;default:0"`
+	Name     string    `json:"name,omitempty" gorm:"type:varchar(255)"`
+	Weight   float64   `json:"weight" gorm:"type:double precision;not null;default:1"`
 	Input    string    `json:"input" gorm:"type:text;not null"`
 	Expected string    `json:"expected" gorm:"type:text;not null"`
 	Code     string    `json:"code" gorm:"type:text;not null"`
 
+	// Cách so sánh output với expected, xem package comparator
+	CompareMode     string  `json:"compare_mode" gorm:"type:varchar(30);not null;default:exact"`
+	AbsoluteEpsilon float64 `json:"absolute_epsilon,omitempty" gorm:"type:double precision;default:0"`
+	RelativeEpsilon float64 `json:"relative_epsilon,omitempty" gorm:"type:double precision;default:0"`
+
+	// Giới hạn riêng của testcase, nil là dùng giới hạn của assignment hoặc khóa học
+	MaxExecutionTime *int `json:"max_execution_time,omitempty" gorm:"type:int"`
+	MaxMemoryUsage   *int `json:"max_memory_usage,omitempty" gorm:"type:int"`
+
+	// Kết quả chạy testcase với lời giải mẫu của assignment khi tạo post
+	ReferenceStatus  string      `json:"reference_status,omitempty" gorm:"type:varchar(30)"`
+	ReferenceVerdict Verdict     `json:"reference_verdict,omitempty" gorm:"type:varchar(20)"`
+	ReferenceLog     string      `json:"reference_log,omitempty" gorm:"type:text"`
+	ReferenceDiff    *OutputDiff `json:"reference_diff,omitempty" gorm:"type:text;serializer:json"`
+
+	// Kết quả kiểm tra ổn định gần nhất: testcase cho output khác nhau khi chạy lặp lại
+	Flaky        bool          `json:"flaky,omitempty" gorm:"default:false"`
+	FlakySamples []FlakySample `json:"flaky_samples,omitempty" gorm:"type:text;serializer:json"`
+
 	Post *Post `json:"-" gorm:"foreignKey:PostID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
 }
 
@@ -48,15 +76,28 @@ type Interaction struct {
 }
 
 type StudentRunTestcase struct {
-	ID          uuid.UUID `json:"id" gorm:"type:uuid;primaryKey"`
-	PostID      uuid.UUID `json:"post_id" gorm:"type:uuid"`
-	StudentMail string    `json:"student_mail" gorm:"type:varchar(100);primaryKey"`
-	Log         string    `json:"log" gorm:"type:text;not null"`
-	Score       int       `json:"score" gorm:"type:int"`
-	Time        time.Time `json:"time" gorm:"autoCreateTime"`
-
-	Post    *Post `json:"-" gorm:"foreignKey:PostID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
-	Student *User `json:"-" gorm:"foreignKey:StudentMail;references:Mail;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	ID              uuid.UUID          `json:"id" gorm:"type:uuid;primaryKey"`
+	PostID          uuid.UUID          `json:"post_id" gorm:"type:uuid"`
+	TestcaseID      *uuid.UUID         `json:"testcase_id,omitempty" gorm:"type:uuid;index"`
+	SubmissionID    *uuid.UUID         `json:"submission_id,omitempty" gorm:"type:uuid;index"` // Các testcase chạy trong cùng một lần bấm run
+	UploadID        *uuid.UUID         `json:"upload_id,omitempty" gorm:"type:uuid;index"`     // Phiên bản code đã chạy
+	HarnessID       *uuid.UUID         `json:"harness_id,omitempty" gorm:"type:uuid;index"`    // Phiên bản harness đã dùng
+	StudentMail     string             `json:"student_mail" gorm:"type:varchar(100);primaryKey"`
+	Log             string             `json:"log" gorm:"type:text;not null"`
+	Score           int                `json:"score" gorm:"type:int"`
+	Verdict         Verdict            `json:"verdict" gorm:"type:varchar(20);index"`
+	Weight          float64            `json:"weight" gorm:"type:double precision;default:1"`
+	Diff            *OutputDiff        `json:"diff,omitempty" gorm:"type:text;serializer:json"`     // Chỉ có khi output sai
+	Cached          bool               `json:"cached" gorm:"default:false"`                         // Kết quả lấy từ cache chạy
+	Findings        []SanitizerFinding `json:"findings,omitempty" gorm:"type:text;serializer:json"` // Lỗi bộ nhớ khi chạy ở chế độ memcheck, không ảnh hưởng verdict
+	Stdout          string             `json:"-" gorm:"type:text"`                                  // Output đã chuẩn hóa, chỉ lưu khi chương trình chạy xong (AC/WA)
+	StdoutHash      string             `json:"-" gorm:"type:varchar(64);index"`                     // SHA-256 của Stdout trước khi cắt bớt, dùng để gom output giống nhau
+	StdoutTruncated bool               `json:"-" gorm:"default:false"`                              // Stdout đã bị cắt bớt khi lưu
+	Time            time.Time          `json:"time" gorm:"autoCreateTime"`
+
+	Post     *Post     `json:"-" gorm:"foreignKey:PostID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	Testcase *Testcase `json:"-" gorm:"foreignKey:TestcaseID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	Student  *User     `json:"-" gorm:"foreignKey:StudentMail;references:Mail;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
 }
 
 type TeacherVerifyPost struct {
@@ -100,6 +141,7 @@ type InteractionInfo struct {
 	VerifiedTeacherMail *string    `json:"verified_teacher_mail"`
 	Views               int        `json:"view_count"` // Số lượt xem
 	Runs                int        `json:"run_count"`  // Số lượt chạy
+	Passed              bool       `json:"passed"`     // User hiện tại đã pass mọi testcase của post
 }
 
 type PostStats struct {
@@ -110,4 +152,11 @@ type PostStats struct {
 	VerifiedTeacherMail *string    `gorm:"column:verified_teacher_mail"`
 	Views               int        `gorm:"column:views"`
 	Runs                int        `gorm:"column:runs"`
+	TestcaseCount       int64      `gorm:"column:testcase_count"`
+	PassedCount         int64      `gorm:"column:passed_count"` // Số testcase user đã từng chạy đúng
+}
+
+// Passed cho biết user đã từng chạy đúng mọi testcase của post
+func (s PostStats) Passed() bool {
+	return s.TestcaseCount > 0 && s.PassedCount == s.TestcaseCount
 }
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Kết quả kiểm tra testcase bằng lời giải mẫu của assignment
+const (
+	ReferenceStatusUnchecked    = "unchecked"        // Chưa có lời giải mẫu hoặc chưa chạy được trên Jobe
+	ReferenceStatusPassed       = "passes_reference" // Output của lời giải mẫu khớp expected
+	ReferenceStatusFailed       = "fails_reference"  // Lời giải mẫu chạy xong nhưng không khớp expected
+	ReferenceStatusCompileError = "does_not_compile" // Code của testcase không biên dịch được với lời giải mẫu
+)
+
+// ReferenceSolution là lời giải mẫu của giáo viên cho một assignment, mỗi assignment có tối đa một lời giải.
+// Upload lại sẽ thay toàn bộ file và file ID trên Jobe.
+type ReferenceSolution struct {
+	ID           uuid.UUID `json:"id" gorm:"type:uuid;primaryKey"`
+	AssignmentID uuid.UUID `json:"assignment_id" gorm:"type:uuid;not null;uniqueIndex"` // uuid.Nil là assignment mặc định
+	UploadedBy   string    `json:"uploaded_by" gorm:"type:varchar(100)"`
+	CreatedAt    time.Time `json:"created_at" gorm:"autoCreateTime"`
+
+	Files []ReferenceFile `json:"files" gorm:"foreignKey:ReferenceID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+}
+
+// ReferenceFile là file của lời giải mẫu ứng với một file sinh viên (theo form key) của assignment
+type ReferenceFile struct {
+	ID          uuid.UUID `json:"id" gorm:"type:uuid;primaryKey"`
+	ReferenceID uuid.UUID `json:"reference_id" gorm:"type:uuid;not null;index"`
+	FormKey     string    `json:"form_key" gorm:"type:varchar(100);not null"`
+	FileID      string    `json:"-" gorm:"type:varchar(100);not null;uniqueIndex"` // Không trả về để sinh viên không đọc được lời giải qua Jobe
+	FileName    string    `json:"file_name" gorm:"type:varchar(255);not null"`
+	Content     []byte    `json:"-" gorm:"type:bytea;not null"`
+	SHA256      string    `json:"sha256" gorm:"column:sha256;type:char(64);not null"`
+	Size        int64     `json:"size" gorm:"type:bigint"`
+}
+
+// ReferenceCheck là kết quả chạy một testcase với lời giải mẫu
+type ReferenceCheck struct {
+	TestcaseID string      `json:"testcase_id"`
+	Name       string      `json:"name,omitempty"`
+	Position   int         `json:"position"`
+	Status     string      `json:"status"`
+	Verdict    Verdict     `json:"verdict,omitempty"`
+	Log        string      `json:"log,omitempty"`
+	Diff       *OutputDiff `json:"diff,omitempty"`
+}
+
+// ExpectedPreview là output của lời giải mẫu cho một testcase, dùng làm expected khi tạo post
+type ExpectedPreview struct {
+	Position  int     `json:"position"`
+	Name      string  `json:"name,omitempty"`
+	Generated bool    `json:"generated"` // Lời giải mẫu chạy thành công và Expected dùng được
+	Expected  string  `json:"expected"`
+	Verdict   Verdict `json:"verdict,omitempty"`
+	Log       string  `json:"log,omitempty"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RunCacheEntry là kết quả đã chấm của một testcase với một bộ input cố định.
+// Key là hash của file sinh viên, testcase, harness và run_spec nên khi bất kỳ input nào
+// thay đổi thì key cũng đổi và entry cũ không còn được dùng.
+type RunCacheEntry struct {
+	Key        string             `json:"key" gorm:"type:char(64);primaryKey"`
+	TestcaseID uuid.UUID          `json:"testcase_id" gorm:"type:uuid;not null;index"`
+	HarnessID  *uuid.UUID         `json:"harness_id,omitempty" gorm:"type:uuid;index"`
+	Stdout     string             `json:"stdout" gorm:"type:text"`
+	Score      int                `json:"score" gorm:"type:int"`
+	Verdict    Verdict            `json:"verdict" gorm:"type:varchar(20)"`
+	Log        string             `json:"log" gorm:"type:text"`
+	Diff       *OutputDiff        `json:"diff,omitempty" gorm:"type:text;serializer:json"`
+	Findings   []SanitizerFinding `json:"findings,omitempty" gorm:"type:text;serializer:json"`
+	Hits       int                `json:"hits" gorm:"type:int;default:0"`
+	CreatedAt  time.Time          `json:"created_at" gorm:"autoCreateTime"`
+	LastHitAt  *time.Time         `json:"last_hit_at,omitempty"`
+
+	Testcase *Testcase `json:"-" gorm:"foreignKey:TestcaseID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+}
@@ -0,0 +1,100 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Các trạng thái của một run trong hàng đợi
+const (
+	RunStateQueued  = "queued"
+	RunStateRunning = "running"
+	RunStateDone    = "done"
+	RunStateFailed  = "failed"
+)
+
+// Run là một lần chạy code được lưu lại để worker gửi tới Jobe,
+// dùng khi Jobe trả về 202 hoặc đang quá tải
+type Run struct {
+	ID            uuid.UUID          `json:"id" gorm:"type:uuid;primaryKey"`
+	PostID        uuid.UUID          `json:"post_id" gorm:"type:uuid;not null"`
+	TestcaseID    *uuid.UUID         `json:"testcase_id,omitempty" gorm:"type:uuid"`
+	SubmissionID  *uuid.UUID         `json:"submission_id,omitempty" gorm:"type:uuid"`
+	UploadID      *uuid.UUID         `json:"upload_id,omitempty" gorm:"type:uuid"`  // Phiên bản code đã chạy
+	HarnessID     *uuid.UUID         `json:"harness_id,omitempty" gorm:"type:uuid"` // Phiên bản harness đã dùng
+	StudentMail   string             `json:"student_mail" gorm:"type:varchar(100);not null;index"`
+	State         string             `json:"state" gorm:"type:varchar(20);not null;default:queued;index"`
+	RunSpec       RunSpec            `json:"-" gorm:"type:text;serializer:json;not null"`
+	Attempts      int                `json:"attempts" gorm:"type:int;default:0"`
+	NextAttemptAt time.Time          `json:"-" gorm:"index"`
+	Error         string             `json:"error,omitempty" gorm:"type:text"`
+	Result        string             `json:"result,omitempty" gorm:"type:text"`
+	Score         int                `json:"score" gorm:"type:int;default:0"`
+	Verdict       Verdict            `json:"verdict,omitempty" gorm:"type:varchar(20)"`
+	Log           string             `json:"log,omitempty" gorm:"type:text"`
+	Diff          *OutputDiff        `json:"diff,omitempty" gorm:"type:text;serializer:json"`
+	Findings      []SanitizerFinding `json:"findings,omitempty" gorm:"type:text;serializer:json"` // Lỗi bộ nhớ khi chạy ở chế độ memcheck
+	StudentRunID  *uuid.UUID         `json:"student_run_id,omitempty" gorm:"type:uuid"`
+	CreatedAt     time.Time          `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt     time.Time          `json:"updated_at" gorm:"autoUpdateTime"`
+
+	Post    *Post `json:"-" gorm:"foreignKey:PostID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	Student *User `json:"-" gorm:"foreignKey:StudentMail;references:Mail;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+}
+
+// RunQueueStats là độ sâu hàng đợi run và số run đang gửi tới Jobe, dùng cho giám sát
+type RunQueueStats struct {
+	Queued       int64 `json:"queued"`
+	Running      int64 `json:"running"`
+	Workers      int   `json:"workers"`
+	JobeInFlight int   `json:"jobe_in_flight"`
+	JobeCapacity int   `json:"jobe_capacity"` // 0 là không giới hạn
+}
+
+// RunHistoryPage là một trang lịch sử chạy testcase của sinh viên
+type RunHistoryPage struct {
+	Runs     []StudentRunTestcase `json:"runs"`
+	Total    int64                `json:"total"`
+	Page     int                  `json:"page"`
+	PageSize int                  `json:"page_size"`
+}
+
+// BestResult là lần nộp có điểm cao nhất của sinh viên trên một post
+type BestResult struct {
+	PostID        uuid.UUID `json:"post_id" gorm:"column:post_id"`
+	SubmissionID  uuid.UUID `json:"submission_id" gorm:"column:submission_id"`
+	WeightedScore float64   `json:"weighted_score" gorm:"column:weighted_score"`
+	MaxScore      float64   `json:"max_score" gorm:"column:max_score"`
+	Passed        bool      `json:"passed" gorm:"column:passed"`     // Mọi testcase trong lần nộp đều AC
+	Attempts      int64     `json:"attempts" gorm:"column:attempts"` // Số lần nộp trên post
+	BestRunAt     time.Time `json:"best_run_at" gorm:"column:best_run_at"`
+	LastRunAt     time.Time `json:"last_run_at" gorm:"column:last_run_at"`
+}
+
+// Các giai đoạn của một lần chạy code gửi qua stream tiến trình
+const (
+	RunStageFilesChecked  = "files_checked"  // Đã kiểm tra file của sinh viên trên Jobe
+	RunStageFilesUploaded = "files_uploaded" // Đã upload lại file bị Jobe xóa khỏi cache
+	RunStageSubmitted     = "submitted"      // Đã gửi testcase tới Jobe
+	RunStageCompiled      = "compiled"       // Jobe đã biên dịch xong
+	RunStageCompared      = "compared"       // Đã so sánh output với expected
+	RunStageCached        = "cached"         // Dùng lại kết quả trong cache
+	RunStageQueued        = "queued"         // Jobe quá tải, testcase được đưa vào hàng đợi
+	RunStageResult        = "result"         // Kết quả cuối cùng
+	RunStageError         = "error"
+)
+
+// RunEvent là một event tiến trình của lần chạy code
+type RunEvent struct {
+	Stage      string             `json:"stage"`
+	TestcaseID string             `json:"testcase_id,omitempty"`
+	Index      int                `json:"index,omitempty"` // Thứ tự testcase trong lần chạy, bắt đầu từ 1
+	Total      int                `json:"total,omitempty"`
+	Files      []string           `json:"files,omitempty"`
+	Verdict    Verdict            `json:"verdict,omitempty"`
+	Message    string             `json:"message,omitempty"`
+	RunID      string             `json:"run_id,omitempty"`
+	Result     *SubmitRunResponse `json:"result,omitempty"`
+	Error      string             `json:"error,omitempty"`
+}
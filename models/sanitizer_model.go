@@ -0,0 +1,37 @@
+package models
+
+// RunMode là chế độ chạy testcase
+type RunMode string
+
+const (
+	RunModeNormal   RunMode = "normal"
+	RunModeMemcheck RunMode = "memcheck" // Biên dịch với AddressSanitizer/LeakSanitizer
+)
+
+// StackFrame là một frame trong stack trace của sanitizer
+type StackFrame struct {
+	Index    int    `json:"index"`
+	Function string `json:"function,omitempty"`
+	File     string `json:"file,omitempty"` // Chỉ giữ tên file, bỏ thư mục chạy trên Jobe
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+	Module   string `json:"module,omitempty"` // Thư viện chứa frame khi không có thông tin dòng
+}
+
+// SanitizerStack là một stack trace phụ của lỗi, ví dụ nơi vùng nhớ đã được cấp phát hoặc giải phóng
+type SanitizerStack struct {
+	Title  string       `json:"title"`
+	Frames []StackFrame `json:"frames"`
+}
+
+// SanitizerFinding là một lỗi bộ nhớ do AddressSanitizer hoặc LeakSanitizer báo khi chạy ở chế độ memcheck
+type SanitizerFinding struct {
+	Tool        string           `json:"tool"` // AddressSanitizer hoặc LeakSanitizer
+	Kind        string           `json:"kind"` // Ví dụ heap-use-after-free, direct-leak
+	Description string           `json:"description"`
+	Location    string           `json:"location,omitempty"` // file:line đầu tiên nằm trong code của bài
+	Bytes       int64            `json:"bytes,omitempty"`    // Số byte bị rò rỉ
+	Objects     int              `json:"objects,omitempty"`  // Số vùng nhớ bị rò rỉ
+	Frames      []StackFrame     `json:"frames"`
+	Related     []SanitizerStack `json:"related,omitempty"`
+}
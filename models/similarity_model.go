@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Fingerprint là một dấu vân tay winnowing của code cùng các dòng sinh ra nó
+type Fingerprint struct {
+	Hash      uint64 `json:"h"`
+	StartLine int    `json:"s"`
+	EndLine   int    `json:"e"`
+}
+
+// UploadFingerprint là dấu vân tay của một file trong phiên bản upload, tính một lần khi sinh viên upload
+type UploadFingerprint struct {
+	UploadFileID uuid.UUID     `json:"upload_file_id" gorm:"type:uuid;primaryKey"`
+	UploadID     uuid.UUID     `json:"upload_id" gorm:"type:uuid;not null;index"`
+	StudentMail  string        `json:"student_mail" gorm:"type:varchar(100);not null;index"`
+	AssignmentID *uuid.UUID    `json:"assignment_id,omitempty" gorm:"type:uuid;index"`
+	FormKey      string        `json:"form_key" gorm:"type:varchar(100)"`
+	Fingerprints []Fingerprint `json:"-" gorm:"type:text;serializer:json"`
+	CreatedAt    time.Time     `json:"created_at" gorm:"autoCreateTime"`
+
+	UploadFile *StudentUploadFile `json:"-" gorm:"foreignKey:UploadFileID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+}
+
+// MatchedRegion là một cặp vùng dòng giống nhau giữa file của hai sinh viên
+type MatchedRegion struct {
+	StartA int `json:"start_a"`
+	EndA   int `json:"end_a"`
+	StartB int `json:"start_b"`
+	EndB   int `json:"end_b"`
+}
+
+// SimilarityPair là một cặp sinh viên có file giống nhau vượt ngưỡng
+type SimilarityPair struct {
+	StudentA   string          `json:"student_a"`
+	StudentB   string          `json:"student_b"`
+	FormKey    string          `json:"form_key"`
+	VersionA   int             `json:"version_a"`
+	VersionB   int             `json:"version_b"`
+	Similarity float64         `json:"similarity"` // Từ 0 đến 1
+	Shared     int             `json:"shared"`     // Số dấu vân tay chung
+	Regions    []MatchedRegion `json:"regions"`
+}
+
+// SimilarityFile là file của một bên trong cặp giống nhau, dùng để tô sáng các vùng trùng
+type SimilarityFile struct {
+	StudentMail string `json:"student_mail"`
+	Version     int    `json:"version"`
+	FileName    string `json:"file_name"`
+	Content     string `json:"content"`
+}
+
+// SimilarityDetail là cặp giống nhau kèm nội dung hai file
+type SimilarityDetail struct {
+	SimilarityPair
+	FileA SimilarityFile `json:"file_a"`
+	FileB SimilarityFile `json:"file_b"`
+}
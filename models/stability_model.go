@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FlakySample là một output khác nhau khi chạy lặp lại testcase
+type FlakySample struct {
+	Verdict   Verdict `json:"verdict"`
+	Stdout    string  `json:"stdout"`
+	Truncated bool    `json:"truncated,omitempty"`
+	Count     int     `json:"count"` // Số lần chạy ra output này
+}
+
+// StabilityResult là kết quả chạy lặp lại một testcase
+type StabilityResult struct {
+	TestcaseID string        `json:"testcase_id"`
+	Name       string        `json:"name,omitempty"`
+	Position   int           `json:"position"`
+	Runs       int           `json:"runs"` // Số lần đã chạy xong
+	Flaky      bool          `json:"flaky"`
+	Samples    []FlakySample `json:"samples,omitempty"`
+	Diff       string        `json:"diff,omitempty"` // Unified diff giữa hai output thường gặp nhất
+	Error      string        `json:"error,omitempty"`
+}
+
+// StabilityReport là kết quả kiểm tra ổn định của các testcase trong post
+type StabilityReport struct {
+	PostID    uuid.UUID         `json:"post_id"`
+	Source    string            `json:"source"` // reference hoặc email sinh viên có bài nộp được dùng
+	Runs      int               `json:"runs"`
+	Flaky     bool              `json:"flaky"`
+	Testcases []StabilityResult `json:"testcases"`
+	CheckedAt time.Time         `json:"checked_at"`
+}
+
+// StabilityCheck là một lần kiểm tra ổn định chạy nền, client hỏi lại để lấy Report khi State là done.
+// State dùng các trạng thái RunState* của hàng đợi run.
+type StabilityCheck struct {
+	ID          uuid.UUID        `json:"id" gorm:"type:uuid;primaryKey"`
+	PostID      uuid.UUID        `json:"post_id" gorm:"type:uuid;not null;index;uniqueIndex:idx_stability_checks_running,where:state = 'running'"` // Mỗi post chỉ có một lần kiểm tra đang chạy
+	RequestedBy string           `json:"requested_by" gorm:"type:varchar(100);not null"`
+	Source      string           `json:"source" gorm:"type:varchar(100);not null"`
+	Runs        int              `json:"runs" gorm:"type:int;not null"`
+	State       string           `json:"state" gorm:"type:varchar(20);not null;default:running;index"`
+	Error       string           `json:"error,omitempty" gorm:"type:text"`
+	Report      *StabilityReport `json:"report,omitempty" gorm:"type:text;serializer:json"`
+	CreatedAt   time.Time        `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time        `json:"updated_at" gorm:"autoUpdateTime"`
+
+	Post *Post `json:"-" gorm:"foreignKey:PostID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// StudentFile là bản đang dùng của một file sinh viên, được lưu lại để
+// upload lại lên Jobe khi file cache của Jobe bị xóa
+type StudentFile struct {
+	FileID      string     `json:"file_id" gorm:"type:varchar(100);primaryKey"` // File ID trên Jobe, ví dụ <maso>cpp với assignment mặc định
+	StudentMail string     `json:"student_mail" gorm:"type:varchar(100);not null;index"`
+	FormKey     string     `json:"form_key" gorm:"type:varchar(100)"`
+	FileName    string     `json:"file_name" gorm:"type:varchar(255)"` // Tên file gốc sinh viên upload
+	Content     []byte     `json:"-" gorm:"type:bytea;not null"`
+	SHA256      string     `json:"sha256" gorm:"column:sha256;type:char(64);not null"`
+	Size        int64      `json:"size" gorm:"type:bigint"`
+	UploadedAt  time.Time  `json:"uploaded_at" gorm:"not null"`
+	UploadID    *uuid.UUID `json:"upload_id,omitempty" gorm:"type:uuid"` // Phiên bản upload đang được dùng
+	Version     int        `json:"version"`
+
+	Student *User `json:"-" gorm:"foreignKey:StudentMail;references:Mail;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+}
+
+// StudentUpload là một phiên bản code của sinh viên gồm mọi file của assignment
+// trong một lần upload. Phiên bản không bị sửa sau khi tạo.
+type StudentUpload struct {
+	ID           uuid.UUID         `json:"id" gorm:"type:uuid;primaryKey"`
+	StudentMail  string            `json:"student_mail" gorm:"type:varchar(100);not null;uniqueIndex:idx_student_upload_version"`
+	Version      int               `json:"version" gorm:"type:int;not null;uniqueIndex:idx_student_upload_version"` // Tăng dần theo từng sinh viên
+	AssignmentID *uuid.UUID        `json:"assignment_id,omitempty" gorm:"type:uuid"`
+	Active       bool              `json:"active" gorm:"-"`
+	Warnings     []PolicyViolation `json:"warnings,omitempty" gorm:"type:text;serializer:json"` // Vi phạm mức warning khi upload
+	CreatedAt    time.Time         `json:"created_at" gorm:"autoCreateTime"`
+
+	Files   []StudentUploadFile `json:"files" gorm:"foreignKey:UploadID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	Student *User               `json:"-" gorm:"foreignKey:StudentMail;references:Mail;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+}
+
+// StudentUploadFile là nội dung một file trong một phiên bản upload
+type StudentUploadFile struct {
+	ID       uuid.UUID `json:"id" gorm:"type:uuid;primaryKey"`
+	UploadID uuid.UUID `json:"upload_id" gorm:"type:uuid;not null;index"`
+	FileID   string    `json:"file_id" gorm:"type:varchar(100);not null"`
+	FormKey  string    `json:"form_key" gorm:"type:varchar(100)"`
+	FileName string    `json:"file_name" gorm:"type:varchar(255)"`
+	Content  []byte    `json:"-" gorm:"type:bytea;not null"`
+	SHA256   string    `json:"sha256" gorm:"column:sha256;type:char(64);not null"`
+	Size     int64     `json:"size" gorm:"type:bigint"`
+}
+
+// UploadFileDiff là khác biệt của một file giữa hai phiên bản upload
+type UploadFileDiff struct {
+	FormKey  string `json:"form_key"`
+	FileName string `json:"file_name"`
+	Changed  bool   `json:"changed"`
+	Unified  string `json:"unified,omitempty"`
+}
@@ -0,0 +1,50 @@
+package models
+
+// Verdict là kết luận chấm của một testcase, suy ra từ outcome, cmpinfo và stderr của Jobe
+type Verdict string
+
+const (
+	VerdictAccepted     Verdict = "AC"            // Output đúng
+	VerdictWrongAnswer  Verdict = "WA"            // Chạy xong nhưng output sai
+	VerdictCompileError Verdict = "CE"            // Lỗi biên dịch
+	VerdictRuntimeError Verdict = "RE"            // Lỗi khi chạy hoặc có ghi ra stderr
+	VerdictTimeLimit    Verdict = "TLE"           // Quá thời gian
+	VerdictMemoryLimit  Verdict = "MLE"           // Quá bộ nhớ
+	VerdictIllegal      Verdict = "ILLEGAL"       // Gọi system call bị cấm
+	VerdictSandboxError Verdict = "SANDBOX_ERROR" // Lỗi nội bộ của Jobe
+	VerdictOverload     Verdict = "OVERLOAD"      // Jobe quá tải
+)
+
+// Description trả về mô tả dễ đọc của verdict
+func (v Verdict) Description() string {
+	switch v {
+	case VerdictAccepted:
+		return "Accepted"
+	case VerdictWrongAnswer:
+		return "Wrong answer"
+	case VerdictCompileError:
+		return "Compilation error"
+	case VerdictRuntimeError:
+		return "Runtime error"
+	case VerdictTimeLimit:
+		return "Time limit exceeded"
+	case VerdictMemoryLimit:
+		return "Memory limit exceeded"
+	case VerdictIllegal:
+		return "Illegal system call"
+	case VerdictOverload:
+		return "Server overload"
+	default:
+		return "Sandbox error"
+	}
+}
+
+// IsValid cho biết chuỗi có phải một verdict hợp lệ hay không
+func (v Verdict) IsValid() bool {
+	switch v {
+	case VerdictAccepted, VerdictWrongAnswer, VerdictCompileError, VerdictRuntimeError, VerdictTimeLimit,
+		VerdictMemoryLimit, VerdictIllegal, VerdictSandboxError, VerdictOverload:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,157 @@
+package policy
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/tison2810/be-go-tc/models"
+)
+
+var identifierRule = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(::[A-Za-z_][A-Za-z0-9_]*)*\(?$`)
+
+// forbiddenIdentifier tìm tên bị cấm trong code, pattern kết thúc bằng "(" chỉ bắt lời gọi hàm
+func (s *source) forbiddenIdentifier(pattern string) []models.PolicyViolation {
+	name := strings.TrimSuffix(pattern, "(")
+	expr := `\b` + regexp.QuoteMeta(name) + `\b`
+	if name != pattern {
+		expr += `\s*\(`
+	}
+	re := regexp.MustCompile(expr)
+
+	var violations []models.PolicyViolation
+	for i, line := range s.code {
+		for _, match := range re.FindAllStringIndex(line, -1) {
+			violations = append(violations, models.PolicyViolation{
+				Line:    i + 1,
+				Column:  match[0] + 1,
+				Message: fmt.Sprintf("%s is not allowed", pattern),
+			})
+		}
+	}
+	return violations
+}
+
+// requiredSignature kiểm tra code có chứa khai báo, khoảng trắng giữa các token không quan trọng
+func (s *source) requiredSignature(pattern string) []models.PolicyViolation {
+	re, err := signaturePattern(pattern)
+	if err != nil {
+		return []models.PolicyViolation{{Message: fmt.Sprintf("invalid signature %q", pattern)}}
+	}
+	if re.MatchString(strings.Join(s.code, "\n")) {
+		return nil
+	}
+	return []models.PolicyViolation{{
+		Message: fmt.Sprintf("required signature %s was not found", strings.Join(strings.Fields(pattern), " ")),
+	}}
+}
+
+// signaturePattern chuyển khai báo thành regex: các token được nối bằng \s*,
+// hai tên đứng cạnh nhau phải cách nhau ít nhất một khoảng trắng
+func signaturePattern(signature string) (*regexp.Regexp, error) {
+	var tokens []string
+	var current strings.Builder
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, current.String())
+			current.Reset()
+		}
+	}
+	for _, r := range signature {
+		switch {
+		case unicode.IsSpace(r):
+			flush()
+		case isWordRune(r):
+			current.WriteRune(r)
+		default:
+			flush()
+			tokens = append(tokens, string(r))
+		}
+	}
+	flush()
+
+	var b strings.Builder
+	for i, token := range tokens {
+		if i > 0 {
+			if isWordRune(rune(tokens[i-1][len(tokens[i-1])-1])) && isWordRune(rune(token[0])) {
+				b.WriteString(`\s+`)
+			} else {
+				b.WriteString(`\s*`)
+			}
+		}
+		b.WriteString(regexp.QuoteMeta(token))
+	}
+	expr := b.String()
+	if len(tokens) > 0 && isWordRune(rune(tokens[0][0])) {
+		expr = `\b` + expr
+	}
+	return regexp.Compile(expr)
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// stripCommentsAndStrings thay comment và nội dung chuỗi, ký tự bằng khoảng trắng,
+// giữ nguyên ký tự xuống dòng để số dòng và cột không đổi
+func stripCommentsAndStrings(code string) string {
+	out := []byte(code)
+	const (
+		normal = iota
+		lineComment
+		blockComment
+		stringLiteral
+		charLiteral
+	)
+	state := normal
+	for i := 0; i < len(out); i++ {
+		c := out[i]
+		switch state {
+		case normal:
+			switch {
+			case c == '/' && i+1 < len(out) && out[i+1] == '/':
+				state = lineComment
+				out[i], out[i+1] = ' ', ' '
+				i++
+			case c == '/' && i+1 < len(out) && out[i+1] == '*':
+				state = blockComment
+				out[i], out[i+1] = ' ', ' '
+				i++
+			case c == '"':
+				state = stringLiteral
+			case c == '\'':
+				state = charLiteral
+			}
+		case lineComment:
+			if c == '\n' {
+				state = normal
+			} else {
+				out[i] = ' '
+			}
+		case blockComment:
+			if c == '*' && i+1 < len(out) && out[i+1] == '/' {
+				out[i], out[i+1] = ' ', ' '
+				i++
+				state = normal
+			} else if c != '\n' {
+				out[i] = ' '
+			}
+		case stringLiteral, charLiteral:
+			quote := byte('"')
+			if state == charLiteral {
+				quote = '\''
+			}
+			switch {
+			case c == '\\' && i+1 < len(out) && out[i+1] != '\n':
+				out[i], out[i+1] = ' ', ' '
+				i++
+			case c == quote || c == '\n':
+				state = normal
+			default:
+				out[i] = ' '
+			}
+		}
+	}
+	return string(out)
+}
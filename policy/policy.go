@@ -0,0 +1,158 @@
+// Package policy kiểm tra tĩnh code của sinh viên theo các luật của assignment
+// (header và tên bị cấm, khai báo bắt buộc, giới hạn số dòng và kích thước).
+package policy
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/tison2810/be-go-tc/models"
+)
+
+var includePattern = regexp.MustCompile(`^\s*#\s*include\s*([<"])([^>"]+)[>"]`)
+
+// Validate kiểm tra cấu hình luật trước khi lưu vào assignment
+func Validate(rules []models.PolicyRule) error {
+	for i, rule := range rules {
+		switch rule.Severity {
+		case models.PolicySeverityError, models.PolicySeverityWarning:
+		default:
+			return fmt.Errorf("policy rule %d: severity must be %q or %q", i, models.PolicySeverityError, models.PolicySeverityWarning)
+		}
+		switch rule.Type {
+		case models.PolicyForbiddenInclude, models.PolicyRequiredSignature:
+			if strings.TrimSpace(rule.Pattern) == "" {
+				return fmt.Errorf("policy rule %d: %s requires pattern", i, rule.Type)
+			}
+		case models.PolicyForbiddenIdentifier:
+			if !identifierRule.MatchString(rule.Pattern) {
+				return fmt.Errorf("policy rule %d: %s pattern must be an identifier, optionally followed by (", i, rule.Type)
+			}
+		case models.PolicyMaxLines, models.PolicyMaxSize:
+			if rule.Limit <= 0 {
+				return fmt.Errorf("policy rule %d: %s requires a positive limit", i, rule.Type)
+			}
+		default:
+			return fmt.Errorf("policy rule %d: unknown type %q", i, rule.Type)
+		}
+	}
+	return nil
+}
+
+// Check áp dụng các luật lên một file của sinh viên và trả về các vi phạm theo thứ tự luật rồi tới dòng
+func Check(rules []models.PolicyRule, formKey, fileName string, content []byte) []models.PolicyViolation {
+	source := newSource(content)
+	var violations []models.PolicyViolation
+	for _, rule := range rules {
+		if !appliesTo(rule, formKey) {
+			continue
+		}
+		for _, found := range source.check(rule) {
+			found.Rule = rule.Type
+			found.Pattern = rule.Pattern
+			found.Severity = rule.Severity
+			found.FormKey = formKey
+			found.FileName = fileName
+			if rule.Message != "" {
+				found.Message = rule.Message
+			}
+			violations = append(violations, found)
+		}
+	}
+	return violations
+}
+
+// HasErrors cho biết có vi phạm nào ở mức error hay không
+func HasErrors(violations []models.PolicyViolation) bool {
+	for _, violation := range violations {
+		if violation.Severity == models.PolicySeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+func appliesTo(rule models.PolicyRule, formKey string) bool {
+	if len(rule.Files) == 0 {
+		return true
+	}
+	for _, key := range rule.Files {
+		if key == formKey {
+			return true
+		}
+	}
+	return false
+}
+
+// source giữ nội dung gốc và bản đã xóa comment, chuỗi để tìm tên mà không bắt nhầm
+type source struct {
+	raw   []byte
+	lines []string // Dòng gốc, dùng để đọc #include
+	code  []string // Dòng đã thay comment và nội dung chuỗi bằng khoảng trắng, giữ nguyên vị trí cột
+}
+
+func newSource(content []byte) *source {
+	normalized := bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+	return &source{
+		raw:   content,
+		lines: strings.Split(string(normalized), "\n"),
+		code:  strings.Split(stripCommentsAndStrings(string(normalized)), "\n"),
+	}
+}
+
+func (s *source) check(rule models.PolicyRule) []models.PolicyViolation {
+	switch rule.Type {
+	case models.PolicyForbiddenInclude:
+		return s.forbiddenInclude(rule.Pattern)
+	case models.PolicyForbiddenIdentifier:
+		return s.forbiddenIdentifier(rule.Pattern)
+	case models.PolicyRequiredSignature:
+		return s.requiredSignature(rule.Pattern)
+	case models.PolicyMaxLines:
+		return s.maxLines(rule.Limit)
+	case models.PolicyMaxSize:
+		if len(s.raw) > rule.Limit {
+			return []models.PolicyViolation{{
+				Message: fmt.Sprintf("file is %d bytes, limit is %d bytes", len(s.raw), rule.Limit),
+			}}
+		}
+	}
+	return nil
+}
+
+func (s *source) forbiddenInclude(pattern string) []models.PolicyViolation {
+	header := strings.Trim(strings.TrimSpace(pattern), `<>"`)
+	var violations []models.PolicyViolation
+	for i, line := range s.lines {
+		// Dòng #include nằm trong comment đã bị xóa ở bản code
+		if strings.TrimSpace(s.code[i]) == "" {
+			continue
+		}
+		match := includePattern.FindStringSubmatchIndex(line)
+		if match == nil || strings.TrimSpace(line[match[4]:match[5]]) != header {
+			continue
+		}
+		violations = append(violations, models.PolicyViolation{
+			Line:    i + 1,
+			Column:  strings.Index(line, "#") + 1,
+			Message: fmt.Sprintf("#include <%s> is not allowed", header),
+		})
+	}
+	return violations
+}
+
+func (s *source) maxLines(limit int) []models.PolicyViolation {
+	count := len(s.lines)
+	if count > 0 && s.lines[count-1] == "" {
+		count-- // Dòng trống sau ký tự xuống dòng cuối cùng
+	}
+	if count <= limit {
+		return nil
+	}
+	return []models.PolicyViolation{{
+		Line:    limit + 1,
+		Message: fmt.Sprintf("file has %d lines, limit is %d lines", count, limit),
+	}}
+}
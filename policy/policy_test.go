@@ -0,0 +1,189 @@
+package policy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tison2810/be-go-tc/models"
+)
+
+func rule(ruleType, pattern string) models.PolicyRule {
+	return models.PolicyRule{Type: ruleType, Pattern: pattern, Severity: models.PolicySeverityError}
+}
+
+// positions trả về các vị trí dòng:cột của vi phạm để so sánh gọn
+func positions(violations []models.PolicyViolation) [][2]int {
+	var result [][2]int
+	for _, violation := range violations {
+		result = append(result, [2]int{violation.Line, violation.Column})
+	}
+	return result
+}
+
+func equalPositions(a, b [][2]int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestForbiddenInclude(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want [][2]int
+	}{
+		{"angle brackets", "#include <bits/stdc++.h>\nint main() {}", [][2]int{{1, 1}}},
+		{"quotes and spaces", "int x;\n  #  include \"bits/stdc++.h\"", [][2]int{{2, 3}}},
+		{"CRLF", "#include <iostream>\r\n#include <bits/stdc++.h>\r\n", [][2]int{{2, 1}}},
+		{"trailing comment", "#include <bits/stdc++.h> // needed", [][2]int{{1, 1}}},
+		{"line comment", "// #include <bits/stdc++.h>\nint main() {}", nil},
+		{"block comment", "/*\n#include <bits/stdc++.h>\n*/\nint main() {}", nil},
+		{"other header", "#include <bits/stdc++11.h>\n#include <vector>", nil},
+		{"inside string", "const char *s = \"#include <bits/stdc++.h>\";", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Check([]models.PolicyRule{rule(models.PolicyForbiddenInclude, "<bits/stdc++.h>")}, "cpp_file", "a.cpp", []byte(tt.code))
+			if !equalPositions(positions(got), tt.want) {
+				t.Errorf("violations at %v, want %v: %+v", positions(got), tt.want, got)
+			}
+		})
+	}
+}
+
+func TestForbiddenIdentifier(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		code    string
+		want    [][2]int
+	}{
+		{"call", "system(", `int main() { system ("ls"); }`, [][2]int{{1, 14}}},
+		{"call only", "system(", "int system_id = 1;\nint system = 2;", nil},
+		{"any use", "goto", "void f() {\n  goto end;\nend:;\n}", [][2]int{{2, 3}}},
+		{"whole word", "vector", "myvector v; vectors w;", nil},
+		{"qualified name", "std::sort(", "std::sort(a, a + n);", [][2]int{{1, 1}}},
+		{"line comment", "system(", "// system(\"ls\");\nint x;", nil},
+		{"block comment", "system(", "/* system(\"ls\"); */ int x;", nil},
+		{"string literal", "system(", `puts("system(\"ls\")");`, nil},
+		{"char literal does not leak", "system(", "char c = '\"'; system(\"x\");", [][2]int{{1, 15}}},
+		{"several matches", "malloc(", "p = malloc(1);\nq = malloc(2); r = malloc(3);", [][2]int{{1, 5}, {2, 5}, {2, 20}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Check([]models.PolicyRule{rule(models.PolicyForbiddenIdentifier, tt.pattern)}, "cpp_file", "a.cpp", []byte(tt.code))
+			if !equalPositions(positions(got), tt.want) {
+				t.Errorf("violations at %v, want %v: %+v", positions(got), tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRequiredSignature(t *testing.T) {
+	signature := "int Army::getLF() const"
+	tests := []struct {
+		name  string
+		code  string
+		found bool
+	}{
+		{"exact", "int Army::getLF() const { return lf; }", true},
+		{"different spacing", "int\nArmy :: getLF ( )const{}", true},
+		{"missing const", "int Army::getLF() { return lf; }", false},
+		{"glued words", "intArmy::getLF() const {}", false},
+		{"only in comment", "// int Army::getLF() const\n", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Check([]models.PolicyRule{rule(models.PolicyRequiredSignature, signature)}, "cpp_file", "a.cpp", []byte(tt.code))
+			if found := len(got) == 0; found != tt.found {
+				t.Errorf("signature found = %v, want %v: %+v", found, tt.found, got)
+			}
+		})
+	}
+}
+
+func TestLimits(t *testing.T) {
+	code := []byte("a\nb\nc\n")
+	maxLines := models.PolicyRule{Type: models.PolicyMaxLines, Limit: 3, Severity: models.PolicySeverityWarning}
+	if got := Check([]models.PolicyRule{maxLines}, "k", "f", code); len(got) != 0 {
+		t.Errorf("3 lines with limit 3: %+v", got)
+	}
+	maxLines.Limit = 2
+	got := Check([]models.PolicyRule{maxLines}, "k", "f", code)
+	if len(got) != 1 || got[0].Line != 3 || got[0].Severity != models.PolicySeverityWarning {
+		t.Errorf("3 lines with limit 2: %+v", got)
+	}
+
+	maxSize := models.PolicyRule{Type: models.PolicyMaxSize, Limit: len(code), Severity: models.PolicySeverityError}
+	if got := Check([]models.PolicyRule{maxSize}, "k", "f", code); len(got) != 0 {
+		t.Errorf("size at limit: %+v", got)
+	}
+	maxSize.Limit--
+	if got := Check([]models.PolicyRule{maxSize}, "k", "f", code); len(got) != 1 || !HasErrors(got) {
+		t.Errorf("size over limit: %+v", got)
+	}
+}
+
+func TestCheckFillsViolation(t *testing.T) {
+	rules := []models.PolicyRule{
+		{Type: models.PolicyForbiddenIdentifier, Pattern: "exit(", Severity: models.PolicySeverityWarning, Message: "do not call exit"},
+		{Type: models.PolicyForbiddenIdentifier, Pattern: "abort(", Severity: models.PolicySeverityError, Files: []string{"h_file"}},
+	}
+	got := Check(rules, "cpp_file", "hcmcampaign.cpp", []byte("exit(1); abort();"))
+	if len(got) != 1 {
+		t.Fatalf("got %d violations, want 1 (abort rule only applies to h_file): %+v", len(got), got)
+	}
+	want := models.PolicyViolation{
+		Rule:     models.PolicyForbiddenIdentifier,
+		Pattern:  "exit(",
+		Severity: models.PolicySeverityWarning,
+		FormKey:  "cpp_file",
+		FileName: "hcmcampaign.cpp",
+		Line:     1,
+		Column:   1,
+		Message:  "do not call exit",
+	}
+	if got[0] != want {
+		t.Errorf("violation = %+v, want %+v", got[0], want)
+	}
+	if HasErrors(got) {
+		t.Error("HasErrors = true for warnings only")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    models.PolicyRule
+		wantErr string
+	}{
+		{"valid include", rule(models.PolicyForbiddenInclude, "bits/stdc++.h"), ""},
+		{"valid call", rule(models.PolicyForbiddenIdentifier, "std::system("), ""},
+		{"missing severity", models.PolicyRule{Type: models.PolicyMaxLines, Limit: 1}, "severity"},
+		{"empty include", rule(models.PolicyForbiddenInclude, "  "), "requires pattern"},
+		{"identifier with spaces", rule(models.PolicyForbiddenIdentifier, "system ("), "identifier"},
+		{"identifier regex", rule(models.PolicyForbiddenIdentifier, ".*"), "identifier"},
+		{"zero limit", models.PolicyRule{Type: models.PolicyMaxSize, Severity: models.PolicySeverityError}, "positive limit"},
+		{"unknown type", models.PolicyRule{Type: "max_depth", Severity: models.PolicySeverityError}, "unknown type"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate([]models.PolicyRule{tt.rule})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package sanitizer
+
+import (
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/tison2810/be-go-tc/models"
+)
+
+const (
+	MaxFindings = 20 // Báo cáo dài hơn chỉ giữ các lỗi đầu tiên
+	MaxFrames   = 16 // Số frame tối đa giữ lại cho mỗi stack
+)
+
+var (
+	headerPattern    = regexp.MustCompile(`^==\d+==ERROR: (AddressSanitizer|LeakSanitizer): (.*)$`)
+	leakPattern      = regexp.MustCompile(`^(Direct|Indirect) leak of (\d+) byte\(s\) in (\d+) object\(s\) allocated from:$`)
+	summaryPattern   = regexp.MustCompile(`^SUMMARY: (?:AddressSanitizer|LeakSanitizer): (\S+)`)
+	framePattern     = regexp.MustCompile(`^#(\d+)\s+0x[0-9a-fA-F]+(?:\s+in\s+(.+?))?\s+(?:\((\S+?)\+0x[0-9a-fA-F]+\)|(\S+?):(\d+)(?::(\d+))?)$`)
+	runtimeLine      = regexp.MustCompile(`^==\d+==`)
+	separatorPattern = regexp.MustCompile(`^=+$`)
+)
+
+// Parse tách báo cáo của sanitizer khỏi stderr, trả về các lỗi đã đọc được và phần stderr còn lại của chương trình
+func Parse(stderr string) ([]models.SanitizerFinding, string) {
+	var (
+		findings []models.SanitizerFinding
+		rest     []string
+		current  *models.SanitizerFinding
+		stack    *[]models.StackFrame
+		inReport bool
+		inShadow bool
+		leaks    bool
+	)
+	for _, line := range strings.Split(strings.TrimRight(stderr, "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		if match := headerPattern.FindStringSubmatch(trimmed); match != nil {
+			inReport, inShadow = true, false
+			leaks = match[1] == "LeakSanitizer" || strings.HasPrefix(match[2], "detected memory leaks")
+			// Dòng === ngay trước header thuộc về báo cáo
+			if len(rest) > 0 && separatorPattern.MatchString(strings.TrimSpace(rest[len(rest)-1])) {
+				rest = rest[:len(rest)-1]
+			}
+			current, stack = nil, nil
+			if !leaks {
+				findings = append(findings, models.SanitizerFinding{
+					Tool:        match[1],
+					Kind:        errorKind(match[2]),
+					Description: match[2],
+				})
+				current = &findings[len(findings)-1]
+				stack = &current.Frames
+			}
+			continue
+		}
+		if inShadow {
+			// Bảng shadow memory in sau SUMMARY của AddressSanitizer
+			if trimmed == "" || strings.HasPrefix(trimmed, "Shadow") || strings.HasPrefix(trimmed, "=>") || strings.HasPrefix(line, " ") {
+				continue
+			}
+			inShadow = false
+		}
+		if !inReport {
+			if !runtimeLine.MatchString(trimmed) {
+				rest = append(rest, line)
+			}
+			continue
+		}
+
+		switch {
+		case summaryPattern.MatchString(trimmed):
+			// Kind trong SUMMARY chính xác hơn header, ví dụ double-free thay cho attempting
+			if current != nil && !leaks {
+				current.Kind = strings.TrimSuffix(summaryPattern.FindStringSubmatch(trimmed)[1], ":")
+			}
+			inShadow = !leaks
+			inReport, current, stack = false, nil, nil
+		case leaks && leakPattern.MatchString(trimmed):
+			match := leakPattern.FindStringSubmatch(trimmed)
+			bytes, _ := strconv.ParseInt(match[2], 10, 64)
+			objects, _ := strconv.Atoi(match[3])
+			findings = append(findings, models.SanitizerFinding{
+				Tool:        "LeakSanitizer",
+				Kind:        strings.ToLower(match[1]) + "-leak",
+				Description: strings.TrimSuffix(trimmed, " allocated from:"),
+				Bytes:       bytes,
+				Objects:     objects,
+			})
+			current = &findings[len(findings)-1]
+			stack = &current.Frames
+		case framePattern.MatchString(trimmed):
+			if stack != nil && len(*stack) < MaxFrames {
+				*stack = append(*stack, parseFrame(framePattern.FindStringSubmatch(trimmed)))
+			}
+		case current != nil && !leaks && strings.HasSuffix(trimmed, ":") && strings.Contains(trimmed, " by "):
+			// Stack phụ như "freed by thread T0 here:" hoặc "previously allocated by thread T0 here:"
+			current.Related = append(current.Related, models.SanitizerStack{Title: strings.TrimSuffix(trimmed, ":")})
+			stack = &current.Related[len(current.Related)-1].Frames
+		}
+	}
+
+	if len(findings) > MaxFindings {
+		findings = findings[:MaxFindings]
+	}
+	for i := range findings {
+		finding := &findings[i]
+		finding.Location = userLocation(finding.Frames)
+		trimDirs(finding.Frames)
+		for j := range finding.Related {
+			trimDirs(finding.Related[j].Frames)
+		}
+	}
+	return findings, strings.TrimSpace(strings.Join(rest, "\n"))
+}
+
+// errorKind lấy loại lỗi từ header, ví dụ "heap-use-after-free on address ..." là heap-use-after-free
+func errorKind(description string) string {
+	description = strings.TrimPrefix(description, "attempting ")
+	if kind, _, ok := strings.Cut(description, " "); ok {
+		return kind
+	}
+	return description
+}
+
+func parseFrame(match []string) models.StackFrame {
+	frame := models.StackFrame{Function: match[2]}
+	frame.Index, _ = strconv.Atoi(match[1])
+	if match[3] != "" {
+		frame.Module = path.Base(match[3])
+		return frame
+	}
+	frame.File = match[4]
+	frame.Line, _ = strconv.Atoi(match[5])
+	frame.Column, _ = strconv.Atoi(match[6])
+	return frame
+}
+
+// userLocation trả về file:line của frame đầu tiên nằm trong code của bài, bỏ qua thư viện hệ thống
+func userLocation(frames []models.StackFrame) string {
+	for _, frame := range frames {
+		// Runtime của sanitizer và glibc được build với đường dẫn tương đối như ../../../../src/libsanitizer
+		if frame.File == "" || strings.HasPrefix(frame.File, "/usr/") || strings.HasPrefix(frame.File, "../") ||
+			strings.Contains(frame.File, "libsanitizer") || strings.Contains(frame.File, "sanitizer_common") {
+			continue
+		}
+		return path.Base(frame.File) + ":" + strconv.Itoa(frame.Line)
+	}
+	return ""
+}
+
+// trimDirs chỉ giữ tên file trong frame vì thư mục chạy trên Jobe thay đổi theo từng lần chạy
+func trimDirs(frames []models.StackFrame) {
+	for i := range frames {
+		if frames[i].File != "" {
+			frames[i].File = path.Base(frames[i].File)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package sanitizer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tison2810/be-go-tc/models"
+)
+
+// Báo cáo thật của g++ -fsanitize=address với halt_on_error=0, rút gọn đường dẫn
+const useAfterFreeReport = `Army: LIBERATIONARMY
+=================================================================
+==31337==ERROR: AddressSanitizer: heap-use-after-free on address 0x602000000010 at pc 0x55d4c2a1b3c5 bp 0x7ffd2c8e4a30 sp 0x7ffd2c8e4a20
+READ of size 4 at 0x602000000010 thread T0
+    #0 0x55d4c2a1b3c4 in Unit::getAttackScore() /home/jobe/runs/jobe_a1b2c3/hcmcampaign.cpp:42
+    #1 0x55d4c2a1c8f1 in Army::fight(Army*, bool) /home/jobe/runs/jobe_a1b2c3/hcmcampaign.cpp:118
+    #2 0x55d4c2a1a2b7 in main /home/jobe/runs/jobe_a1b2c3/tc.cpp:15
+    #3 0x7f3b1c229d8f in __libc_start_call_main ../sysdeps/nptl/libc_start_call_main.h:58
+    #4 0x7f3b1c229e3f in __libc_start_main_impl ../csu/libc-start.c:392
+    #5 0x55d4c2a1a124 in _start (/home/jobe/runs/jobe_a1b2c3/prog+0x2124)
+
+0x602000000010 is located 0 bytes inside of 4-byte region [0x602000000010,0x602000000014)
+freed by thread T0 here:
+    #0 0x7f3b1c6b724f in operator delete(void*, unsigned long) ../../../../src/libsanitizer/asan/asan_new_delete.cpp:172
+    #1 0x55d4c2a1c5d2 in UnitList::remove(Unit*) /home/jobe/runs/jobe_a1b2c3/hcmcampaign.cpp:87
+    #2 0x55d4c2a1a2a1 in main /home/jobe/runs/jobe_a1b2c3/tc.cpp:14
+
+previously allocated by thread T0 here:
+    #0 0x7f3b1c6b61e7 in operator new(unsigned long) ../../../../src/libsanitizer/asan/asan_new_delete.cpp:99
+    #1 0x55d4c2a1b871 in UnitList::insert(Unit*) /home/jobe/runs/jobe_a1b2c3/hcmcampaign.cpp:63
+    #2 0x55d4c2a1a27d in main /home/jobe/runs/jobe_a1b2c3/tc.cpp:12
+
+SUMMARY: AddressSanitizer: heap-use-after-free /home/jobe/runs/jobe_a1b2c3/hcmcampaign.cpp:42 in Unit::getAttackScore()
+Shadow bytes around the buggy address:
+  0x0c047fff7fb0: 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00
+  0x0c047fff7fc0: 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00
+=>0x0c047fff8000: fa fa[fd]fa fa fa fd fa fa fa fd fa fa fa fa fa
+  0x0c047fff8010: fa fa fa fa fa fa fa fa fa fa fa fa fa fa fa fa
+Shadow byte legend (one shadow byte represents 8 application bytes):
+  Addressable:           00
+  Partially addressable: 01 02 03 04 05 06 07 
+  Heap left redzone:       fa
+  Freed heap region:       fd
+==31337==ABORTING
+Battle result: 120`
+
+const leakReport = `Result: 7
+
+=================================================================
+==4242==ERROR: LeakSanitizer: detected memory leaks
+
+Direct leak of 24 byte(s) in 1 object(s) allocated from:
+    #0 0x7f8a2e4b61e7 in operator new(unsigned long) ../../../../src/libsanitizer/asan/asan_new_delete.cpp:99
+    #1 0x5623a1b0e4c2 in Army::Army(Unit**, int, std::string, BattleField*) /home/jobe/runs/jobe_d4e5f6/hcmcampaign.cpp:201
+    #2 0x5623a1b0d1f3 in main /home/jobe/runs/jobe_d4e5f6/tc.cpp:9
+    #3 0x7f8a2e029d8f in __libc_start_call_main ../sysdeps/nptl/libc_start_call_main.h:58
+
+Indirect leak of 64 byte(s) in 2 object(s) allocated from:
+    #0 0x7f8a2e4b61e7 in operator new(unsigned long) ../../../../src/libsanitizer/asan/asan_new_delete.cpp:99
+    #1 0x5623a1b0e9aa in UnitList::insert(Unit*) /home/jobe/runs/jobe_d4e5f6/hcmcampaign.cpp:63
+    #2 0x5623a1b0e51c in Army::Army(Unit**, int, std::string, BattleField*) /home/jobe/runs/jobe_d4e5f6/hcmcampaign.cpp:205
+
+SUMMARY: AddressSanitizer: 88 byte(s) leaked in 3 allocation(s).`
+
+func TestParseUseAfterFree(t *testing.T) {
+	findings, rest := Parse(useAfterFreeReport)
+	if len(findings) != 1 {
+		t.Fatalf("findings = %+v, want 1", findings)
+	}
+	finding := findings[0]
+	if finding.Tool != "AddressSanitizer" || finding.Kind != "heap-use-after-free" {
+		t.Errorf("tool/kind = %s/%s", finding.Tool, finding.Kind)
+	}
+	if !strings.HasPrefix(finding.Description, "heap-use-after-free on address 0x602000000010") {
+		t.Errorf("description = %q", finding.Description)
+	}
+	if finding.Location != "hcmcampaign.cpp:42" {
+		t.Errorf("location = %q, want hcmcampaign.cpp:42", finding.Location)
+	}
+
+	if len(finding.Frames) != 6 {
+		t.Fatalf("frames = %+v, want 6", finding.Frames)
+	}
+	first := finding.Frames[0]
+	if first.Index != 0 || first.Function != "Unit::getAttackScore()" || first.File != "hcmcampaign.cpp" || first.Line != 42 {
+		t.Errorf("frame 0 = %+v", first)
+	}
+	if last := finding.Frames[5]; last.Function != "_start" || last.Module != "prog" || last.File != "" {
+		t.Errorf("frame 5 = %+v", last)
+	}
+	for _, frame := range finding.Frames {
+		if strings.Contains(frame.File, "/") {
+			t.Errorf("frame %d keeps directory %q", frame.Index, frame.File)
+		}
+	}
+
+	if len(finding.Related) != 2 {
+		t.Fatalf("related = %+v, want 2", finding.Related)
+	}
+	if finding.Related[0].Title != "freed by thread T0 here" || len(finding.Related[0].Frames) != 3 {
+		t.Errorf("related 0 = %+v", finding.Related[0])
+	}
+	if finding.Related[1].Title != "previously allocated by thread T0 here" || finding.Related[1].Frames[1].Function != "UnitList::insert(Unit*)" {
+		t.Errorf("related 1 = %+v", finding.Related[1])
+	}
+
+	// Output của chương trình giữ nguyên, bảng shadow và dòng runtime bị bỏ
+	if rest != "Army: LIBERATIONARMY\nBattle result: 120" {
+		t.Errorf("rest = %q", rest)
+	}
+}
+
+func TestParseLeaks(t *testing.T) {
+	findings, rest := Parse(leakReport)
+	if len(findings) != 2 {
+		t.Fatalf("findings = %+v, want 2", findings)
+	}
+	direct, indirect := findings[0], findings[1]
+	if direct.Tool != "LeakSanitizer" || direct.Kind != "direct-leak" || direct.Bytes != 24 || direct.Objects != 1 {
+		t.Errorf("direct = %+v", direct)
+	}
+	if direct.Description != "Direct leak of 24 byte(s) in 1 object(s)" {
+		t.Errorf("direct description = %q", direct.Description)
+	}
+	if direct.Location != "hcmcampaign.cpp:201" || len(direct.Frames) != 4 {
+		t.Errorf("direct location = %q, %d frames", direct.Location, len(direct.Frames))
+	}
+	if indirect.Kind != "indirect-leak" || indirect.Bytes != 64 || indirect.Objects != 2 || indirect.Location != "hcmcampaign.cpp:63" {
+		t.Errorf("indirect = %+v", indirect)
+	}
+	if rest != "Result: 7" {
+		t.Errorf("rest = %q", rest)
+	}
+}
+
+func TestParseDoubleFreeKindFromSummary(t *testing.T) {
+	stderr := `==77==ERROR: AddressSanitizer: attempting double-free on 0x602000000010 in thread T0:
+    #0 0x7f3b1c6b724f in operator delete(void*) ../../../../src/libsanitizer/asan/asan_new_delete.cpp:160
+    #1 0x55d4c2a1c5d2 in Unit::~Unit() /tmp/jobe/hcmcampaign.cpp:30
+SUMMARY: AddressSanitizer: double-free ../../../../src/libsanitizer/asan/asan_new_delete.cpp:160 in operator delete(void*)`
+
+	findings, rest := Parse(stderr)
+	if len(findings) != 1 || findings[0].Kind != "double-free" || findings[0].Location != "hcmcampaign.cpp:30" {
+		t.Fatalf("findings = %+v", findings)
+	}
+	if rest != "" {
+		t.Errorf("rest = %q", rest)
+	}
+}
+
+func TestParseWithoutReport(t *testing.T) {
+	stderr := "warning: something\n==12==WARNING: runtime line\nterminate called after throwing an instance of 'std::out_of_range'"
+	findings, rest := Parse(stderr)
+	if findings != nil {
+		t.Errorf("findings = %+v, want none", findings)
+	}
+	if rest != "warning: something\nterminate called after throwing an instance of 'std::out_of_range'" {
+		t.Errorf("rest = %q", rest)
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	var b strings.Builder
+	for i := 0; i < MaxFindings+5; i++ {
+		b.WriteString("==1==ERROR: AddressSanitizer: heap-buffer-overflow on address 0x1\n")
+		for j := 0; j < MaxFrames+4; j++ {
+			b.WriteString("    #0 0x1 in f /tmp/a.cpp:1\n")
+		}
+		b.WriteString("SUMMARY: AddressSanitizer: heap-buffer-overflow /tmp/a.cpp:1 in f\n")
+	}
+
+	findings, _ := Parse(b.String())
+	if len(findings) != MaxFindings {
+		t.Fatalf("findings = %d, want %d", len(findings), MaxFindings)
+	}
+	for _, finding := range findings {
+		if len(finding.Frames) != MaxFrames {
+			t.Fatalf("frames = %d, want %d", len(finding.Frames), MaxFrames)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	runSpec := models.RunSpec{
+		LanguageID: "cpp",
+		SourceCode: "int main() {}",
+		Parameters: map[string]interface{}{"compileargs": []string{"-std=c++17"}, "memorylimit": 256},
+	}
+	Apply(&runSpec)
+
+	parameters := runSpec.Parameters.(map[string]interface{})
+	args := parameters["compileargs"].([]string)
+	if args[0] != "-std=c++17" || len(args) != 1+len(CompileArgs) {
+		t.Errorf("compileargs = %v", args)
+	}
+	if parameters["memorylimit"] != 0 {
+		t.Errorf("memorylimit = %v, want 0 so the shadow memory reservation is not killed", parameters["memorylimit"])
+	}
+	if !strings.Contains(runSpec.SourceCode, `extern "C" const char *__asan_default_options()`) {
+		t.Errorf("source = %q", runSpec.SourceCode)
+	}
+}
@@ -0,0 +1,34 @@
+// Package sanitizer biên dịch code với AddressSanitizer/LeakSanitizer cho chế độ memcheck
+// và đọc báo cáo lỗi bộ nhớ của chúng từ stderr.
+package sanitizer
+
+import "github.com/tison2810/be-go-tc/models"
+
+// CompileArgs là cờ biên dịch thêm vào compileargs của run_spec ở chế độ memcheck
+var CompileArgs = []string{"-fsanitize=address", "-fsanitize-recover=address", "-fno-omit-frame-pointer", "-g"}
+
+// defaultOptions được ghép vào cuối source để sanitizer báo lỗi mà không dừng chương trình
+// hay đổi exit code, nhờ vậy verdict vẫn được tính như khi chạy bình thường
+const defaultOptions = `const char *__asan_default_options() { return "halt_on_error=0:exitcode=0:detect_leaks=1"; }`
+
+// Apply thêm cờ sanitizer vào run_spec đã được tạo cho assignment và bỏ giới hạn bộ nhớ của Jobe
+// (memorylimit 0), vì AddressSanitizer dành trước vùng shadow memory rất lớn và sẽ bị dừng trước khi kịp báo lỗi
+func Apply(runSpec *models.RunSpec) {
+	parameters, ok := runSpec.Parameters.(map[string]interface{})
+	if !ok {
+		parameters = map[string]interface{}{}
+		runSpec.Parameters = parameters
+	}
+	var args []string
+	if existing, ok := parameters["compileargs"].([]string); ok {
+		args = append(args, existing...)
+	}
+	parameters["compileargs"] = append(args, CompileArgs...)
+	parameters["memorylimit"] = 0
+
+	options := defaultOptions
+	if runSpec.LanguageID != "c" {
+		options = `extern "C" ` + options
+	}
+	runSpec.SourceCode += "\n" + options + "\n"
+}
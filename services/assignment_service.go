@@ -0,0 +1,205 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/tison2810/be-go-tc/database"
+	"github.com/tison2810/be-go-tc/models"
+	"github.com/tison2810/be-go-tc/policy"
+)
+
+var idSuffixPattern = regexp.MustCompile(`^[A-Za-z0-9]+$`)
+
+// DefaultAssignment trả về cấu hình hcmcampaign dùng cho các post chưa gắn assignment
+func DefaultAssignment() models.Assignment {
+	return models.Assignment{
+		ID:             uuid.Nil,
+		Name:           "hcmcampaign",
+		Subject:        "KTLT",
+		LanguageID:     "cpp",
+		SourceFilename: "tc.cpp",
+		Headers: `#include "main.h"
+	#include "tc.h"
+	#include "hcmcampaign.h"
+
+	`,
+		StudentFiles: []models.AssignmentStudentFile{
+			{FormKey: "cpp_file", FileName: "hcmcampaign.cpp", IDSuffix: "cpp", Extensions: []string{".cpp"}},
+			{FormKey: "h_file", FileName: "hcmcampaign.h", IDSuffix: "h", Extensions: []string{".h"}},
+		},
+		SystemFiles: []models.AssignmentSystemFile{
+			{FileID: "systemmainh", FileName: "main.h"},
+			{FileID: "systemmaincpp", FileName: "main.cpp"},
+			{FileID: "systemtch", FileName: "tc.h"},
+		},
+		CompileArgs:   []string{"-I .", "-std=c++11"},
+		LinkArgs:      []string{"hcmcampaign.cpp", "main.cpp"},
+		InputFilename: "config.txt",
+		MaxFileSize:   200 * 1024,
+		PolicyRules: []models.PolicyRule{
+			{Type: models.PolicyForbiddenInclude, Pattern: "bits/stdc++.h", Severity: models.PolicySeverityError},
+			{Type: models.PolicyForbiddenIdentifier, Pattern: "system(", Severity: models.PolicySeverityError},
+		},
+	}
+}
+
+// GetAssignment lấy assignment theo ID, uuid.Nil trả về assignment mặc định
+func GetAssignment(id uuid.UUID) (models.Assignment, error) {
+	if id == uuid.Nil {
+		return DefaultAssignment(), nil
+	}
+	var assignment models.Assignment
+	if err := database.DB.Db.First(&assignment, "id = ?", id).Error; err != nil {
+		return models.Assignment{}, err
+	}
+	return assignment, nil
+}
+
+// GetAssignmentByParam lấy assignment từ chuỗi ID trong request, chuỗi rỗng là assignment mặc định
+func GetAssignmentByParam(id string) (models.Assignment, error) {
+	if id == "" {
+		return DefaultAssignment(), nil
+	}
+	assignmentID, err := uuid.Parse(id)
+	if err != nil {
+		return models.Assignment{}, fmt.Errorf("invalid assignment_id")
+	}
+	return GetAssignment(assignmentID)
+}
+
+// GetAssignmentForPost lấy assignment mà post tham chiếu tới
+func GetAssignmentForPost(postID uuid.UUID) (models.Assignment, error) {
+	var post models.Post
+	if err := database.DB.Db.Select("id", "assignment_id").First(&post, "id = ?", postID).Error; err != nil {
+		return models.Assignment{}, err
+	}
+	if post.AssignmentID == nil {
+		return DefaultAssignment(), nil
+	}
+	return GetAssignment(*post.AssignmentID)
+}
+
+// ListAssignments trả về các assignment đã tạo cùng assignment mặc định
+func ListAssignments() ([]models.Assignment, error) {
+	var assignments []models.Assignment
+	if err := database.DB.Db.Order("created_at").Find(&assignments).Error; err != nil {
+		return nil, err
+	}
+	return append([]models.Assignment{DefaultAssignment()}, assignments...), nil
+}
+
+// ValidateAssignment kiểm tra cấu hình assignment trước khi lưu
+func ValidateAssignment(assignment *models.Assignment) error {
+	assignment.Name = strings.TrimSpace(assignment.Name)
+	if assignment.Name == "" {
+		return errors.New("name is required")
+	}
+	if assignment.LanguageID == "" {
+		return errors.New("language_id is required")
+	}
+	if assignment.SourceFilename == "" {
+		return errors.New("source_filename is required")
+	}
+	if len(assignment.StudentFiles) == 0 {
+		return errors.New("at least one student file is required")
+	}
+
+	formKeys := make(map[string]bool)
+	suffixes := make(map[string]bool)
+	required := false
+	for _, file := range assignment.StudentFiles {
+		if file.FormKey == "" || file.FileName == "" {
+			return errors.New("student files require form_key and file_name")
+		}
+		if file.FormKey == ArchiveFormKey || file.FormKey == ExtraFilesFormKey {
+			return fmt.Errorf("form_key %s is reserved", file.FormKey)
+		}
+		if path.Base(file.FileName) != file.FileName {
+			return fmt.Errorf("invalid file_name %q", file.FileName)
+		}
+		if !idSuffixPattern.MatchString(file.IDSuffix) {
+			return fmt.Errorf("id_suffix of %s must be alphanumeric", file.FormKey)
+		}
+		if formKeys[file.FormKey] || suffixes[file.IDSuffix] {
+			return fmt.Errorf("duplicate student file %s", file.FormKey)
+		}
+		formKeys[file.FormKey] = true
+		suffixes[file.IDSuffix] = true
+		required = required || !file.Optional
+	}
+	if !required {
+		return errors.New("at least one student file must not be optional")
+	}
+	for _, ext := range assignment.ExtraExtensions {
+		if !strings.HasPrefix(ext, ".") || path.Base(ext) != ext {
+			return fmt.Errorf("invalid extra extension %q", ext)
+		}
+	}
+	if assignment.MaxExtraFiles < 0 {
+		return errors.New("max_extra_files must not be negative")
+	}
+	for _, file := range assignment.SystemFiles {
+		if file.FileID == "" || file.FileName == "" {
+			return errors.New("system files require file_id and file_name")
+		}
+	}
+
+	if assignment.Subject == "" {
+		assignment.Subject = "KTLT"
+	}
+	if assignment.MaxExecutionTime < 0 || assignment.MaxMemoryUsage < 0 {
+		return errors.New("limits must not be negative")
+	}
+	if assignment.MaxFileSize <= 0 {
+		assignment.MaxFileSize = DefaultAssignment().MaxFileSize
+	}
+	for _, rule := range assignment.PolicyRules {
+		for _, formKey := range rule.Files {
+			if !formKeys[formKey] {
+				return fmt.Errorf("policy rule %s applies to unknown student file %s", rule.Type, formKey)
+			}
+		}
+	}
+	return policy.Validate(assignment.PolicyRules)
+}
+
+// StudentFileID trả về file ID trên Jobe của một file sinh viên.
+// Assignment mặc định giữ dạng <maso><id_suffix> cũ, các assignment khác thêm phần băm ID assignment
+// để hai assignment dùng cùng id_suffix không ghi đè file của nhau.
+func StudentFileID(studentID string, assignment models.Assignment, file models.AssignmentStudentFile) string {
+	if assignment.ID == uuid.Nil {
+		return studentID + file.IDSuffix
+	}
+	sum := sha256.Sum256([]byte(assignment.ID.String()))
+	return studentID + "a" + hex.EncodeToString(sum[:])[:12] + file.IDSuffix
+}
+
+// StudentFileIDs trả về file ID trên Jobe của mọi file sinh viên theo assignment
+func StudentFileIDs(studentID string, assignment models.Assignment) []string {
+	fileIDs := make([]string, 0, len(assignment.StudentFiles))
+	for _, file := range assignment.StudentFiles {
+		fileIDs = append(fileIDs, StudentFileID(studentID, assignment, file))
+	}
+	return fileIDs
+}
+
+// HasAllowedExtension kiểm tra tên file có đuôi hợp lệ theo cấu hình assignment
+func HasAllowedExtension(file models.AssignmentStudentFile, filename string) bool {
+	return len(file.Extensions) == 0 || hasExtension(file.Extensions, filename)
+}
+
+// IsAssignmentInUse cho biết có post nào đang tham chiếu assignment hay không
+func IsAssignmentInUse(id uuid.UUID) (bool, error) {
+	var count int64
+	if err := database.DB.Db.Model(&models.Post{}).Where("assignment_id = ?", id).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
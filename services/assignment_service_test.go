@@ -0,0 +1,33 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/tison2810/be-go-tc/models"
+)
+
+func TestStudentFileID(t *testing.T) {
+	cppFile := models.AssignmentStudentFile{FormKey: "cpp_file", FileName: "main.cpp", IDSuffix: "cpp"}
+
+	if got := StudentFileID("2212345", DefaultAssignment(), cppFile); got != "2212345cpp" {
+		t.Errorf("default assignment file ID = %q, want legacy 2212345cpp", got)
+	}
+
+	a := models.Assignment{ID: uuid.MustParse("6f1c1f3e-2d1b-4f7a-9a51-0d1e2f3a4b5c")}
+	b := models.Assignment{ID: uuid.MustParse("0b7e8a52-91c4-4e0d-8f3a-5c6d7e8f9a0b")}
+	idA := StudentFileID("2212345", a, cppFile)
+	idB := StudentFileID("2212345", b, cppFile)
+	if idA == idB {
+		t.Fatalf("assignments with the same id_suffix share file ID %q", idA)
+	}
+	if idA == "2212345cpp" || idB == "2212345cpp" {
+		t.Errorf("assignment file ID collides with the default assignment: %q, %q", idA, idB)
+	}
+	if again := StudentFileID("2212345", a, cppFile); again != idA {
+		t.Errorf("file ID is not stable: %q then %q", idA, again)
+	}
+	if other := StudentFileID("2212346", a, cppFile); other == idA {
+		t.Errorf("different students share file ID %q", other)
+	}
+}
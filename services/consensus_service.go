@@ -0,0 +1,162 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/tison2810/be-go-tc/database"
+	"github.com/tison2810/be-go-tc/diff"
+	"github.com/tison2810/be-go-tc/models"
+)
+
+const (
+	MinConsensusStudents = 3   // Cần ít nhất chừng này sinh viên cùng output mới nghi expected sai
+	ConsensusRatio       = 0.6 // Tỉ lệ tối thiểu trong số sinh viên chạy xong phải cùng output
+	maxRecordedOutput    = 64 * 1024
+)
+
+// normalizeOutput chuẩn hóa stdout để so khớp giữa các sinh viên: bỏ \r và khoảng trắng cuối dòng
+func normalizeOutput(stdout string) string {
+	lines := strings.Split(strings.ReplaceAll(stdout, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// outputHash trả về SHA-256 của output đã chuẩn hóa
+func outputHash(normalized string) string {
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// recordOutput lưu stdout đã chuẩn hóa vào lần chạy theo verdict của nó.
+// Chỉ lưu khi chương trình chạy xong vì output của lần chạy lỗi không dùng để kết luận được.
+func recordOutput(studentRun *models.StudentRunTestcase, stdout string) {
+	studentRun.Stdout, studentRun.StdoutHash, studentRun.StdoutTruncated = "", "", false
+	if studentRun.Verdict != models.VerdictAccepted && studentRun.Verdict != models.VerdictWrongAnswer {
+		return
+	}
+	normalized := normalizeOutput(stdout)
+	studentRun.StdoutHash = outputHash(normalized)
+	if len(normalized) > maxRecordedOutput {
+		// Cắt giữa một ký tự nhiều byte thì ToValidUTF8 bỏ thêm vài byte nên không suy ra được từ độ dài
+		normalized = strings.ToValidUTF8(normalized[:maxRecordedOutput], "")
+		studentRun.StdoutTruncated = true
+	}
+	studentRun.Stdout = normalized
+}
+
+// outputSample trả về lần chạy gần nhất của testcase có output với hash cho trước
+type outputSample func(testcaseID uuid.UUID, hash string) (models.StudentRunTestcase, error)
+
+// SuspectedWrongExpected tìm các testcase của post mà đa số sinh viên chạy xong cùng ra một output
+// không khớp expected hiện tại. Mỗi sinh viên chỉ tính lần chạy mới nhất với từng testcase.
+func SuspectedWrongExpected(post models.Post) ([]models.SuspectedExpected, error) {
+	var latest []models.StudentRunTestcase
+	err := database.DB.Db.Model(&models.StudentRunTestcase{}).
+		Select("DISTINCT ON (testcase_id, student_mail) testcase_id, student_mail, stdout_hash, time").
+		Where("post_id = ? AND testcase_id IS NOT NULL", post.ID).
+		Order("testcase_id, student_mail, time DESC").
+		Scan(&latest).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return suspectedExpected(post.Testcases, latest, func(testcaseID uuid.UUID, hash string) (models.StudentRunTestcase, error) {
+		var sample models.StudentRunTestcase
+		err := database.DB.Db.Select("stdout", "stdout_truncated").
+			Where("testcase_id = ? AND stdout_hash = ?", testcaseID, hash).
+			Order("time DESC").First(&sample).Error
+		return sample, err
+	})
+}
+
+// suspectedExpected đếm output của lần chạy mới nhất của từng sinh viên trong runs và so với expected của testcase
+func suspectedExpected(testcases []models.Testcase, runs []models.StudentRunTestcase, sample outputSample) ([]models.SuspectedExpected, error) {
+	type key struct {
+		testcaseID  uuid.UUID
+		studentMail string
+	}
+	latest := make(map[key]models.StudentRunTestcase)
+	for _, run := range runs {
+		if run.TestcaseID == nil {
+			continue
+		}
+		k := key{*run.TestcaseID, run.StudentMail}
+		if previous, ok := latest[k]; !ok || run.Time.After(previous.Time) {
+			latest[k] = run
+		}
+	}
+
+	type tally struct {
+		total    int
+		finished int
+		counts   map[string]int
+	}
+	tallies := make(map[uuid.UUID]*tally)
+	for k, run := range latest {
+		t := tallies[k.testcaseID]
+		if t == nil {
+			t = &tally{counts: make(map[string]int)}
+			tallies[k.testcaseID] = t
+		}
+		t.total++
+		if run.StdoutHash != "" {
+			t.finished++
+			t.counts[run.StdoutHash]++
+		}
+	}
+
+	suspects := []models.SuspectedExpected{}
+	for _, testcase := range testcases {
+		t := tallies[testcase.ID]
+		if t == nil || t.finished == 0 {
+			continue
+		}
+		majorityHash, agreeing := majorityOutput(t.counts)
+		agreement := float64(agreeing) / float64(t.finished)
+		if agreeing < MinConsensusStudents || agreement < ConsensusRatio {
+			continue
+		}
+		if outputHash(normalizeOutput(testcase.Expected)) == majorityHash {
+			continue
+		}
+
+		run, err := sample(testcase.ID, majorityHash)
+		if err != nil {
+			return nil, err
+		}
+		// Expected có thể đã được sửa sau các lần chạy nên so lại với expected hiện tại
+		if !run.StdoutTruncated && TestcaseComparator(testcase).Compare(testcase.Expected, run.Stdout) {
+			continue
+		}
+		suspects = append(suspects, models.SuspectedExpected{
+			TestcaseID:       testcase.ID.String(),
+			Name:             testcase.Name,
+			Position:         testcase.Position,
+			Expected:         testcase.Expected,
+			MajorityOutput:   run.Stdout,
+			Truncated:        run.StdoutTruncated,
+			AgreeingStudents: agreeing,
+			FinishedStudents: t.finished,
+			TotalStudents:    t.total,
+			Agreement:        agreement,
+			Diff:             diff.Compute(strings.TrimSpace(testcase.Expected), run.Stdout),
+		})
+	}
+	return suspects, nil
+}
+
+// majorityOutput trả về hash có nhiều sinh viên nhất, khi bằng nhau lấy hash nhỏ hơn để kết quả ổn định
+func majorityOutput(counts map[string]int) (string, int) {
+	majorityHash, agreeing := "", 0
+	for hash, count := range counts {
+		if count > agreeing || (count == agreeing && hash < majorityHash) {
+			majorityHash, agreeing = hash, count
+		}
+	}
+	return majorityHash, agreeing
+}
@@ -0,0 +1,206 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/tison2810/be-go-tc/models"
+)
+
+// consensusRuns tạo các lần chạy đã lưu như RecordRun, giữ lại để làm nguồn output mẫu
+type consensusRuns struct {
+	runs []models.StudentRunTestcase
+	now  time.Time
+}
+
+func (c *consensusRuns) add(testcase models.Testcase, student string, verdict models.Verdict, stdout string) {
+	c.now = c.now.Add(time.Minute)
+	run := models.StudentRunTestcase{
+		ID:          uuid.New(),
+		PostID:      testcase.PostID,
+		TestcaseID:  &testcase.ID,
+		StudentMail: student,
+		Verdict:     verdict,
+		Time:        c.now,
+	}
+	recordOutput(&run, stdout)
+	c.runs = append(c.runs, run)
+}
+
+// addMany thêm n sinh viên cùng ra stdout
+func (c *consensusRuns) addMany(testcase models.Testcase, prefix string, n int, verdict models.Verdict, stdout string) {
+	for i := 0; i < n; i++ {
+		c.add(testcase, fmt.Sprintf("%s%d@hcmut.edu.vn", prefix, i), verdict, stdout)
+	}
+}
+
+// sample tìm lần chạy mới nhất có hash cho trước giống truy vấn trong SuspectedWrongExpected
+func (c *consensusRuns) sample(testcaseID uuid.UUID, hash string) (models.StudentRunTestcase, error) {
+	var found *models.StudentRunTestcase
+	for i := range c.runs {
+		run := &c.runs[i]
+		if *run.TestcaseID == testcaseID && run.StdoutHash == hash && (found == nil || run.Time.After(found.Time)) {
+			found = run
+		}
+	}
+	if found == nil {
+		return models.StudentRunTestcase{}, errors.New("no sample")
+	}
+	return *found, nil
+}
+
+func (c *consensusRuns) suspects(t *testing.T, testcases ...models.Testcase) []models.SuspectedExpected {
+	t.Helper()
+	suspects, err := suspectedExpected(testcases, c.runs, c.sample)
+	if err != nil {
+		t.Fatalf("suspectedExpected: %v", err)
+	}
+	return suspects
+}
+
+func consensusTestcase(expected string) models.Testcase {
+	return models.Testcase{ID: uuid.New(), PostID: uuid.New(), Name: "battle", Expected: expected}
+}
+
+func TestSuspectedExpectedThresholds(t *testing.T) {
+	tests := []struct {
+		name      string
+		agreeing  int
+		other     int // Sinh viên chạy xong với output khác
+		failed    int // Sinh viên chạy lỗi, không tính vào finished
+		suspected bool
+	}{
+		{"too few students", MinConsensusStudents - 1, 0, 0, false},
+		{"minimum students", MinConsensusStudents, 0, 0, true},
+		{"below ratio", 3, 3, 0, false},
+		{"at ratio", 3, 2, 0, true},
+		{"failed runs do not count toward the ratio", 3, 2, 10, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testcase := consensusTestcase("Result: 10")
+			var c consensusRuns
+			c.addMany(testcase, "agree", tt.agreeing, models.VerdictWrongAnswer, "Result: 12\n")
+			c.addMany(testcase, "other", tt.other, models.VerdictWrongAnswer, "Result: 7")
+			c.addMany(testcase, "failed", tt.failed, models.VerdictRuntimeError, "")
+
+			suspects := c.suspects(t, testcase)
+			if got := len(suspects) == 1; got != tt.suspected {
+				t.Fatalf("suspected = %v, want %v (%+v)", got, tt.suspected, suspects)
+			}
+			if !tt.suspected {
+				return
+			}
+			s := suspects[0]
+			if s.MajorityOutput != "Result: 12" || s.AgreeingStudents != tt.agreeing ||
+				s.FinishedStudents != tt.agreeing+tt.other || s.TotalStudents != tt.agreeing+tt.other+tt.failed {
+				t.Errorf("suspect = %+v", s)
+			}
+			if want := float64(tt.agreeing) / float64(tt.agreeing+tt.other); s.Agreement != want {
+				t.Errorf("agreement = %v, want %v", s.Agreement, want)
+			}
+			if s.Diff == nil {
+				t.Error("suspect has no diff against expected")
+			}
+		})
+	}
+}
+
+func TestSuspectedExpectedLatestRunPerStudent(t *testing.T) {
+	testcase := consensusTestcase("Result: 10")
+	var c consensusRuns
+	// Ba sinh viên từng ra cùng output sai nhưng hai người đã sửa code
+	c.addMany(testcase, "student", 3, models.VerdictWrongAnswer, "Result: 12")
+	c.add(testcase, "student0@hcmut.edu.vn", models.VerdictAccepted, "Result: 10")
+	c.add(testcase, "student1@hcmut.edu.vn", models.VerdictAccepted, "Result: 10")
+
+	if suspects := c.suspects(t, testcase); len(suspects) != 0 {
+		t.Errorf("older runs were counted: %+v", suspects)
+	}
+}
+
+func TestSuspectedExpectedMatchesCurrentExpected(t *testing.T) {
+	var c consensusRuns
+
+	// Đa số ra đúng expected (khác khoảng trắng cuối dòng) thì không nghi ngờ
+	matching := consensusTestcase("Result: 10\nWinner: LIBERATIONARMY")
+	c.addMany(matching, "a", 4, models.VerdictAccepted, "Result: 10  \r\nWinner: LIBERATIONARMY\n")
+
+	// Expected đã được giáo viên sửa sau các lần chạy, so lại bằng comparator của testcase
+	fixed := consensusTestcase("Result: 12.0")
+	fixed.CompareMode = "numeric"
+	c.addMany(fixed, "b", 4, models.VerdictWrongAnswer, "Result: 12")
+
+	// Expected vẫn sai
+	wrong := consensusTestcase("Result: 10")
+	c.addMany(wrong, "c", 4, models.VerdictWrongAnswer, "Result: 12")
+
+	suspects := c.suspects(t, matching, fixed, wrong)
+	if len(suspects) != 1 || suspects[0].TestcaseID != wrong.ID.String() {
+		t.Fatalf("suspects = %+v, want only %s", suspects, wrong.ID)
+	}
+}
+
+func TestSuspectedExpectedTruncatedOutput(t *testing.T) {
+	// Output dài bị cắt giữa một ký tự nhiều byte vẫn phải được đánh dấu truncated
+	long := strings.Repeat("a", maxRecordedOutput-1) + "ế" + "tail"
+	testcase := consensusTestcase("Result: 10")
+	var c consensusRuns
+	c.addMany(testcase, "student", 3, models.VerdictWrongAnswer, long)
+
+	suspects := c.suspects(t, testcase)
+	if len(suspects) != 1 {
+		t.Fatalf("suspects = %+v", suspects)
+	}
+	if !suspects[0].Truncated {
+		t.Errorf("truncated = false for output of %d bytes stored as %d", len(long), len(suspects[0].MajorityOutput))
+	}
+}
+
+func TestMajorityOutputTieBreak(t *testing.T) {
+	counts := map[string]int{"bbb": 3, "aaa": 3, "ccc": 1}
+	for i := 0; i < 20; i++ {
+		if hash, count := majorityOutput(counts); hash != "aaa" || count != 3 {
+			t.Fatalf("majorityOutput = %s, %d, want aaa, 3", hash, count)
+		}
+	}
+	if hash, count := majorityOutput(map[string]int{}); hash != "" || count != 0 {
+		t.Errorf("majorityOutput of no runs = %q, %d", hash, count)
+	}
+}
+
+func TestRecordOutput(t *testing.T) {
+	run := models.StudentRunTestcase{Verdict: models.VerdictRuntimeError}
+	recordOutput(&run, "partial output")
+	if run.Stdout != "" || run.StdoutHash != "" || run.StdoutTruncated {
+		t.Errorf("output of a failed run was recorded: %+v", run)
+	}
+
+	run = models.StudentRunTestcase{Verdict: models.VerdictWrongAnswer}
+	recordOutput(&run, "Result: 12  \r\n\n")
+	if run.Stdout != "Result: 12" || run.StdoutHash != outputHash("Result: 12") || run.StdoutTruncated {
+		t.Errorf("recorded %+v", run)
+	}
+
+	// Đúng bằng giới hạn thì không bị cắt
+	run = models.StudentRunTestcase{Verdict: models.VerdictAccepted}
+	recordOutput(&run, strings.Repeat("x", maxRecordedOutput))
+	if run.StdoutTruncated || len(run.Stdout) != maxRecordedOutput {
+		t.Errorf("output at the limit: truncated %v, %d bytes", run.StdoutTruncated, len(run.Stdout))
+	}
+
+	// Cắt giữa ký tự nhiều byte làm output ngắn hơn giới hạn nhưng vẫn là truncated
+	long := strings.Repeat("a", maxRecordedOutput-1) + "ế"
+	recordOutput(&run, long)
+	if !run.StdoutTruncated || len(run.Stdout) >= maxRecordedOutput || !utf8.ValidString(run.Stdout) {
+		t.Errorf("output cut mid-rune: truncated %v, %d bytes", run.StdoutTruncated, len(run.Stdout))
+	}
+	if run.StdoutHash != outputHash(long) {
+		t.Error("hash was computed after truncation")
+	}
+}
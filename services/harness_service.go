@@ -0,0 +1,237 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+
+	"github.com/google/uuid"
+	"github.com/tison2810/be-go-tc/database"
+	"github.com/tison2810/be-go-tc/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// harnessFileIDPrefix phân biệt file harness với các file ID khác trên Jobe
+const harnessFileIDPrefix = "hs"
+
+// HarnessUpload là một file harness giáo viên gửi lên
+type HarnessUpload struct {
+	FileName string
+	Content  []byte
+}
+
+// CreateHarnessVersion lưu bộ file harness thành phiên bản mới của assignment và upload lên mọi Jobe server
+func CreateHarnessVersion(ctx context.Context, assignmentID uuid.UUID, createdBy, note string, uploads []HarnessUpload) (*models.HarnessVersion, error) {
+	if len(uploads) == 0 {
+		return nil, errors.New("at least one harness file is required")
+	}
+	harness := &models.HarnessVersion{
+		ID:           uuid.New(),
+		AssignmentID: assignmentID,
+		Note:         note,
+		CreatedBy:    createdBy,
+	}
+	names := make(map[string]bool)
+	for _, upload := range uploads {
+		if upload.FileName == "" || path.Base(upload.FileName) != upload.FileName {
+			return nil, fmt.Errorf("invalid file name %q", upload.FileName)
+		}
+		if names[upload.FileName] {
+			return nil, fmt.Errorf("duplicate file name %q", upload.FileName)
+		}
+		names[upload.FileName] = true
+
+		sum := sha256.Sum256(upload.Content)
+		harness.Files = append(harness.Files, models.HarnessFile{
+			ID:        uuid.New(),
+			HarnessID: harness.ID,
+			FileID:    harnessFileIDPrefix + newOpaqueID(),
+			FileName:  upload.FileName,
+			Content:   upload.Content,
+			SHA256:    hex.EncodeToString(sum[:]),
+			Size:      int64(len(upload.Content)),
+		})
+	}
+
+	err := database.DB.Db.Transaction(func(tx *gorm.DB) error {
+		// Khóa các phiên bản của assignment để không lấy trùng số phiên bản
+		var versions []int
+		if err := tx.Model(&models.HarnessVersion{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("assignment_id = ?", assignmentID).Pluck("version", &versions).Error; err != nil {
+			return err
+		}
+		for _, version := range versions {
+			harness.Version = max(harness.Version, version)
+		}
+		harness.Version++
+		return tx.Create(harness).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	pushHarnessFiles(ctx, harness)
+	return harness, nil
+}
+
+// ActivateHarnessVersion đặt phiên bản làm harness đang dùng của assignment
+func ActivateHarnessVersion(ctx context.Context, assignmentID uuid.UUID, version int) (*models.HarnessVersion, error) {
+	var harness models.HarnessVersion
+	err := database.DB.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Preload("Files").Where("assignment_id = ? AND version = ?", assignmentID, version).First(&harness).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.HarnessVersion{}).Where("assignment_id = ? AND active", assignmentID).Update("active", false).Error; err != nil {
+			return err
+		}
+		harness.Active = true
+		return tx.Model(&harness).Update("active", true).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	pushHarnessFiles(ctx, &harness)
+	return &harness, nil
+}
+
+// ListHarnessVersions trả về các phiên bản harness của assignment, mới nhất trước, không kèm nội dung file
+func ListHarnessVersions(assignmentID uuid.UUID) ([]models.HarnessVersion, error) {
+	var versions []models.HarnessVersion
+	if err := database.DB.Db.
+		Preload("Files", func(db *gorm.DB) *gorm.DB { return db.Omit("content") }).
+		Where("assignment_id = ?", assignmentID).Order("version DESC").Find(&versions).Error; err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// GetActiveHarness trả về harness đang dùng của assignment, nil nếu assignment vẫn dùng system_files cấu hình tay
+func GetActiveHarness(assignmentID uuid.UUID) (*models.HarnessVersion, error) {
+	var harness models.HarnessVersion
+	err := database.DB.Db.Preload("Files", func(db *gorm.DB) *gorm.DB { return db.Omit("content") }).
+		Where("assignment_id = ? AND active", assignmentID).First(&harness).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &harness, nil
+}
+
+// ApplyHarness thay system_files của assignment bằng các file của harness
+func ApplyHarness(assignment *models.Assignment, harness *models.HarnessVersion) {
+	if harness == nil {
+		return
+	}
+	systemFiles := make([]models.AssignmentSystemFile, 0, len(harness.Files))
+	for _, file := range harness.Files {
+		systemFiles = append(systemFiles, models.AssignmentSystemFile{FileID: file.FileID, FileName: file.FileName})
+	}
+	assignment.SystemFiles = systemFiles
+}
+
+// HarnessIDForFiles tìm phiên bản harness chứa các file trong file_list, nil nếu run không dùng harness
+func HarnessIDForFiles(fileIDs []string) *uuid.UUID {
+	var file models.HarnessFile
+	err := database.DB.Db.Select("id", "harness_id").Where("file_id IN ?", fileIDs).First(&file).Error
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Failed to find harness for run: %v", err)
+		}
+		return nil
+	}
+	return &file.HarnessID
+}
+
+// pushHarnessFiles upload các file harness lên mọi Jobe server, node lỗi sẽ được upload lại khi chạy
+func pushHarnessFiles(ctx context.Context, harness *models.HarnessVersion) {
+	fileIDs := make([]string, 0, len(harness.Files))
+	for _, file := range harness.Files {
+		fileIDs = append(fileIDs, file.FileID)
+	}
+	if _, err := jobePool.EnsureFiles(ctx, fileIDs...); err != nil {
+		log.Printf("Failed to push harness %s to Jobe: %v", harness.ID, err)
+	}
+}
+
+// harnessFileSource cho phép Pool upload lại file harness lên node bị thiếu file
+func harnessFileSource(ctx context.Context, fileID string) ([]byte, bool, error) {
+	var file models.HarnessFile
+	err := database.DB.Db.WithContext(ctx).Select("id", "content").First(&file, "file_id = ?", fileID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return file.Content, true, nil
+}
+
+// RegradeAssignment đưa lại vào hàng đợi mọi testcase của các post thuộc assignment cho từng
+// sinh viên đã từng chạy post đó. Run dùng code đang active của sinh viên và harness đang dùng,
+// trả về số run đã được đưa vào hàng đợi.
+func RegradeAssignment(assignmentID uuid.UUID) (int, error) {
+	assignment, err := GetAssignment(assignmentID)
+	if err != nil {
+		return 0, err
+	}
+	harness, err := GetActiveHarness(assignmentID)
+	if err != nil {
+		return 0, err
+	}
+	ApplyHarness(&assignment, harness)
+	courseLimit, err := GetCourseLimit()
+	if err != nil {
+		return 0, err
+	}
+
+	query := database.DB.Db.Model(&models.Post{}).Where("post_status IN ?", []string{"active", "similar"})
+	if assignmentID == uuid.Nil {
+		query = query.Where("assignment_id IS NULL")
+	} else {
+		query = query.Where("assignment_id = ?", assignmentID)
+	}
+	var postIDs []uuid.UUID
+	if err := query.Pluck("id", &postIDs).Error; err != nil {
+		return 0, err
+	}
+
+	queued := 0
+	for _, postID := range postIDs {
+		testcases, err := GetTestcasesByPostID(postID)
+		if err != nil {
+			return queued, err
+		}
+		var students []struct {
+			Mail string
+			Maso string
+		}
+		if err := database.DB.Db.Table("student_run_testcases AS s").
+			Select("DISTINCT u.mail, u.maso").
+			Joins("JOIN users u ON u.mail = s.student_mail").
+			Where("s.post_id = ?", postID).Scan(&students).Error; err != nil {
+			return queued, err
+		}
+
+		for _, student := range students {
+			submissionID := uuid.New()
+			files, _ := StudentRunFiles(student.Mail, student.Maso, assignment)
+			for _, testcase := range testcases {
+				limits := ResolveRunLimits(courseLimit, assignment, testcase)
+				runSpec := BuildRunSpec(assignment, files, testcase, limits)
+				if _, err := EnqueueRun(testcase, student.Mail, submissionID, runSpec); err != nil {
+					return queued, err
+				}
+				queued++
+			}
+		}
+	}
+	return queued, nil
+}
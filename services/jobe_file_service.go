@@ -0,0 +1,146 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"regexp"
+
+	"github.com/google/uuid"
+	"github.com/tison2810/be-go-tc/database"
+	"github.com/tison2810/be-go-tc/models"
+	"gorm.io/gorm"
+)
+
+// jobeFileIDPrefix phân biệt file của proxy với file ID nội bộ (<maso>cpp, ID testcase, systemmainh)
+const jobeFileIDPrefix = "ns"
+
+var (
+	fileNamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,100}$`)
+
+	ErrFileForbidden   = errors.New("not allowed to access this file")
+	ErrInvalidFileName = errors.New("file name may only contain letters, digits, '.', '_' and '-'")
+	ErrInvalidScope    = errors.New("scope must be user, testcase or system")
+	ErrMissingTestcase = errors.New("testcase_id is required for testcase files")
+)
+
+// FileAccess là người dùng đang truy cập proxy file
+type FileAccess struct {
+	Email string
+	Role  string
+}
+
+func (a FileAccess) isTeacher() bool {
+	return a.Role == "teacher"
+}
+
+// PutScopedFile lưu file vào phạm vi cho trước và upload lên Jobe. File cùng tên trong
+// cùng phạm vi giữ nguyên ID và được ghi đè nội dung.
+func PutScopedFile(ctx context.Context, access FileAccess, scope string, testcaseID *uuid.UUID, name string, content []byte) (*models.JobeFile, error) {
+	if !fileNamePattern.MatchString(name) {
+		return nil, ErrInvalidFileName
+	}
+	owner, err := scopeOwner(access, scope, testcaseID)
+	if err != nil {
+		return nil, err
+	}
+
+	var file models.JobeFile
+	err = database.DB.Db.Where("scope = ? AND owner = ? AND name = ?", scope, owner, name).First(&file).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		file = models.JobeFile{
+			ID:         jobeFileIDPrefix + newOpaqueID(),
+			Scope:      scope,
+			Owner:      owner,
+			Name:       name,
+			TestcaseID: testcaseID,
+			CreatedBy:  access.Email,
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(content)
+	file.Content = content
+	file.SHA256 = hex.EncodeToString(sum[:])
+	file.Size = int64(len(content))
+	if err := database.DB.Db.Save(&file).Error; err != nil {
+		return nil, err
+	}
+
+	// File đã được lưu nên Pool sẽ upload lại khi Jobe thiếu file
+	if err := jobePool.PutFile(ctx, file.ID, content); err != nil {
+		log.Printf("Failed to push file %s to Jobe, will retry before run: %v", file.ID, err)
+	}
+	return &file, nil
+}
+
+// GetScopedFile lấy file của proxy theo ID và kiểm tra quyền đọc
+func GetScopedFile(access FileAccess, fileID string) (*models.JobeFile, error) {
+	var file models.JobeFile
+	if err := database.DB.Db.Omit("content").First(&file, "id = ?", fileID).Error; err != nil {
+		return nil, err
+	}
+	if file.Scope == models.FileScopeUser && file.Owner != access.Email && !access.isTeacher() {
+		return nil, ErrFileForbidden
+	}
+	return &file, nil
+}
+
+// scopeOwner kiểm tra quyền ghi vào phạm vi và trả về owner của file
+func scopeOwner(access FileAccess, scope string, testcaseID *uuid.UUID) (string, error) {
+	switch scope {
+	case models.FileScopeUser:
+		return access.Email, nil
+	case models.FileScopeSystem:
+		if !access.isTeacher() {
+			return "", ErrFileForbidden
+		}
+		return "", nil
+	case models.FileScopeTestcase:
+		if testcaseID == nil {
+			return "", ErrMissingTestcase
+		}
+		testcase, err := GetTestcase(*testcaseID)
+		if err != nil {
+			return "", err
+		}
+		if !access.isTeacher() {
+			var post models.Post
+			if err := database.DB.Db.Select("id", "user_mail").First(&post, "id = ?", testcase.PostID).Error; err != nil {
+				return "", err
+			}
+			if post.UserMail != access.Email {
+				return "", ErrFileForbidden
+			}
+		}
+		return testcaseID.String(), nil
+	default:
+		return "", ErrInvalidScope
+	}
+}
+
+// newOpaqueID sinh chuỗi hex ngẫu nhiên dùng làm file ID trên Jobe
+func newOpaqueID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		log.Panicf("failed to generate file ID: %v", err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// jobeFileSource cho phép Pool upload lại file của proxy lên node bị thiếu file
+func jobeFileSource(ctx context.Context, fileID string) ([]byte, bool, error) {
+	var file models.JobeFile
+	err := database.DB.Db.WithContext(ctx).Select("id", "content").First(&file, "id = ?", fileID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return file.Content, true, nil
+}
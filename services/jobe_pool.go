@@ -0,0 +1,46 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tison2810/be-go-tc/database"
+	"github.com/tison2810/be-go-tc/jobe"
+	"github.com/tison2810/be-go-tc/models"
+	"gorm.io/gorm"
+)
+
+// JobePool trả về Pool các Jobe server dùng chung cho toàn bộ backend
+func JobePool() *jobe.Pool {
+	return jobePool
+}
+
+// StartJobeHealthChecks bắt đầu kiểm tra định kỳ các Jobe server.
+// Chu kỳ đọc từ JOBE_HEALTH_INTERVAL (ví dụ 30s), mặc định là jobe.DefaultHealthCheckInterval.
+func StartJobeHealthChecks() {
+	interval, err := time.ParseDuration(os.Getenv("JOBE_HEALTH_INTERVAL"))
+	if err != nil || interval <= 0 {
+		interval = jobe.DefaultHealthCheckInterval
+	}
+	jobePool.StartHealthChecks(context.Background(), interval)
+}
+
+// testcaseInputSource cho phép Pool upload lại input của testcase lên node bị thiếu file
+func testcaseInputSource(ctx context.Context, fileID string) ([]byte, bool, error) {
+	testcaseID, err := uuid.Parse(fileID)
+	if err != nil {
+		return nil, false, nil
+	}
+	var testcase models.Testcase
+	err = database.DB.Db.WithContext(ctx).Select("id", "input").First(&testcase, "id = ?", testcaseID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return []byte(testcase.Input), true, nil
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/tison2810/be-go-tc/database"
+	"github.com/tison2810/be-go-tc/models"
+	"gorm.io/gorm"
+)
+
+// DefaultCourseLimit là giới hạn dùng khi giáo viên chưa cấu hình
+func DefaultCourseLimit() models.CourseLimit {
+	return models.CourseLimit{
+		ID:                      models.CourseLimitID,
+		DefaultMaxExecutionTime: 5,
+		DefaultMaxMemoryUsage:   1000000,
+		MaxExecutionTime:        30,
+		MaxMemoryUsage:          4000000,
+	}
+}
+
+// GetCourseLimit lấy giới hạn của khóa học, chưa cấu hình thì trả về DefaultCourseLimit
+func GetCourseLimit() (models.CourseLimit, error) {
+	var limit models.CourseLimit
+	err := database.DB.Db.First(&limit, "id = ?", models.CourseLimitID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return DefaultCourseLimit(), nil
+	}
+	if err != nil {
+		return models.CourseLimit{}, err
+	}
+	return limit, nil
+}
+
+// ValidateCourseLimit kiểm tra giới hạn của khóa học trước khi lưu
+func ValidateCourseLimit(limit *models.CourseLimit) error {
+	if limit.DefaultMaxExecutionTime <= 0 || limit.DefaultMaxMemoryUsage <= 0 ||
+		limit.MaxExecutionTime <= 0 || limit.MaxMemoryUsage <= 0 {
+		return errors.New("all limits must be positive")
+	}
+	if limit.DefaultMaxExecutionTime > limit.MaxExecutionTime {
+		return errors.New("default_max_execution_time must not exceed max_execution_time")
+	}
+	if limit.DefaultMaxMemoryUsage > limit.MaxMemoryUsage {
+		return errors.New("default_max_memory_usage must not exceed max_memory_usage")
+	}
+	limit.ID = models.CourseLimitID
+	return nil
+}
+
+// ResolveRunLimits chọn giới hạn cho testcase theo thứ tự testcase, assignment,
+// mặc định của khóa học, sau đó giới hạn lại bằng mức tối đa của khóa học
+func ResolveRunLimits(course models.CourseLimit, assignment models.Assignment, testcase models.Testcase) models.RunLimits {
+	limits := models.RunLimits{
+		MaxExecutionTime: course.DefaultMaxExecutionTime,
+		MaxMemoryUsage:   course.DefaultMaxMemoryUsage,
+	}
+	if assignment.MaxExecutionTime > 0 {
+		limits.MaxExecutionTime = assignment.MaxExecutionTime
+	}
+	if assignment.MaxMemoryUsage > 0 {
+		limits.MaxMemoryUsage = assignment.MaxMemoryUsage
+	}
+	if testcase.MaxExecutionTime != nil {
+		limits.MaxExecutionTime = *testcase.MaxExecutionTime
+	}
+	if testcase.MaxMemoryUsage != nil {
+		limits.MaxMemoryUsage = *testcase.MaxMemoryUsage
+	}
+	limits.MaxExecutionTime = min(limits.MaxExecutionTime, course.MaxExecutionTime)
+	limits.MaxMemoryUsage = min(limits.MaxMemoryUsage, course.MaxMemoryUsage)
+	return limits
+}
+
+// ValidateTestcaseLimits kiểm tra giới hạn riêng của testcase không vượt quá mức tối đa của khóa học
+func ValidateTestcaseLimits(course models.CourseLimit, testcase models.Testcase) error {
+	if testcase.MaxExecutionTime != nil && *testcase.MaxExecutionTime > course.MaxExecutionTime {
+		return fmt.Errorf("max_execution_time must not exceed %d", course.MaxExecutionTime)
+	}
+	if testcase.MaxMemoryUsage != nil && *testcase.MaxMemoryUsage > course.MaxMemoryUsage {
+		return fmt.Errorf("max_memory_usage must not exceed %d", course.MaxMemoryUsage)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/tison2810/be-go-tc/models"
+)
+
+func intPtr(v int) *int { return &v }
+
+func TestResolveRunLimits(t *testing.T) {
+	course := models.CourseLimit{
+		DefaultMaxExecutionTime: 5,
+		DefaultMaxMemoryUsage:   100000,
+		MaxExecutionTime:        30,
+		MaxMemoryUsage:          400000,
+	}
+	tests := []struct {
+		name       string
+		assignment models.Assignment
+		testcase   models.Testcase
+		want       models.RunLimits
+	}{
+		{"course defaults", models.Assignment{}, models.Testcase{}, models.RunLimits{MaxExecutionTime: 5, MaxMemoryUsage: 100000}},
+		{"assignment overrides defaults", models.Assignment{MaxExecutionTime: 10, MaxMemoryUsage: 200000}, models.Testcase{},
+			models.RunLimits{MaxExecutionTime: 10, MaxMemoryUsage: 200000}},
+		{"testcase overrides assignment", models.Assignment{MaxExecutionTime: 10, MaxMemoryUsage: 200000},
+			models.Testcase{MaxExecutionTime: intPtr(20), MaxMemoryUsage: intPtr(50000)},
+			models.RunLimits{MaxExecutionTime: 20, MaxMemoryUsage: 50000}},
+		{"testcase can tighten limits", models.Assignment{}, models.Testcase{MaxExecutionTime: intPtr(1)},
+			models.RunLimits{MaxExecutionTime: 1, MaxMemoryUsage: 100000}},
+		{"capped by course maximum", models.Assignment{MaxExecutionTime: 60, MaxMemoryUsage: 800000}, models.Testcase{},
+			models.RunLimits{MaxExecutionTime: 30, MaxMemoryUsage: 400000}},
+		{"testcase capped by course maximum", models.Assignment{},
+			models.Testcase{MaxExecutionTime: intPtr(45), MaxMemoryUsage: intPtr(900000)},
+			models.RunLimits{MaxExecutionTime: 30, MaxMemoryUsage: 400000}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ResolveRunLimits(course, tt.assignment, tt.testcase); got != tt.want {
+				t.Errorf("ResolveRunLimits = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateTestcaseLimits(t *testing.T) {
+	course := DefaultCourseLimit()
+	valid := []models.Testcase{
+		{},
+		{MaxExecutionTime: intPtr(1), MaxMemoryUsage: intPtr(1024)},
+		{MaxExecutionTime: intPtr(course.MaxExecutionTime), MaxMemoryUsage: intPtr(course.MaxMemoryUsage)},
+	}
+	for _, testcase := range valid {
+		if err := ValidateTestcaseLimits(course, testcase); err != nil {
+			t.Errorf("ValidateTestcaseLimits(%v, %v) = %v", testcase.MaxExecutionTime, testcase.MaxMemoryUsage, err)
+		}
+	}
+
+	invalid := []models.Testcase{
+		{MaxExecutionTime: intPtr(course.MaxExecutionTime + 1)},
+		{MaxMemoryUsage: intPtr(course.MaxMemoryUsage + 1)},
+	}
+	for _, testcase := range invalid {
+		if err := ValidateTestcaseLimits(course, testcase); err == nil {
+			t.Errorf("ValidateTestcaseLimits accepted limits above the course maximum: %v, %v", testcase.MaxExecutionTime, testcase.MaxMemoryUsage)
+		}
+	}
+}
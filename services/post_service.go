@@ -1,29 +1,38 @@
 package services
 
 import (
-	"bytes"
-	"encoding/base64"
-	"encoding/json"
+	"context"
 	"fmt"
 	"io"
 	"log"
-	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
+	"github.com/tison2810/be-go-tc/comparator"
 	"github.com/tison2810/be-go-tc/database"
+	"github.com/tison2810/be-go-tc/diff"
+	"github.com/tison2810/be-go-tc/jobe"
 	"github.com/tison2810/be-go-tc/models"
+	"github.com/tison2810/be-go-tc/sanitizer"
 	"github.com/tison2810/be-go-tc/utils"
+	"gorm.io/gorm"
 )
 
 // PostService chứa các phương thức liên quan đến post
 var flaskClient *utils.FlaskClient
+var jobePool *jobe.Pool
 
 func init() {
 	flaskClient = utils.NewFlaskClient()
+	jobePool = jobe.NewPoolFromEnv()
+	jobePool.AddFileSource(studentFileSource)
+	jobePool.AddFileSource(testcaseInputSource)
+	jobePool.AddFileSource(jobeFileSource)
+	jobePool.AddFileSource(harnessFileSource)
+	jobePool.AddFileSource(referenceFileSource)
 }
 
 type PostService struct {
@@ -35,54 +44,88 @@ func NewPostService() *PostService {
 	return &PostService{}
 }
 
+// RunSource cho biết một lần chạy testcase thuộc lần nộp nào, dùng phiên bản code và harness nào
+type RunSource struct {
+	SubmissionID uuid.UUID
+	UploadID     *uuid.UUID
+	HarnessID    *uuid.UUID
+}
+
+// RunSourceForSpec xác định phiên bản code và harness từ các file trong run_spec
+func RunSourceForSpec(studentMail string, submissionID uuid.UUID, runSpec models.RunSpec) RunSource {
+	fileIDs := jobe.FileListIDs(runSpec.FileList)
+	return RunSource{
+		SubmissionID: submissionID,
+		UploadID:     ActiveUploadID(studentMail, fileIDs),
+		HarnessID:    HarnessIDForFiles(fileIDs),
+	}
+}
+
 // CheckRunResult kiểm tra kết quả chạy code từ Jobe và so sánh với expected của testcase
 func (s *PostService) CheckRunResult(
-	postID uuid.UUID,
+	testcase models.Testcase,
 	studentMail string,
-	jobeResponse string,
+	source RunSource,
+	jobeResult *models.JobeRunResult,
 ) (*models.StudentRunTestcase, error) {
-	// 1. Parse response từ Jobe
-	var jobeResult models.JobeRunResult
-	if err := json.Unmarshal([]byte(jobeResponse), &jobeResult); err != nil {
-		return nil, err
-	}
+	// 1. So sánh stdout với expected theo chế độ so sánh của testcase
+	stdout := strings.TrimSpace(jobeResult.Stdout)
 
-	// 2. Lấy testcase từ database
-	var testcase models.Testcase
-	if err := database.DB.Db.Where("post_id = ?", postID).First(&testcase).Error; err != nil {
-		return nil, err
+	// 2. Tách báo cáo của sanitizer khỏi stderr để lỗi bộ nhớ không làm đổi verdict
+	findings, stderr := sanitizer.Parse(jobeResult.Stderr)
+	if len(findings) > 0 {
+		stripped := *jobeResult
+		stripped.Stderr = stderr
+		jobeResult = &stripped
 	}
 
-	// 3. So sánh stdout với expected
-	// Chuẩn hóa stdout và expected (loại bỏ khoảng trắng thừa, xuống dòng)
-	stdout := strings.TrimSpace(jobeResult.Stdout)
-	expected := strings.TrimSpace(testcase.Expected)
+	// 3. Suy ra verdict từ outcome, cmpinfo và stderr
+	matched := TestcaseComparator(testcase).Compare(testcase.Expected, jobeResult.Stdout)
+	verdict := jobe.Verdict(jobeResult, matched)
 
-	// Tính score: 1 nếu khớp, 0 nếu không khớp
+	// Tính score: 1 nếu AC, 0 trong các trường hợp còn lại
 	score := 0
-	logMessage := stdout
-	if stdout == expected {
+	if verdict == models.VerdictAccepted {
 		score = 1
 	}
 
-	// Kiểm tra lỗi biên dịch hoặc runtime
-	if jobeResult.Cmpinfo != "" {
+	logMessage := stdout
+	switch verdict {
+	case models.VerdictAccepted, models.VerdictWrongAnswer:
+		// Log là stdout của chương trình
+	case models.VerdictCompileError:
 		logMessage = "Compilation error: " + jobeResult.Cmpinfo
-		score = 0
-	} else if jobeResult.Stderr != "" {
+	case models.VerdictRuntimeError:
 		logMessage = "Runtime error: " + jobeResult.Stderr
-		score = 0
-	} else if jobeResult.Outcome != 15 {
-		logMessage = "Execution failed: " + strconv.Itoa(jobeResult.Outcome)
-		score = 0
+	default:
+		logMessage = "Execution failed: " + verdict.Description()
+		if jobeResult.Stderr != "" {
+			logMessage += "\n" + jobeResult.Stderr
+		}
 	}
+
+	// Chỉ tính diff khi chương trình chạy xong nhưng output sai
+	var outputDiff *models.OutputDiff
+	if verdict == models.VerdictWrongAnswer {
+		outputDiff = diff.Compute(strings.TrimSpace(testcase.Expected), stdout)
+	}
+
 	studentRun := models.StudentRunTestcase{
-		ID:          uuid.New(),
-		PostID:      postID,
-		StudentMail: studentMail,
-		Log:         logMessage,
-		Score:       score,
+		ID:           uuid.New(),
+		PostID:       testcase.PostID,
+		TestcaseID:   &testcase.ID,
+		SubmissionID: &source.SubmissionID,
+		UploadID:     source.UploadID,
+		HarnessID:    source.HarnessID,
+		StudentMail:  studentMail,
+		Log:          logMessage,
+		Score:        score,
+		Verdict:      verdict,
+		Weight:       testcase.Weight,
+		Diff:         outputDiff,
+		Findings:     findings,
 	}
+	recordOutput(&studentRun, jobeResult.Stdout)
 
 	if err := database.DB.Db.Create(&studentRun).Error; err != nil {
 		return nil, err
@@ -91,9 +134,69 @@ func (s *PostService) CheckRunResult(
 	return &studentRun, nil
 }
 
-func GetTestcaseByPostID(postID uuid.UUID) (models.Testcase, error) {
+// RecordCachedRun lưu lần chạy testcase dùng kết quả đã có trong cache thay vì chạy trên Jobe
+func (s *PostService) RecordCachedRun(
+	testcase models.Testcase,
+	studentMail string,
+	source RunSource,
+	entry *models.RunCacheEntry,
+) (*models.StudentRunTestcase, error) {
+	studentRun := models.StudentRunTestcase{
+		ID:           uuid.New(),
+		PostID:       testcase.PostID,
+		TestcaseID:   &testcase.ID,
+		SubmissionID: &source.SubmissionID,
+		UploadID:     source.UploadID,
+		HarnessID:    source.HarnessID,
+		StudentMail:  studentMail,
+		Log:          entry.Log,
+		Score:        entry.Score,
+		Verdict:      entry.Verdict,
+		Weight:       testcase.Weight,
+		Diff:         entry.Diff,
+		Findings:     entry.Findings,
+		Cached:       true,
+	}
+	recordOutput(&studentRun, entry.Stdout)
+
+	if err := database.DB.Db.Create(&studentRun).Error; err != nil {
+		return nil, err
+	}
+
+	return &studentRun, nil
+}
+
+// TestcaseComparator trả về comparator của testcase, cấu hình lỗi sẽ quay về so sánh exact
+func TestcaseComparator(testcase models.Testcase) comparator.Comparator {
+	cmp, err := comparator.New(testcase.CompareMode, comparator.Options{
+		AbsoluteEpsilon: testcase.AbsoluteEpsilon,
+		RelativeEpsilon: testcase.RelativeEpsilon,
+	})
+	if err != nil {
+		log.Printf("Invalid comparator for testcase %s: %v", testcase.ID, err)
+		return comparator.Exact{}
+	}
+	return cmp
+}
+
+// OrderTestcases sắp xếp testcase theo thứ tự trong post, dùng với Preload("Testcases", ...)
+func OrderTestcases(db *gorm.DB) *gorm.DB {
+	return db.Order("position, id")
+}
+
+// GetTestcasesByPostID lấy các testcase của post theo thứ tự
+func GetTestcasesByPostID(postID uuid.UUID) ([]models.Testcase, error) {
+	var testcases []models.Testcase
+	if err := OrderTestcases(database.DB.Db.Where("post_id = ?", postID)).Find(&testcases).Error; err != nil {
+		return nil, err
+	}
+	return testcases, nil
+}
+
+// GetTestcase lấy một testcase theo ID
+func GetTestcase(id uuid.UUID) (models.Testcase, error) {
 	var testcase models.Testcase
-	if err := database.DB.Db.Where("post_id = ?", postID).First(&testcase).Error; err != nil {
+	if err := database.DB.Db.Where("id = ?", id).First(&testcase).Error; err != nil {
 		return models.Testcase{}, err
 	}
 	return testcase, nil
@@ -120,6 +223,21 @@ func GetPostStats(userMail string, postIDs []uuid.UUID) []models.PostStats {
                 FROM comments c 
                 WHERE c.post_id = p.id AND c.is_deleted = false
             ), 0) as comment_count,
+            (
+                SELECT COUNT(*)
+                FROM testcases t
+                WHERE t.post_id = p.id
+            ) as testcase_count,
+            (
+                SELECT COUNT(*)
+                FROM testcases t
+                WHERE t.post_id = p.id AND EXISTS (
+                    SELECT 1
+                    FROM student_run_testcases s
+                    WHERE s.student_mail = ? AND s.score = 1
+                      AND (s.testcase_id = t.id OR (s.testcase_id IS NULL AND s.post_id = t.id))
+                )
+            ) as passed_count,
             MAX(CASE WHEN i.user_mail = ? AND i.is_like = true THEN i.id::text END)::uuid as like_id,
             tvp.teacher_mail as verified_teacher_mail
         FROM posts p
@@ -127,7 +245,7 @@ func GetPostStats(userMail string, postIDs []uuid.UUID) []models.PostStats {
         LEFT JOIN teacher_verify_posts tvp ON p.id = tvp.post_id
         WHERE p.id IN (?)
         GROUP BY p.id, p.views, p.runs, tvp.teacher_mail
-    `, userMail, postIDs).Scan(&stats)
+    `, userMail, userMail, postIDs).Scan(&stats)
 	return stats
 }
 
@@ -140,95 +258,85 @@ func CreatePostFormData(c *fiber.Ctx) (*models.Post, error) {
 		return nil, fmt.Errorf("user email not found in context")
 	}
 
+	generateExpected := c.FormValue("generate_expected") == "true"
+	if role, _ := c.Locals("role").(string); generateExpected && role != "teacher" {
+		return nil, ErrGenerateExpectedForbidden
+	}
+
 	post.Title = c.FormValue("title")
 	post.Description = c.FormValue("description")
 	post.Subject = "KTLT"
 
-	testcase := new(models.Testcase)
-	file, err := c.FormFile("input")
-	if err == nil { // File tồn tại
-		// Mở file
-		fileHandle, err := file.Open()
-		if err != nil {
-			log.Printf("Failed to open uploaded file: %v", err)
-			return nil, fmt.Errorf("failed to open uploaded file: %v", err)
-		}
-		defer fileHandle.Close()
-
-		// Đọc nội dung file
-		fileContent, err := io.ReadAll(fileHandle)
-		if err != nil {
-			log.Printf("Failed to read uploaded file: %v", err)
-			return nil, fmt.Errorf("failed to read uploaded file: %v", err)
-		}
-
-		testcase.Input = string(fileContent)
-	} else if err != fiber.ErrBadRequest { // Chỉ log lỗi nếu không phải trường hợp file không được gửi
-		log.Printf("Failed to get supfile from form: %v", err)
+	assignment, err := GetAssignmentByParam(c.FormValue("assignment_id"))
+	if err != nil {
+		return nil, fmt.Errorf("assignment not found: %v", err)
+	}
+	if assignment.ID != uuid.Nil {
+		post.AssignmentID = &assignment.ID
+		post.Subject = assignment.Subject
 	}
-	testcase.Expected = c.FormValue("expected")
-	// testCode := c.FormValue("code")
-	// headers := `#include "main.h"
-	// #include "tc.h"
-	// #include "hcmcampaign.h"
 
-	// `
-	// testcase.Code = headers + testCode
-	testcase.Code = c.FormValue("code")
-	if testcase.Input != "" || testcase.Expected != "" || testcase.Code != "" {
-		post.Testcase = testcase
+	testcases, err := parseTestcasesForm(c)
+	if err != nil {
+		return nil, err
+	}
+	courseLimit, err := GetCourseLimit()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load course limits: %v", err)
+	}
+	for _, testcase := range testcases {
+		if err := ValidateTestcaseLimits(courseLimit, testcase); err != nil {
+			return nil, err
+		}
 	}
 
 	post.ID = uuid.New()
 	post.CreatedAt = time.Now()
 	post.LastModified = post.CreatedAt
 
-	if post.Testcase != nil {
-		post.Testcase.PostID = post.ID
+	for i := range testcases {
+		testcases[i].ID = uuid.New()
+		testcases[i].PostID = post.ID
+	}
+
+	// Chạy lời giải mẫu trong request tạo post bị giới hạn tổng thời gian,
+	// testcase chưa kịp chạy được đánh dấu chưa kiểm tra và có thể kiểm tra lại sau
+	referenceCtx, cancel := context.WithTimeout(c.UserContext(), ReferenceCreateTimeout)
+	defer cancel()
+
+	// Tác giả có thể để trống expected và lấy output của lời giải mẫu
+	if generateExpected {
+		if err := GenerateExpected(referenceCtx, assignment, testcases); err != nil {
+			return nil, err
+		}
 	}
+	post.Testcases = testcases
 
 	if err := database.DB.Db.Create(&post).Error; err != nil {
 		return nil, fmt.Errorf("failed to save post and testcase: %v", err)
 	}
 
-	// Upload testcase input lên Jobe server nếu có
-	if post.Testcase != nil && post.Testcase.Input != "" {
-		go func() {
-			fileName := strings.ReplaceAll(post.ID.String(), "-", "")
-			fileContents := []byte(post.Testcase.Input)
-			base64Contents := base64.StdEncoding.EncodeToString(fileContents)
-
-			requestData := models.UploadFileRequest{
-				FileContents: base64Contents,
-			}
-			jsonData, err := json.Marshal(requestData)
-			if err != nil {
-				log.Printf("Failed to marshal JSON for Jobe: %v", err)
-				return
-			}
-
-			url := fmt.Sprintf("http://jobe:80/jobe/index.php/restapi/files/%s", fileName)
-			req, err := http.NewRequest(http.MethodPut, url, bytes.NewBuffer(jsonData))
-			if err != nil {
-				log.Printf("Failed to create Jobe request: %v", err)
-				return
-			}
-
-			req.Header.Set("Content-Type", "application/json")
-			req.Header.Set("Accept", "application/json")
-
-			client := &http.Client{}
-			resp, err := client.Do(req)
-			if err != nil {
-				log.Printf("Failed to send request to Jobe: %v", err)
-				return
+	// Upload input của các testcase lên Jobe server nếu có
+	for _, testcase := range post.Testcases {
+		if testcase.Input == "" {
+			continue
+		}
+		go func(testcase models.Testcase) {
+			if err := UploadTestcaseInput(context.Background(), testcase); err != nil {
+				log.Printf("Failed to upload testcase input to Jobe: %v", err)
 			}
-			defer resp.Body.Close()
+		}(testcase)
+	}
 
-			if resp.StatusCode != http.StatusNoContent {
-				log.Printf("Jobe returned unexpected status: %d", resp.StatusCode)
+	// Chạy testcase với lời giải mẫu, kết quả được lưu vào từng testcase để báo cho tác giả.
+	// Testcase có expected sinh từ lời giải mẫu thì không cần chạy lại.
+	for _, testcase := range post.Testcases {
+		if testcase.ReferenceStatus != models.ReferenceStatusPassed {
+			if _, err := ValidatePostTestcases(referenceCtx, assignment, post); err != nil {
+				log.Printf("Failed to validate testcases against reference: %v", err)
 			}
-		}()
+			break
+		}
 	}
 
 	// Gọi Flask để trace nếu cần
@@ -247,6 +355,155 @@ func CreatePostFormData(c *fiber.Ctx) (*models.Post, error) {
 	return post, nil
 }
 
+// PreviewExpectedFormData đọc assignment và testcase từ form-data như CreatePostFormData,
+// chạy lời giải mẫu và trả về output mà không lưu post
+func PreviewExpectedFormData(c *fiber.Ctx) ([]models.ExpectedPreview, error) {
+	assignment, err := GetAssignmentByParam(c.FormValue("assignment_id"))
+	if err != nil {
+		return nil, fmt.Errorf("assignment not found: %v", err)
+	}
+	testcases, err := parseTestcasesForm(c)
+	if err != nil {
+		return nil, err
+	}
+	if len(testcases) == 0 {
+		return nil, fmt.Errorf("no testcase to preview")
+	}
+	courseLimit, err := GetCourseLimit()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load course limits: %v", err)
+	}
+	for i := range testcases {
+		if err := ValidateTestcaseLimits(courseLimit, testcases[i]); err != nil {
+			return nil, err
+		}
+		// ID tạm để upload input lên Jobe
+		testcases[i].ID = uuid.New()
+	}
+	return PreviewExpected(c.UserContext(), assignment, testcases)
+}
+
+// parseTestcasesForm đọc các testcase từ form-data. Testcase đầu tiên có thể gửi
+// theo các key cũ (input, expected, code), các testcase tiếp theo dùng hậu tố
+// _0, _1, ... (input_0, expected_0, code_0, name_0, weight_0).
+func parseTestcasesForm(c *fiber.Ctx) ([]models.Testcase, error) {
+	var testcases []models.Testcase
+
+	legacy, err := parseTestcaseForm(c, "")
+	if err != nil {
+		return nil, err
+	}
+	if legacy != nil {
+		testcases = append(testcases, *legacy)
+	}
+
+	for i := 0; ; i++ {
+		testcase, err := parseTestcaseForm(c, "_"+strconv.Itoa(i))
+		if err != nil {
+			return nil, err
+		}
+		if testcase == nil {
+			break
+		}
+		testcases = append(testcases, *testcase)
+	}
+
+	for i := range testcases {
+		testcases[i].Position = i
+	}
+	return testcases, nil
+}
+
+// parseTestcaseForm đọc một testcase với hậu tố key cho trước, trả về nil nếu không có dữ liệu
+func parseTestcaseForm(c *fiber.Ctx, suffix string) (*models.Testcase, error) {
+	testcase := &models.Testcase{
+		Name:     c.FormValue("name" + suffix),
+		Expected: c.FormValue("expected" + suffix),
+		Code:     c.FormValue("code" + suffix),
+		Weight:   1,
+	}
+
+	file, err := c.FormFile("input" + suffix)
+	if err == nil { // File tồn tại
+		// Mở file
+		fileHandle, err := file.Open()
+		if err != nil {
+			log.Printf("Failed to open uploaded file: %v", err)
+			return nil, fmt.Errorf("failed to open uploaded file: %v", err)
+		}
+		defer fileHandle.Close()
+
+		// Đọc nội dung file
+		fileContent, err := io.ReadAll(fileHandle)
+		if err != nil {
+			log.Printf("Failed to read uploaded file: %v", err)
+			return nil, fmt.Errorf("failed to read uploaded file: %v", err)
+		}
+
+		testcase.Input = string(fileContent)
+	} else {
+		testcase.Input = c.FormValue("input" + suffix)
+	}
+
+	if weight := c.FormValue("weight" + suffix); weight != "" {
+		value, err := strconv.ParseFloat(weight, 64)
+		if err != nil || value <= 0 {
+			return nil, fmt.Errorf("invalid weight%s: must be a positive number", suffix)
+		}
+		testcase.Weight = value
+	}
+
+	if testcase.Input == "" && testcase.Expected == "" && testcase.Code == "" {
+		return nil, nil
+	}
+
+	testcase.CompareMode = c.FormValue("compare_mode"+suffix, comparator.ModeExact)
+	if testcase.AbsoluteEpsilon, err = parseEpsilonForm(c, "absolute_epsilon"+suffix); err != nil {
+		return nil, err
+	}
+	if testcase.RelativeEpsilon, err = parseEpsilonForm(c, "relative_epsilon"+suffix); err != nil {
+		return nil, err
+	}
+	if testcase.MaxExecutionTime, err = parseLimitForm(c, "max_execution_time"+suffix); err != nil {
+		return nil, err
+	}
+	if testcase.MaxMemoryUsage, err = parseLimitForm(c, "max_memory_usage"+suffix); err != nil {
+		return nil, err
+	}
+
+	options := comparator.Options{AbsoluteEpsilon: testcase.AbsoluteEpsilon, RelativeEpsilon: testcase.RelativeEpsilon}
+	if err := comparator.Validate(testcase.CompareMode, options, testcase.Expected); err != nil {
+		return nil, fmt.Errorf("invalid compare_mode%s: %v", suffix, err)
+	}
+	return testcase, nil
+}
+
+// parseEpsilonForm đọc sai số cho chế độ so sánh numeric, để trống là 0
+func parseEpsilonForm(c *fiber.Ctx, key string) (float64, error) {
+	value := c.FormValue(key)
+	if value == "" {
+		return 0, nil
+	}
+	epsilon, err := strconv.ParseFloat(value, 64)
+	if err != nil || epsilon < 0 {
+		return 0, fmt.Errorf("invalid %s: must be a non-negative number", key)
+	}
+	return epsilon, nil
+}
+
+// parseLimitForm đọc giới hạn tùy chọn của testcase, để trống là nil
+func parseLimitForm(c *fiber.Ctx, key string) (*int, error) {
+	value := c.FormValue(key)
+	if value == "" {
+		return nil, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit <= 0 {
+		return nil, fmt.Errorf("invalid %s: must be a positive integer", key)
+	}
+	return &limit, nil
+}
+
 type HotPost struct {
 	ID       uuid.UUID `json:"id" gorm:"type:uuid;primaryKey"`
 	Title    string    `json:"title" gorm:"type:varchar(255);not null"`
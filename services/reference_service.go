@@ -0,0 +1,413 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tison2810/be-go-tc/comparator"
+	"github.com/tison2810/be-go-tc/database"
+	"github.com/tison2810/be-go-tc/diff"
+	"github.com/tison2810/be-go-tc/jobe"
+	"github.com/tison2810/be-go-tc/models"
+	"gorm.io/gorm"
+)
+
+// referenceFileIDPrefix phân biệt file lời giải mẫu với các file ID khác trên Jobe
+const referenceFileIDPrefix = "rs"
+
+// ReferenceCreateTimeout là tổng thời gian chạy lời giải mẫu trong một request tạo post
+const ReferenceCreateTimeout = 30 * time.Second
+
+var (
+	// ErrNoReferenceSolution được trả về khi cần lời giải mẫu nhưng assignment chưa có
+	ErrNoReferenceSolution = errors.New("assignment has no reference solution")
+	// ErrGenerateExpectedForbidden được trả về khi người không phải giáo viên dùng generate_expected.
+	// Output của lời giải mẫu chạy với code tùy ý có thể chứa chính mã nguồn lời giải mẫu.
+	ErrGenerateExpectedForbidden = errors.New("only teachers can generate expected output from the reference solution")
+)
+
+// ReferenceUpload là file lời giải mẫu giáo viên gửi lên cho một form key của assignment
+type ReferenceUpload struct {
+	FormKey  string
+	FileName string
+	Content  []byte
+}
+
+// SaveReferenceSolution lưu lời giải mẫu của assignment, thay lời giải cũ nếu có,
+// và upload file lên mọi Jobe server. Cần đủ file cho mọi file sinh viên bắt buộc của assignment.
+func SaveReferenceSolution(ctx context.Context, assignment models.Assignment, uploadedBy string, uploads []ReferenceUpload) (*models.ReferenceSolution, error) {
+	byKey := make(map[string]ReferenceUpload, len(uploads))
+	for _, upload := range uploads {
+		byKey[upload.FormKey] = upload
+	}
+
+	reference := &models.ReferenceSolution{
+		ID:           uuid.New(),
+		AssignmentID: assignment.ID,
+		UploadedBy:   uploadedBy,
+	}
+	for _, studentFile := range assignment.StudentFiles {
+		upload, ok := byKey[studentFile.FormKey]
+		if !ok {
+			if studentFile.Optional {
+				continue
+			}
+			return nil, fmt.Errorf("missing reference file %s", studentFile.FormKey)
+		}
+		if !HasAllowedExtension(studentFile, upload.FileName) {
+			return nil, fmt.Errorf("file %s must have extension %s", studentFile.FormKey, strings.Join(studentFile.Extensions, ", "))
+		}
+		sum := sha256.Sum256(upload.Content)
+		reference.Files = append(reference.Files, models.ReferenceFile{
+			ID:          uuid.New(),
+			ReferenceID: reference.ID,
+			FormKey:     studentFile.FormKey,
+			FileID:      referenceFileIDPrefix + newOpaqueID(),
+			FileName:    upload.FileName,
+			Content:     upload.Content,
+			SHA256:      hex.EncodeToString(sum[:]),
+			Size:        int64(len(upload.Content)),
+		})
+	}
+
+	err := database.DB.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("assignment_id = ?", assignment.ID).Delete(&models.ReferenceSolution{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(reference).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Lời giải đã được lưu nên lỗi từ Jobe chỉ được ghi log, file sẽ được upload lại khi chạy
+	for _, file := range reference.Files {
+		if err := jobePool.PutFile(ctx, file.FileID, file.Content); err != nil {
+			log.Printf("Failed to push reference file %s to Jobe: %v", file.FileID, err)
+		}
+	}
+	return reference, nil
+}
+
+// GetReferenceSolution trả về lời giải mẫu của assignment không kèm nội dung file, nil nếu chưa có
+func GetReferenceSolution(assignmentID uuid.UUID) (*models.ReferenceSolution, error) {
+	var reference models.ReferenceSolution
+	err := database.DB.Db.Preload("Files", func(db *gorm.DB) *gorm.DB { return db.Omit("content") }).
+		Where("assignment_id = ?", assignmentID).First(&reference).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &reference, nil
+}
+
+// DeleteReferenceSolution xóa lời giải mẫu của assignment
+func DeleteReferenceSolution(assignmentID uuid.UUID) error {
+	result := database.DB.Db.Where("assignment_id = ?", assignmentID).Delete(&models.ReferenceSolution{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// referenceRunner chạy testcase với lời giải mẫu thay cho file của sinh viên
+type referenceRunner struct {
+	assignment  models.Assignment // Đã áp dụng harness đang dùng
+	files       []RunFile
+	courseLimit models.CourseLimit
+}
+
+// newReferenceRunner chuẩn bị chạy lời giải mẫu của assignment, nil nếu assignment chưa có lời giải mẫu
+func newReferenceRunner(assignment models.Assignment) (*referenceRunner, error) {
+	reference, err := GetReferenceSolution(assignment.ID)
+	if err != nil || reference == nil {
+		return nil, err
+	}
+	harness, err := GetActiveHarness(assignment.ID)
+	if err != nil {
+		return nil, err
+	}
+	ApplyHarness(&assignment, harness)
+	files, err := referenceRunFiles(assignment, reference)
+	if err != nil {
+		return nil, err
+	}
+	courseLimit, err := GetCourseLimit()
+	if err != nil {
+		return nil, err
+	}
+	return &referenceRunner{assignment: assignment, files: files, courseLimit: courseLimit}, nil
+}
+
+func (r *referenceRunner) run(ctx context.Context, testcase models.Testcase) (*models.JobeRunResult, error) {
+	limits := ResolveRunLimits(r.courseLimit, r.assignment, testcase)
+	return jobePool.Run(ctx, BuildRunSpec(r.assignment, r.files, testcase, limits))
+}
+
+// CheckTestcasesAgainstReference chạy các testcase với lời giải mẫu của assignment.
+// Trả về nil nếu assignment chưa có lời giải mẫu.
+func CheckTestcasesAgainstReference(ctx context.Context, assignment models.Assignment, testcases []models.Testcase) ([]models.ReferenceCheck, error) {
+	runner, err := newReferenceRunner(assignment)
+	if err != nil || runner == nil {
+		return nil, err
+	}
+
+	checks := make([]models.ReferenceCheck, 0, len(testcases))
+	for _, testcase := range testcases {
+		check := models.ReferenceCheck{
+			TestcaseID: testcase.ID.String(),
+			Name:       testcase.Name,
+			Position:   testcase.Position,
+		}
+		jobeResult, err := runner.run(ctx, testcase)
+		if err != nil || IsJobeBusy(jobeResult, nil) {
+			// Không chạy được lời giải mẫu thì không kết luận testcase sai
+			if err == nil {
+				err = jobe.ErrOverloaded
+			}
+			log.Printf("Failed to run reference for testcase %s: %v", testcase.ID, err)
+			check.Status = models.ReferenceStatusUnchecked
+			check.Log = "Reference solution could not be run: " + err.Error()
+		} else {
+			evaluateReference(&check, testcase, jobeResult)
+		}
+		checks = append(checks, check)
+	}
+	return checks, nil
+}
+
+// PreviewExpected chạy lời giải mẫu với input và code của các testcase, trả về output để tác giả xem trước.
+// Testcase cần có ID, input được upload lên Jobe theo ID đó trước khi chạy.
+func PreviewExpected(ctx context.Context, assignment models.Assignment, testcases []models.Testcase) ([]models.ExpectedPreview, error) {
+	runner, err := newReferenceRunner(assignment)
+	if err != nil {
+		return nil, err
+	}
+	if runner == nil {
+		return nil, ErrNoReferenceSolution
+	}
+
+	previews := make([]models.ExpectedPreview, 0, len(testcases))
+	for _, testcase := range testcases {
+		previews = append(previews, runner.preview(ctx, testcase))
+	}
+	return previews, nil
+}
+
+// GenerateExpected điền expected cho các testcase để trống expected bằng output của lời giải mẫu.
+// Trả về lỗi nếu lời giải mẫu không chạy thành công với một testcase nào đó.
+func GenerateExpected(ctx context.Context, assignment models.Assignment, testcases []models.Testcase) error {
+	runner, err := newReferenceRunner(assignment)
+	if err != nil {
+		return err
+	}
+	if runner == nil {
+		return ErrNoReferenceSolution
+	}
+
+	for i := range testcases {
+		testcase := &testcases[i]
+		if testcase.Expected != "" {
+			continue
+		}
+		switch testcase.CompareMode {
+		case comparator.ModeRegex, comparator.ModeWildcard:
+			return fmt.Errorf("testcase %d: cannot generate expected for compare_mode %s", testcase.Position, testcase.CompareMode)
+		}
+
+		preview := runner.preview(ctx, *testcase)
+		if !preview.Generated {
+			return fmt.Errorf("testcase %d: reference solution failed (%s): %s", testcase.Position, preview.Verdict, preview.Log)
+		}
+		testcase.Expected = preview.Expected
+		testcase.ReferenceStatus = models.ReferenceStatusPassed
+		testcase.ReferenceVerdict = models.VerdictAccepted
+	}
+	return nil
+}
+
+// preview chạy một testcase chưa lưu với lời giải mẫu
+func (r *referenceRunner) preview(ctx context.Context, testcase models.Testcase) models.ExpectedPreview {
+	preview := models.ExpectedPreview{
+		Position: testcase.Position,
+		Name:     testcase.Name,
+	}
+
+	// Testcase chưa được lưu nên Pool không thể lấy lại input từ database, phải upload trước
+	if r.assignment.InputFilename != "" {
+		if err := UploadTestcaseInput(ctx, testcase); err != nil {
+			preview.Log = "Failed to upload input to Jobe: " + err.Error()
+			return preview
+		}
+	}
+
+	jobeResult, err := r.run(ctx, testcase)
+	if err != nil || IsJobeBusy(jobeResult, nil) {
+		if err == nil {
+			err = jobe.ErrOverloaded
+		}
+		preview.Log = "Reference solution could not be run: " + err.Error()
+		return preview
+	}
+
+	// Không có expected để so sánh nên chỉ xét chương trình có chạy xong hay không
+	preview.Verdict = jobe.Verdict(jobeResult, true)
+	switch preview.Verdict {
+	case models.VerdictAccepted:
+		preview.Generated = true
+		preview.Expected = jobeResult.Stdout
+	case models.VerdictCompileError:
+		preview.Log = jobeResult.Cmpinfo
+	default:
+		preview.Log = preview.Verdict.Description()
+		if jobeResult.Stderr != "" {
+			preview.Log += "\n" + jobeResult.Stderr
+		}
+	}
+	return preview
+}
+
+// evaluateReference so sánh output của lời giải mẫu với expected của testcase
+func evaluateReference(check *models.ReferenceCheck, testcase models.Testcase, jobeResult *models.JobeRunResult) {
+	matched := TestcaseComparator(testcase).Compare(testcase.Expected, jobeResult.Stdout)
+	check.Verdict = jobe.Verdict(jobeResult, matched)
+
+	switch check.Verdict {
+	case models.VerdictAccepted:
+		check.Status = models.ReferenceStatusPassed
+	case models.VerdictCompileError:
+		check.Status = models.ReferenceStatusCompileError
+		check.Log = jobeResult.Cmpinfo
+	case models.VerdictSandboxError, models.VerdictOverload:
+		check.Status = models.ReferenceStatusUnchecked
+		check.Log = check.Verdict.Description()
+	case models.VerdictWrongAnswer:
+		check.Status = models.ReferenceStatusFailed
+		check.Diff = diff.Compute(strings.TrimSpace(testcase.Expected), strings.TrimSpace(jobeResult.Stdout))
+	default:
+		check.Status = models.ReferenceStatusFailed
+		check.Log = check.Verdict.Description()
+		if jobeResult.Stderr != "" {
+			check.Log += "\n" + jobeResult.Stderr
+		}
+	}
+}
+
+// ValidatePostTestcases chạy các testcase của post với lời giải mẫu và lưu kết quả vào từng testcase.
+// Với post của sinh viên chỉ verdict được lưu và trả về, xem redactReferenceCheck.
+func ValidatePostTestcases(ctx context.Context, assignment models.Assignment, post *models.Post) ([]models.ReferenceCheck, error) {
+	checks, err := CheckTestcasesAgainstReference(ctx, assignment, post.Testcases)
+	if err != nil {
+		return nil, err
+	}
+	if !isTeacher(post.UserMail) {
+		for i := range checks {
+			redactReferenceCheck(&checks[i])
+		}
+	}
+
+	for i := range post.Testcases {
+		testcase := &post.Testcases[i]
+		testcase.ReferenceStatus = models.ReferenceStatusUnchecked
+		testcase.ReferenceVerdict = ""
+		testcase.ReferenceLog = ""
+		testcase.ReferenceDiff = nil
+		if i < len(checks) {
+			testcase.ReferenceStatus = checks[i].Status
+			testcase.ReferenceVerdict = checks[i].Verdict
+			testcase.ReferenceLog = checks[i].Log
+			testcase.ReferenceDiff = checks[i].Diff
+		}
+		if err := database.DB.Db.Model(testcase).Select("reference_status", "reference_verdict", "reference_log", "reference_diff").
+			Updates(testcase).Error; err != nil {
+			return nil, err
+		}
+	}
+	return checks, nil
+}
+
+// redactReferenceCheck bỏ log và diff khỏi kết quả kiểm tra, chỉ giữ status và verdict.
+// Code của testcase được biên dịch cùng lời giải mẫu nên output, stderr và lỗi biên dịch
+// có thể chứa mã nguồn lời giải mẫu nếu testcase cố tình in ra.
+func redactReferenceCheck(check *models.ReferenceCheck) {
+	if check.Status == models.ReferenceStatusUnchecked {
+		return // Log chỉ là lỗi khi gửi tới Jobe
+	}
+	check.Log = ""
+	check.Diff = nil
+}
+
+// isTeacher cho biết email có phải của giáo viên không, lỗi khi tra cứu được coi là không phải
+func isTeacher(email string) bool {
+	user, err := FindUserByEmail(database.DB.Db, email)
+	if err != nil {
+		log.Printf("Failed to look up user %s: %v", email, err)
+		return false
+	}
+	return user != nil && user.Role == "teacher"
+}
+
+// ReferenceFailures trả về kết quả kiểm tra của các testcase không qua được lời giải mẫu
+func ReferenceFailures(testcases []models.Testcase) []models.ReferenceCheck {
+	var failures []models.ReferenceCheck
+	for _, testcase := range testcases {
+		if testcase.ReferenceStatus != models.ReferenceStatusFailed && testcase.ReferenceStatus != models.ReferenceStatusCompileError {
+			continue
+		}
+		failures = append(failures, models.ReferenceCheck{
+			TestcaseID: testcase.ID.String(),
+			Name:       testcase.Name,
+			Position:   testcase.Position,
+			Status:     testcase.ReferenceStatus,
+			Verdict:    testcase.ReferenceVerdict,
+			Log:        testcase.ReferenceLog,
+			Diff:       testcase.ReferenceDiff,
+		})
+	}
+	return failures
+}
+
+// referenceRunFiles trả về file của lời giải mẫu theo thứ tự file sinh viên của assignment
+func referenceRunFiles(assignment models.Assignment, reference *models.ReferenceSolution) ([]RunFile, error) {
+	byKey := make(map[string]string, len(reference.Files))
+	for _, file := range reference.Files {
+		byKey[file.FormKey] = file.FileID
+	}
+	files := make([]RunFile, 0, len(assignment.StudentFiles))
+	for _, studentFile := range assignment.StudentFiles {
+		fileID, ok := byKey[studentFile.FormKey]
+		if !ok {
+			if studentFile.Optional {
+				continue
+			}
+			return nil, fmt.Errorf("reference solution has no file for %s, upload it again", studentFile.FormKey)
+		}
+		files = append(files, RunFile{FileID: fileID, FileName: studentFile.FileName})
+	}
+	return files, nil
+}
+
+// referenceFileSource cho phép Pool upload lại file lời giải mẫu lên node bị thiếu file
+func referenceFileSource(ctx context.Context, fileID string) ([]byte, bool, error) {
+	var file models.ReferenceFile
+	err := database.DB.Db.WithContext(ctx).Select("file_id", "content").First(&file, "file_id = ?", fileID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return file.Content, true, nil
+}
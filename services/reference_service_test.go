@@ -0,0 +1,52 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tison2810/be-go-tc/jobe"
+	"github.com/tison2810/be-go-tc/models"
+)
+
+func TestEvaluateReferenceRedacted(t *testing.T) {
+	// Testcase của sinh viên in mã nguồn lời giải mẫu ra stdout và stderr
+	leaked := "int Army::fight() { return secret; }"
+	testcase := models.Testcase{Expected: "42"}
+	tests := []struct {
+		name   string
+		result models.JobeRunResult
+		status string
+	}{
+		{"wrong answer", models.JobeRunResult{Outcome: jobe.OutcomeSuccess, Stdout: leaked}, models.ReferenceStatusFailed},
+		{"runtime error", models.JobeRunResult{Outcome: jobe.OutcomeRuntimeError, Stderr: leaked}, models.ReferenceStatusFailed},
+		{"compile error", models.JobeRunResult{Outcome: jobe.OutcomeCompileError, Cmpinfo: "hcmcampaign.cpp:3: " + leaked}, models.ReferenceStatusCompileError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var check models.ReferenceCheck
+			evaluateReference(&check, testcase, &tt.result)
+			if check.Status != tt.status {
+				t.Fatalf("status = %s, want %s", check.Status, tt.status)
+			}
+			if !strings.Contains(check.Log, leaked) && (check.Diff == nil || !strings.Contains(check.Diff.Unified, leaked)) {
+				t.Fatalf("unredacted check does not contain the output: %+v", check)
+			}
+
+			verdict := check.Verdict
+			redactReferenceCheck(&check)
+			if check.Log != "" || check.Diff != nil {
+				t.Errorf("redacted check still has output: log %q, diff %+v", check.Log, check.Diff)
+			}
+			if check.Status != tt.status || check.Verdict != verdict {
+				t.Errorf("redacted check = %s/%s, want %s/%s", check.Status, check.Verdict, tt.status, verdict)
+			}
+		})
+	}
+
+	// Lỗi khi gửi tới Jobe không chứa output nên được giữ lại
+	unchecked := models.ReferenceCheck{Status: models.ReferenceStatusUnchecked, Log: "Reference solution could not be run: jobe: server overloaded"}
+	redactReferenceCheck(&unchecked)
+	if unchecked.Log == "" {
+		t.Error("redactReferenceCheck removed the log of an unchecked testcase")
+	}
+}
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tison2810/be-go-tc/database"
+	"github.com/tison2810/be-go-tc/jobe"
+	"github.com/tison2810/be-go-tc/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// runCacheEnabled cho phép tắt cache bằng RUN_CACHE=off, ví dụ khi cần đo lại thời gian chạy
+var runCacheEnabled = os.Getenv("RUN_CACHE") != "off"
+
+// TestcaseHash trả về hash của các trường ảnh hưởng tới kết quả chấm của testcase
+func TestcaseHash(testcase models.Testcase) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00%s\x00%g\x00%g",
+		testcase.Code, testcase.Input, testcase.Expected,
+		testcase.CompareMode, testcase.AbsoluteEpsilon, testcase.RelativeEpsilon)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// RunCacheKey trả về key cache cho một lần chạy testcase, chuỗi rỗng nếu không thể cache.
+// Key gồm hash nội dung của các file trong run_spec (file sinh viên, harness và file qua proxy),
+// hash testcase, phiên bản harness và run_spec (code, giới hạn, tham số biên dịch).
+// Run dùng file không có nội dung trong database, ví dụ file hệ thống systemmainh được quản lý tay
+// trên Jobe, không được cache vì sửa file đó không làm đổi key.
+func RunCacheKey(testcase models.Testcase, runSpec models.RunSpec, harnessID *uuid.UUID) string {
+	if !runCacheEnabled {
+		return ""
+	}
+	fileIDs := jobe.FileListIDs(runSpec.FileList)
+	fileHashes, err := runFileHashes(fileIDs)
+	if err != nil {
+		log.Printf("Failed to hash run files: %v", err)
+		return ""
+	}
+	inputFileID := TestcaseInputFileID(testcase)
+	for _, fileID := range fileIDs {
+		// Input của testcase đã nằm trong hash testcase
+		if _, ok := fileHashes[fileID]; !ok && fileID != inputFileID {
+			return ""
+		}
+	}
+	spec, err := json.Marshal(runSpec)
+	if err != nil {
+		return ""
+	}
+
+	h := sha256.New()
+	for _, fileID := range fileIDs {
+		fmt.Fprintf(h, "file\x00%s\x00%s\x00", fileID, fileHashes[fileID])
+	}
+	fmt.Fprintf(h, "testcase\x00%s\x00", TestcaseHash(testcase))
+	if harnessID != nil {
+		fmt.Fprintf(h, "harness\x00%s\x00", harnessID)
+	}
+	h.Write(spec)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// runFileHashes lấy SHA-256 của các file đã lưu trong database theo file ID,
+// file không có trong database không có trong map
+func runFileHashes(fileIDs []string) (map[string]string, error) {
+	hashes := make(map[string]string, len(fileIDs))
+	studentFiles, err := GetStudentFiles(fileIDs)
+	if err != nil {
+		return nil, err
+	}
+	for id, file := range studentFiles {
+		hashes[id] = file.SHA256
+	}
+
+	var jobeFiles []models.JobeFile
+	if err := database.DB.Db.Select("id", "sha256").Where("id IN ?", fileIDs).Find(&jobeFiles).Error; err != nil {
+		return nil, err
+	}
+	for _, file := range jobeFiles {
+		hashes[file.ID] = file.SHA256
+	}
+
+	var harnessFiles []models.HarnessFile
+	if err := database.DB.Db.Select("file_id", "sha256").Where("file_id IN ?", fileIDs).Find(&harnessFiles).Error; err != nil {
+		return nil, err
+	}
+	for _, file := range harnessFiles {
+		hashes[file.FileID] = file.SHA256
+	}
+	return hashes, nil
+}
+
+// cacheableVerdict cho biết verdict có phản ánh đúng code của sinh viên hay không.
+// Lỗi sandbox, Jobe quá tải, TLE (phụ thuộc tải của Jobe lúc chạy) và RE (lỗi do bộ nhớ chưa khởi tạo
+// hay undefined behavior thường không lặp lại) không được cache.
+func cacheableVerdict(verdict models.Verdict) bool {
+	switch verdict {
+	case models.VerdictSandboxError, models.VerdictOverload, models.VerdictTimeLimit, models.VerdictRuntimeError:
+		return false
+	}
+	return true
+}
+
+// LookupRunCache trả về kết quả đã cache của key, nil nếu chưa có
+func LookupRunCache(key string) *models.RunCacheEntry {
+	if key == "" {
+		return nil
+	}
+	var entry models.RunCacheEntry
+	if err := database.DB.Db.First(&entry, "key = ?", key).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Failed to read run cache: %v", err)
+		}
+		return nil
+	}
+	if !cacheableVerdict(entry.Verdict) {
+		return nil // Entry được lưu trước khi verdict này bị loại khỏi cache
+	}
+
+	now := time.Now()
+	if err := database.DB.Db.Model(&entry).Updates(map[string]interface{}{
+		"hits":        gorm.Expr("hits + 1"),
+		"last_hit_at": now,
+	}).Error; err != nil {
+		log.Printf("Failed to update run cache hits: %v", err)
+	}
+	return &entry
+}
+
+// StoreRunCache lưu kết quả chấm vào cache nếu verdict được phép cache
+func StoreRunCache(key string, testcase models.Testcase, harnessID *uuid.UUID, stdout string, studentRun *models.StudentRunTestcase) {
+	if key == "" || !cacheableVerdict(studentRun.Verdict) {
+		return
+	}
+
+	entry := models.RunCacheEntry{
+		Key:        key,
+		TestcaseID: testcase.ID,
+		HarnessID:  harnessID,
+		Stdout:     stdout,
+		Score:      studentRun.Score,
+		Verdict:    studentRun.Verdict,
+		Log:        studentRun.Log,
+		Diff:       studentRun.Diff,
+		Findings:   studentRun.Findings,
+	}
+	if err := database.DB.Db.Clauses(clause.OnConflict{DoNothing: true}).Create(&entry).Error; err != nil {
+		log.Printf("Failed to store run cache: %v", err)
+	}
+}
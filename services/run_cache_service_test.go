@@ -0,0 +1,60 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/tison2810/be-go-tc/models"
+)
+
+func TestCacheableVerdict(t *testing.T) {
+	tests := []struct {
+		verdict models.Verdict
+		want    bool
+	}{
+		{models.VerdictAccepted, true},
+		{models.VerdictWrongAnswer, true},
+		{models.VerdictCompileError, true},
+		{models.VerdictMemoryLimit, true},
+		{models.VerdictIllegal, true},
+		// Kết quả có thể khác ở lần chạy sau với cùng code
+		{models.VerdictRuntimeError, false},
+		{models.VerdictTimeLimit, false},
+		{models.VerdictSandboxError, false},
+		{models.VerdictOverload, false},
+	}
+	for _, tt := range tests {
+		if got := cacheableVerdict(tt.verdict); got != tt.want {
+			t.Errorf("cacheableVerdict(%s) = %v, want %v", tt.verdict, got, tt.want)
+		}
+	}
+}
+
+func TestTestcaseHash(t *testing.T) {
+	base := models.Testcase{Code: "int main() {}", Input: "1 2", Expected: "3", CompareMode: "exact"}
+	hash := TestcaseHash(base)
+	if hash != TestcaseHash(base) {
+		t.Fatal("TestcaseHash is not deterministic")
+	}
+
+	// Các trường không ảnh hưởng kết quả chấm không làm đổi hash
+	renamed := base
+	renamed.Name, renamed.Position = "renamed", 3
+	if TestcaseHash(renamed) != hash {
+		t.Error("hash changed with the testcase name")
+	}
+
+	for name, change := range map[string]func(*models.Testcase){
+		"code":             func(tc *models.Testcase) { tc.Code += " " },
+		"input":            func(tc *models.Testcase) { tc.Input = "1 3" },
+		"expected":         func(tc *models.Testcase) { tc.Expected = "4" },
+		"compare mode":     func(tc *models.Testcase) { tc.CompareMode = "numeric" },
+		"absolute epsilon": func(tc *models.Testcase) { tc.AbsoluteEpsilon = 1e-6 },
+		"relative epsilon": func(tc *models.Testcase) { tc.RelativeEpsilon = 1e-6 },
+	} {
+		changed := base
+		change(&changed)
+		if TestcaseHash(changed) == hash {
+			t.Errorf("hash did not change with the %s", name)
+		}
+	}
+}
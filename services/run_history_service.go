@@ -0,0 +1,99 @@
+package services
+
+import (
+	"github.com/google/uuid"
+	"github.com/tison2810/be-go-tc/database"
+	"github.com/tison2810/be-go-tc/models"
+)
+
+const (
+	DefaultRunHistoryPageSize = 20
+	MaxRunHistoryPageSize     = 100
+)
+
+// RunHistoryFilter lọc lịch sử chạy theo post và verdict, Page bắt đầu từ 1
+type RunHistoryFilter struct {
+	PostID   *uuid.UUID
+	Verdict  models.Verdict
+	Page     int
+	PageSize int
+}
+
+// ListStudentRuns trả về lịch sử chạy testcase của sinh viên, mới nhất trước
+func ListStudentRuns(studentMail string, filter RunHistoryFilter) (*models.RunHistoryPage, error) {
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	if filter.PageSize < 1 {
+		filter.PageSize = DefaultRunHistoryPageSize
+	}
+	if filter.PageSize > MaxRunHistoryPageSize {
+		filter.PageSize = MaxRunHistoryPageSize
+	}
+
+	query := database.DB.Db.Model(&models.StudentRunTestcase{}).Where("student_mail = ?", studentMail)
+	if filter.PostID != nil {
+		query = query.Where("post_id = ?", *filter.PostID)
+	}
+	if filter.Verdict != "" {
+		query = query.Where("verdict = ?", filter.Verdict)
+	}
+
+	page := &models.RunHistoryPage{
+		Runs:     []models.StudentRunTestcase{},
+		Page:     filter.Page,
+		PageSize: filter.PageSize,
+	}
+	if err := query.Count(&page.Total).Error; err != nil {
+		return nil, err
+	}
+	if err := query.Order("time DESC, id").
+		Offset((filter.Page - 1) * filter.PageSize).Limit(filter.PageSize).
+		Find(&page.Runs).Error; err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// GetBestResults trả về lần nộp tốt nhất của sinh viên trên từng post, nil postIDs là mọi post.
+// Các testcase chạy trong cùng một lần bấm run được gộp theo submission_id, dòng cũ không có
+// submission_id được tính là một lần nộp riêng.
+func GetBestResults(studentMail string, postIDs []uuid.UUID) ([]models.BestResult, error) {
+	filter := ""
+	args := []interface{}{studentMail}
+	if postIDs != nil {
+		filter = "AND post_id IN (?)"
+		args = append(args, postIDs)
+	}
+
+	results := []models.BestResult{}
+	err := database.DB.Db.Raw(`
+        WITH submissions AS (
+            SELECT
+                post_id,
+                COALESCE(submission_id, id) as submission_id,
+                SUM(weight * score) as weighted_score,
+                SUM(weight) as max_score,
+                BOOL_AND(score = 1) as passed,
+                MAX(time) as run_at
+            FROM student_run_testcases
+            WHERE student_mail = ? `+filter+`
+            GROUP BY post_id, COALESCE(submission_id, id)
+        )
+        SELECT DISTINCT ON (post_id)
+            post_id,
+            submission_id,
+            weighted_score,
+            max_score,
+            passed,
+            run_at as best_run_at,
+            COUNT(*) OVER (PARTITION BY post_id) as attempts,
+            MAX(run_at) OVER (PARTITION BY post_id) as last_run_at
+        FROM submissions
+        ORDER BY post_id, weighted_score DESC, run_at ASC
+    `, args...).Scan(&results).Error
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
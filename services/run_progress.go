@@ -0,0 +1,24 @@
+package services
+
+import (
+	"context"
+
+	"github.com/tison2810/be-go-tc/models"
+)
+
+type runProgressKey struct{}
+
+// RunProgressFunc nhận các event tiến trình trong lúc RunSubmission chạy
+type RunProgressFunc func(models.RunEvent)
+
+// WithRunProgress gắn hàm nhận tiến trình vào context truyền cho RunSubmission
+func WithRunProgress(ctx context.Context, progress RunProgressFunc) context.Context {
+	return context.WithValue(ctx, runProgressKey{}, progress)
+}
+
+// reportProgress gửi event tới hàm nhận tiến trình trong context nếu có
+func reportProgress(ctx context.Context, event models.RunEvent) {
+	if progress, ok := ctx.Value(runProgressKey{}).(RunProgressFunc); ok {
+		progress(event)
+	}
+}
@@ -0,0 +1,278 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tison2810/be-go-tc/database"
+	"github.com/tison2810/be-go-tc/jobe"
+	"github.com/tison2810/be-go-tc/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	runQueueMaxAttempts  = 10
+	runQueueMaxBackoff   = time.Minute
+	runQueuePollInterval = 2 * time.Second
+	runQueueJobTimeout   = 30 * time.Second
+)
+
+// RunQueue là worker pool lấy các run đang chờ trong bảng runs và gửi tới Jobe
+type RunQueue struct {
+	workers     int
+	notify      chan struct{}
+	postService *PostService
+}
+
+var runQueue *RunQueue
+
+// StartRunQueue khởi động worker pool xử lý bảng runs
+func StartRunQueue(workers int) *RunQueue {
+	if workers <= 0 {
+		workers = 1
+	}
+	q := &RunQueue{
+		workers:     workers,
+		notify:      make(chan struct{}, workers),
+		postService: NewPostService(),
+	}
+
+	// Các run đang chạy dở khi server tắt sẽ được chạy lại
+	if err := database.DB.Db.Model(&models.Run{}).
+		Where("state = ?", models.RunStateRunning).
+		Update("state", models.RunStateQueued).Error; err != nil {
+		log.Printf("Failed to requeue interrupted runs: %v", err)
+	}
+
+	for i := 0; i < workers; i++ {
+		go q.work()
+	}
+	runQueue = q
+	return q
+}
+
+// IsJobeBusy cho biết Jobe đã xếp run vào hàng đợi riêng, đang quá tải, không còn node nào khỏe
+// hoặc đã đạt giới hạn run đồng thời
+func IsJobeBusy(jobeResult *models.JobeRunResult, err error) bool {
+	if err != nil {
+		return errors.Is(err, jobe.ErrQueued) || errors.Is(err, jobe.ErrOverloaded) ||
+			errors.Is(err, jobe.ErrNoHealthyNode) || errors.Is(err, jobe.ErrTooManyRuns)
+	}
+	return jobeResult.Outcome == jobe.OutcomeServerOverload
+}
+
+// EnqueueRun lưu run_spec của testcase vào hàng đợi để worker gửi lại tới Jobe sau
+func EnqueueRun(testcase models.Testcase, studentMail string, submissionID uuid.UUID, runSpec models.RunSpec) (*models.Run, error) {
+	run := models.Run{
+		ID:            uuid.New(),
+		PostID:        testcase.PostID,
+		TestcaseID:    &testcase.ID,
+		SubmissionID:  &submissionID,
+		StudentMail:   studentMail,
+		State:         models.RunStateQueued,
+		RunSpec:       runSpec,
+		NextAttemptAt: time.Now(),
+	}
+	if err := database.DB.Db.Create(&run).Error; err != nil {
+		return nil, err
+	}
+
+	if runQueue != nil {
+		select {
+		case runQueue.notify <- struct{}{}:
+		default:
+		}
+	}
+	return &run, nil
+}
+
+// GetRunQueueStats trả về số run đang chờ, đang chạy trong hàng đợi và số run đang gửi tới Jobe
+func GetRunQueueStats() (models.RunQueueStats, error) {
+	var stats models.RunQueueStats
+	if runQueue != nil {
+		stats.Workers = runQueue.workers
+	}
+	stats.JobeInFlight, stats.JobeCapacity = jobePool.InFlight()
+
+	var counts []struct {
+		State string
+		Count int64
+	}
+	if err := database.DB.Db.Model(&models.Run{}).
+		Select("state, COUNT(*) AS count").
+		Where("state IN ?", []string{models.RunStateQueued, models.RunStateRunning}).
+		Group("state").Scan(&counts).Error; err != nil {
+		return stats, err
+	}
+	for _, count := range counts {
+		switch count.State {
+		case models.RunStateQueued:
+			stats.Queued = count.Count
+		case models.RunStateRunning:
+			stats.Running = count.Count
+		}
+	}
+	return stats, nil
+}
+
+// GetRun lấy run theo ID
+func GetRun(id uuid.UUID) (*models.Run, error) {
+	var run models.Run
+	if err := database.DB.Db.First(&run, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+func (q *RunQueue) work() {
+	ticker := time.NewTicker(runQueuePollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Xử lý hết các run đến hạn rồi mới chờ tín hiệu tiếp theo
+		for {
+			run, err := q.claim()
+			if err != nil {
+				log.Printf("Failed to claim queued run: %v", err)
+				break
+			}
+			if run == nil {
+				break
+			}
+			q.process(run)
+		}
+
+		select {
+		case <-q.notify:
+		case <-ticker.C:
+		}
+	}
+}
+
+// claim chọn một run đến hạn và chuyển sang trạng thái running
+func (q *RunQueue) claim() (*models.Run, error) {
+	var run models.Run
+	err := database.DB.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("state = ? AND next_attempt_at <= ?", models.RunStateQueued, time.Now()).
+			Order("created_at").
+			First(&run).Error; err != nil {
+			return err
+		}
+		run.State = models.RunStateRunning
+		run.Attempts++
+		return tx.Save(&run).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+func (q *RunQueue) process(run *models.Run) {
+	ctx, cancel := context.WithTimeout(context.Background(), runQueueJobTimeout)
+	defer cancel()
+
+	jobeResult, err := jobePool.Run(ctx, run.RunSpec)
+	if err != nil || IsJobeBusy(jobeResult, nil) {
+		if err == nil {
+			err = jobe.ErrOverloaded
+		}
+		if isRetryableJobeError(err) && run.Attempts < runQueueMaxAttempts {
+			run.State = models.RunStateQueued
+			run.NextAttemptAt = time.Now().Add(runQueueBackoff(run.Attempts))
+			run.Error = err.Error()
+			q.save(run)
+			return
+		}
+		run.State = models.RunStateFailed
+		run.Error = err.Error()
+		q.save(run)
+		return
+	}
+
+	testcase, err := q.testcaseFor(run)
+	if err != nil {
+		run.State = models.RunStateFailed
+		run.Error = "Error retrieving testcase: " + err.Error()
+		q.save(run)
+		return
+	}
+
+	submissionID := run.ID
+	if run.SubmissionID != nil {
+		submissionID = *run.SubmissionID
+	}
+	// Run trong hàng đợi dùng file đang có trên Jobe lúc chạy nên lấy phiên bản đang dùng tại thời điểm này
+	source := RunSourceForSpec(run.StudentMail, submissionID, run.RunSpec)
+	run.UploadID = source.UploadID
+	run.HarnessID = source.HarnessID
+	studentRun, err := q.postService.CheckRunResult(testcase, run.StudentMail, source, jobeResult)
+	if err != nil {
+		run.State = models.RunStateFailed
+		run.Error = "Error checking run result: " + err.Error()
+		q.save(run)
+		return
+	}
+	StoreRunCache(RunCacheKey(testcase, run.RunSpec, source.HarnessID), testcase, source.HarnessID, jobeResult.Stdout, studentRun)
+
+	run.State = models.RunStateDone
+	run.Error = ""
+	run.Result = jobeResult.Stdout
+	run.Score = studentRun.Score
+	run.Log = studentRun.Log
+	run.Verdict = studentRun.Verdict
+	run.Diff = studentRun.Diff
+	run.Findings = studentRun.Findings
+	run.StudentRunID = &studentRun.ID
+	q.save(run)
+}
+
+// testcaseFor lấy testcase của run, run cũ không có testcase_id dùng testcase đầu tiên của post
+func (q *RunQueue) testcaseFor(run *models.Run) (models.Testcase, error) {
+	if run.TestcaseID != nil {
+		return GetTestcase(*run.TestcaseID)
+	}
+	testcases, err := GetTestcasesByPostID(run.PostID)
+	if err != nil {
+		return models.Testcase{}, err
+	}
+	if len(testcases) == 0 {
+		return models.Testcase{}, gorm.ErrRecordNotFound
+	}
+	return testcases[0], nil
+}
+
+func (q *RunQueue) save(run *models.Run) {
+	if err := database.DB.Db.Save(run).Error; err != nil {
+		log.Printf("Failed to update run %s: %v", run.ID, err)
+	}
+}
+
+// isRetryableJobeError cho biết Jobe có đang bận hay không để thử lại sau.
+// Gồm mọi lỗi mà IsJobeBusy dùng để xếp run vào hàng đợi, kể cả khi mọi node đang bị loại chờ health check,
+// và lỗi của node (mất kết nối, lỗi 500) vì lần thử sau pool sẽ chọn node khác còn khỏe.
+func isRetryableJobeError(err error) bool {
+	return errors.Is(err, jobe.ErrQueued) ||
+		errors.Is(err, jobe.ErrOverloaded) ||
+		errors.Is(err, jobe.ErrNoHealthyNode) ||
+		errors.Is(err, jobe.ErrTooManyRuns) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		jobe.IsNodeFailure(err)
+}
+
+// runQueueBackoff tăng thời gian chờ theo cấp số nhân, tối đa runQueueMaxBackoff
+func runQueueBackoff(attempts int) time.Duration {
+	backoff := time.Second << uint(attempts)
+	if backoff <= 0 || backoff > runQueueMaxBackoff {
+		return runQueueMaxBackoff
+	}
+	return backoff
+}
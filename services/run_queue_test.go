@@ -0,0 +1,78 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/tison2810/be-go-tc/jobe"
+	"github.com/tison2810/be-go-tc/jobe/jobetest"
+	"github.com/tison2810/be-go-tc/models"
+)
+
+func TestQueuedErrorsAreRetried(t *testing.T) {
+	// Mọi lỗi làm run bị xếp vào hàng đợi phải được worker thử lại thay vì đánh dấu failed
+	for _, err := range []error{
+		jobe.ErrQueued,
+		jobe.ErrOverloaded,
+		jobe.ErrNoHealthyNode,
+		jobe.ErrTooManyRuns,
+		fmt.Errorf("jobe: run: %w", jobe.ErrNoHealthyNode),
+	} {
+		if !IsJobeBusy(nil, err) {
+			t.Errorf("IsJobeBusy(%v) = false", err)
+		}
+		if !isRetryableJobeError(err) {
+			t.Errorf("isRetryableJobeError(%v) = false for an error that queues the run", err)
+		}
+	}
+
+	if !isRetryableJobeError(context.DeadlineExceeded) {
+		t.Error("timeouts should be retried")
+	}
+	for _, err := range []error{
+		jobe.ErrBadRequest,
+		jobe.ErrNotFound,
+		&jobe.StatusError{Op: "run", StatusCode: http.StatusBadRequest},
+		fmt.Errorf("%w: unexpected end of JSON input", jobe.ErrInvalidResponse),
+	} {
+		if isRetryableJobeError(err) {
+			t.Errorf("isRetryableJobeError(%v) = true, want false", err)
+		}
+	}
+}
+
+func TestNodeFailuresAreRetried(t *testing.T) {
+	failing := jobetest.NewServer()
+	defer failing.Close()
+	failing.SetRunFunc(func(models.RunSpec, map[string][]byte) (int, models.JobeRunResult) {
+		return http.StatusInternalServerError, models.JobeRunResult{}
+	})
+	down := jobetest.NewServer()
+	down.Close()
+	spec := models.RunSpec{LanguageID: "cpp", SourceCode: "int main() {}"}
+
+	// Lỗi thật mà pool trả về khi node lỗi 500 hoặc không kết nối được
+	for name, client := range map[string]*jobe.Client{
+		"server error":       failing.JobeClient(),
+		"connection refused": down.JobeClient(),
+	} {
+		_, err := jobe.NewPool(client).Run(context.Background(), spec)
+		if err == nil {
+			t.Fatalf("%s: Run succeeded", name)
+		}
+		if !isRetryableJobeError(err) {
+			t.Errorf("%s: isRetryableJobeError(%v) = false, the run should wait for a healthy node", name, err)
+		}
+	}
+
+	rejecting := jobetest.NewServer()
+	defer rejecting.Close()
+	rejecting.SetRunFunc(func(models.RunSpec, map[string][]byte) (int, models.JobeRunResult) {
+		return http.StatusBadRequest, models.JobeRunResult{}
+	})
+	if _, err := jobe.NewPool(rejecting.JobeClient()).Run(context.Background(), spec); err == nil || isRetryableJobeError(err) {
+		t.Errorf("bad request: isRetryableJobeError(%v) = true, want false", err)
+	}
+}
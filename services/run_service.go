@@ -0,0 +1,295 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/tison2810/be-go-tc/jobe"
+	"github.com/tison2810/be-go-tc/models"
+	"github.com/tison2810/be-go-tc/sanitizer"
+)
+
+// TestcaseInputFileID trả về file ID trên Jobe chứa input (config.txt) của testcase
+func TestcaseInputFileID(testcase models.Testcase) string {
+	return strings.ReplaceAll(testcase.ID.String(), "-", "")
+}
+
+// UploadTestcaseInput upload input của testcase lên Jobe
+func UploadTestcaseInput(ctx context.Context, testcase models.Testcase) error {
+	return jobePool.PutFile(ctx, TestcaseInputFileID(testcase), []byte(testcase.Input))
+}
+
+// RunFile là một file của sinh viên trong run_spec
+type RunFile struct {
+	FileID   string // File ID trên Jobe
+	FileName string // Tên file khi chạy
+}
+
+// linkedSourceExtensions là đuôi của file nộp thêm được đưa vào linkargs
+var linkedSourceExtensions = []string{".c", ".cc", ".cpp", ".cxx"}
+
+// BuildRunSpec tạo run_spec để chạy testcase với các file của sinh viên theo cấu hình assignment
+func BuildRunSpec(assignment models.Assignment, files []RunFile, testcase models.Testcase, limits models.RunLimits) models.RunSpec {
+	var fileList [][]interface{}
+	for _, file := range files {
+		fileList = append(fileList, []interface{}{file.FileID, file.FileName})
+	}
+	for _, file := range assignment.SystemFiles {
+		fileList = append(fileList, []interface{}{file.FileID, file.FileName})
+	}
+
+	parameters := map[string]interface{}{
+		"cputime":     limits.MaxExecutionTime,
+		"memorylimit": jobeMemoryLimit(limits.MaxMemoryUsage),
+		"compileargs": assignment.CompileArgs,
+		"linkargs":    runLinkArgs(assignment, files),
+	}
+	if assignment.InputFilename != "" {
+		fileList = append(fileList, []interface{}{TestcaseInputFileID(testcase), assignment.InputFilename})
+		parameters["runargs"] = []string{assignment.InputFilename}
+	}
+
+	return models.RunSpec{
+		LanguageID:     assignment.LanguageID,
+		SourceCode:     assignment.Headers + testcase.Code,
+		SourceFilename: assignment.SourceFilename,
+		Input:          "",
+		FileList:       fileList,
+		Parameters:     parameters,
+		Debug:          true,
+	}
+}
+
+// buildModeRunSpec tạo run_spec theo chế độ chạy. Ở chế độ memcheck code được biên dịch với sanitizer
+// và không giới hạn bộ nhớ, limits được cập nhật theo để báo lại cho client.
+func buildModeRunSpec(assignment models.Assignment, files []RunFile, testcase models.Testcase, limits *models.RunLimits, mode models.RunMode) models.RunSpec {
+	if mode == models.RunModeMemcheck {
+		// AddressSanitizer cần dành trước vùng nhớ ảo rất lớn nên không giới hạn bộ nhớ
+		limits.MaxMemoryUsage = 0
+	}
+	runSpec := BuildRunSpec(assignment, files, testcase, *limits)
+	if mode == models.RunModeMemcheck {
+		sanitizer.Apply(&runSpec)
+	}
+	return runSpec
+}
+
+// jobeMemoryLimit đổi giới hạn bộ nhớ từ KB sang MB cho tham số memorylimit của Jobe, làm tròn lên.
+// 0 là không giới hạn.
+func jobeMemoryLimit(kb int) int {
+	if kb <= 0 {
+		return 0
+	}
+	return (kb + 1023) / 1024
+}
+
+// runLinkArgs bỏ khỏi linkargs các file sinh viên không nộp và thêm các file nguồn nộp thêm
+func runLinkArgs(assignment models.Assignment, files []RunFile) []string {
+	submitted := make(map[string]bool, len(files))
+	for _, file := range files {
+		submitted[file.FileName] = true
+	}
+	manifest := make(map[string]bool, len(assignment.StudentFiles))
+	for _, file := range assignment.StudentFiles {
+		manifest[file.FileName] = true
+	}
+
+	var args []string
+	linked := make(map[string]bool)
+	for _, arg := range assignment.LinkArgs {
+		if manifest[arg] && !submitted[arg] {
+			continue
+		}
+		args = append(args, arg)
+		linked[arg] = true
+	}
+	for _, file := range files {
+		if !manifest[file.FileName] && !linked[file.FileName] && hasExtension(linkedSourceExtensions, file.FileName) {
+			args = append(args, file.FileName)
+		}
+	}
+	return args
+}
+
+// ParseRunMode đọc chế độ chạy từ request, chuỗi rỗng là chạy bình thường
+func ParseRunMode(value string) (models.RunMode, error) {
+	switch mode := models.RunMode(value); mode {
+	case "", models.RunModeNormal:
+		return models.RunModeNormal, nil
+	case models.RunModeMemcheck:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown run mode %q", value)
+	}
+}
+
+// RunSubmission chạy lần lượt các testcase của post với file của sinh viên.
+// Testcase nào bị Jobe xếp hàng sẽ được đưa vào hàng đợi runs và có state queued.
+// Ở chế độ memcheck code được biên dịch với sanitizer, lỗi bộ nhớ nằm trong findings của từng testcase.
+func (s *PostService) RunSubmission(
+	ctx context.Context,
+	assignment models.Assignment,
+	studentMail string,
+	studentID string,
+	testcases []models.Testcase,
+	mode models.RunMode,
+) (*models.SubmitRunResponse, error) {
+	courseLimit, err := GetCourseLimit()
+	if err != nil {
+		return nil, fmt.Errorf("error loading course limits: %w", err)
+	}
+
+	harness, err := GetActiveHarness(assignment.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error loading harness: %w", err)
+	}
+	ApplyHarness(&assignment, harness)
+
+	files, uploadID := StudentRunFiles(studentMail, studentID, assignment)
+	fileIDs := make([]string, 0, len(files))
+	fileNames := make(map[string]string, len(files))
+	for _, file := range files {
+		fileIDs = append(fileIDs, file.FileID)
+		fileNames[file.FileID] = file.FileName
+	}
+	restored := EnsureStudentFiles(ctx, fileIDs)
+	reportProgress(ctx, models.RunEvent{Stage: models.RunStageFilesChecked, Total: len(testcases)})
+	if len(restored) > 0 {
+		event := models.RunEvent{Stage: models.RunStageFilesUploaded}
+		for _, fileID := range restored {
+			event.Files = append(event.Files, fileNames[fileID])
+		}
+		reportProgress(ctx, event)
+	}
+
+	source := RunSource{
+		SubmissionID: uuid.New(),
+		UploadID:     uploadID,
+	}
+	response := &models.SubmitRunResponse{
+		Status:       http.StatusOK,
+		SubmissionID: source.SubmissionID.String(),
+		Mode:         mode,
+	}
+	if source.UploadID != nil {
+		response.UploadID = source.UploadID.String()
+	}
+	if harness != nil {
+		source.HarnessID = &harness.ID
+		response.HarnessID = harness.ID.String()
+	}
+
+	for i, testcase := range testcases {
+		event := models.RunEvent{TestcaseID: testcase.ID.String(), Index: i + 1, Total: len(testcases)}
+		limits := ResolveRunLimits(courseLimit, assignment, testcase)
+		runSpec := buildModeRunSpec(assignment, files, testcase, &limits, mode)
+		caseResult := models.TestcaseResult{
+			TestcaseID: testcase.ID.String(),
+			Name:       testcase.Name,
+			Position:   testcase.Position,
+			Weight:     testcase.Weight,
+			Limits:     limits,
+		}
+
+		// Code, testcase và harness không đổi so với lần chạy trước thì dùng lại kết quả đã chấm
+		cacheKey := RunCacheKey(testcase, runSpec, source.HarnessID)
+		if entry := LookupRunCache(cacheKey); entry != nil {
+			studentRun, err := s.RecordCachedRun(testcase, studentMail, source, entry)
+			if err != nil {
+				return nil, fmt.Errorf("error recording cached run: %w", err)
+			}
+			caseResult.State = models.RunStateDone
+			caseResult.Cached = true
+			caseResult.Score = studentRun.Score
+			caseResult.Verdict = studentRun.Verdict
+			caseResult.Result = entry.Stdout
+			caseResult.Log = studentRun.Log
+			caseResult.Diff = studentRun.Diff
+			caseResult.Findings = studentRun.Findings
+			response.Cases = append(response.Cases, caseResult)
+			event.Stage, event.Verdict = models.RunStageCached, studentRun.Verdict
+			reportProgress(ctx, event)
+			continue
+		}
+
+		event.Stage = models.RunStageSubmitted
+		reportProgress(ctx, event)
+		jobeResult, err := jobePool.Run(ctx, runSpec)
+		if IsJobeBusy(jobeResult, err) {
+			run, err := EnqueueRun(testcase, studentMail, source.SubmissionID, runSpec)
+			if err != nil {
+				return nil, fmt.Errorf("error queueing run: %w", err)
+			}
+			caseResult.State = models.RunStateQueued
+			caseResult.RunID = run.ID.String()
+			response.Status = http.StatusAccepted
+			response.Cases = append(response.Cases, caseResult)
+			event.Stage, event.RunID = models.RunStageQueued, caseResult.RunID
+			reportProgress(ctx, event)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		event.Stage, event.Message = models.RunStageCompiled, "Compiled"
+		if jobeResult.Cmpinfo != "" || jobeResult.Outcome == jobe.OutcomeCompileError {
+			event.Message = "Compilation failed"
+		}
+		reportProgress(ctx, event)
+
+		studentRun, err := s.CheckRunResult(testcase, studentMail, source, jobeResult)
+		if err != nil {
+			return nil, fmt.Errorf("error checking run result: %w", err)
+		}
+		StoreRunCache(cacheKey, testcase, source.HarnessID, jobeResult.Stdout, studentRun)
+		caseResult.State = models.RunStateDone
+		caseResult.Score = studentRun.Score
+		caseResult.Verdict = studentRun.Verdict
+		caseResult.Result = jobeResult.Stdout
+		caseResult.Log = studentRun.Log
+		caseResult.Diff = studentRun.Diff
+		caseResult.Findings = studentRun.Findings
+		response.Cases = append(response.Cases, caseResult)
+		event.Stage, event.Message, event.Verdict = models.RunStageCompared, "", studentRun.Verdict
+		reportProgress(ctx, event)
+	}
+
+	summarizeSubmission(response)
+	return response, nil
+}
+
+// summarizeSubmission tính điểm tổng có trọng số và chọn Result/Log đại diện
+func summarizeSubmission(response *models.SubmitRunResponse) {
+	response.Score = 1
+	response.WeightedScore = 0
+	response.MaxScore = 0
+	var representative *models.TestcaseResult
+	for i := range response.Cases {
+		caseResult := &response.Cases[i]
+		response.MaxScore += caseResult.Weight
+		response.WeightedScore += caseResult.Weight * float64(caseResult.Score)
+		if caseResult.Score == 0 {
+			response.Score = 0
+			if representative == nil && caseResult.State == models.RunStateDone {
+				representative = caseResult
+			}
+		}
+	}
+	if len(response.Cases) == 0 {
+		response.Score = 0
+		return
+	}
+	if representative == nil {
+		representative = &response.Cases[0]
+	}
+	response.Result = representative.Result
+	response.Log = representative.Log
+	response.Verdict = representative.Verdict
+	response.Diff = representative.Diff
+	if response.Status == http.StatusAccepted {
+		response.Result = "Job queued for later execution"
+	}
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/tison2810/be-go-tc/jobe"
+	"github.com/tison2810/be-go-tc/jobe/jobetest"
+	"github.com/tison2810/be-go-tc/models"
+)
+
+func runServiceAssignment() models.Assignment {
+	return models.Assignment{
+		LanguageID:     "cpp",
+		SourceFilename: "tc.cpp",
+		InputFilename:  "config.txt",
+		CompileArgs:    []string{"-std=c++17"},
+		LinkArgs:       []string{"hcmcampaign.cpp"},
+		StudentFiles:   []models.AssignmentStudentFile{{FormKey: "cpp_file", FileName: "hcmcampaign.cpp"}},
+	}
+}
+
+func TestBuildRunSpecLimits(t *testing.T) {
+	testcase := models.Testcase{ID: uuid.MustParse("0b6f3c52-8a1d-4e1f-9c0a-2d5e6f7a8b9c"), Code: "int main() {}"}
+	files := []RunFile{{FileID: "2212345cpp", FileName: "hcmcampaign.cpp"}}
+	spec := BuildRunSpec(runServiceAssignment(), files, testcase, models.RunLimits{MaxExecutionTime: 12, MaxMemoryUsage: 300000})
+
+	parameters := spec.Parameters.(map[string]interface{})
+	for _, ignored := range []string{"max_execution_time", "max_memory_usage", "args"} {
+		if _, ok := parameters[ignored]; ok {
+			t.Errorf("parameters contain %s, which Jobe ignores", ignored)
+		}
+	}
+	limits := jobetest.ParseLimits(spec)
+	if limits.CPUTime != 12 {
+		t.Errorf("cputime = %d, want 12", limits.CPUTime)
+	}
+	if limits.MemoryLimit != 293 {
+		t.Errorf("memorylimit = %d MB, want 293 (300000 KB rounded up)", limits.MemoryLimit)
+	}
+	if !reflect.DeepEqual(limits.RunArgs, []string{"config.txt"}) {
+		t.Errorf("runargs = %v, want [config.txt]", limits.RunArgs)
+	}
+
+	spec = BuildRunSpec(runServiceAssignment(), files, testcase, models.RunLimits{MaxExecutionTime: 5})
+	if limits := jobetest.ParseLimits(spec); limits.MemoryLimit != 0 {
+		t.Errorf("memorylimit without a memory limit = %d, want 0", limits.MemoryLimit)
+	}
+}
+
+func TestBuildRunSpecLimitsReachJobe(t *testing.T) {
+	server := jobetest.NewServer()
+	defer server.Close()
+	server.SetFile("2212345cpp", []byte("// student code"))
+	server.SetFile("0b6f3c528a1d4e1f9c0a2d5e6f7a8b9c", []byte("input"))
+	// Testcase nặng chạy 8 giây và dùng 512 MB
+	server.SetUsageFunc(func(models.RunSpec) jobetest.Usage {
+		return jobetest.Usage{CPUTime: 8, Memory: 512}
+	})
+	pool := jobe.NewPool(server.JobeClient())
+
+	testcase := models.Testcase{ID: uuid.MustParse("0b6f3c52-8a1d-4e1f-9c0a-2d5e6f7a8b9c"), Code: "int main() {}"}
+	files := []RunFile{{FileID: "2212345cpp", FileName: "hcmcampaign.cpp"}}
+	tests := []struct {
+		name   string
+		limits models.RunLimits
+		want   models.Verdict
+	}{
+		{"default time limit", models.RunLimits{MaxExecutionTime: 5, MaxMemoryUsage: 1000000}, models.VerdictTimeLimit},
+		{"raised time limit", models.RunLimits{MaxExecutionTime: 10, MaxMemoryUsage: 1000000}, models.VerdictAccepted},
+		{"tight memory limit", models.RunLimits{MaxExecutionTime: 10, MaxMemoryUsage: 256 * 1024}, models.VerdictMemoryLimit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := pool.Run(context.Background(), BuildRunSpec(runServiceAssignment(), files, testcase, tt.limits))
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			if got := jobe.Verdict(result, true); got != tt.want {
+				t.Errorf("verdict = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildModeRunSpecMemcheck(t *testing.T) {
+	server := jobetest.NewServer()
+	defer server.Close()
+	server.SetFile("2212345cpp", []byte("// student code"))
+	server.SetFile("0b6f3c528a1d4e1f9c0a2d5e6f7a8b9c", []byte("input"))
+	// Vùng shadow memory của AddressSanitizer lớn hơn nhiều so với giới hạn thường
+	server.SetUsageFunc(func(spec models.RunSpec) jobetest.Usage {
+		return jobetest.Usage{CPUTime: 1, Memory: 20 * 1024}
+	})
+	pool := jobe.NewPool(server.JobeClient())
+
+	testcase := models.Testcase{ID: uuid.MustParse("0b6f3c52-8a1d-4e1f-9c0a-2d5e6f7a8b9c"), Code: "int main() {}"}
+	files := []RunFile{{FileID: "2212345cpp", FileName: "hcmcampaign.cpp"}}
+	for mode, want := range map[models.RunMode]models.Verdict{
+		models.RunModeNormal:   models.VerdictMemoryLimit,
+		models.RunModeMemcheck: models.VerdictAccepted,
+	} {
+		limits := models.RunLimits{MaxExecutionTime: 5, MaxMemoryUsage: 1000000}
+		spec := buildModeRunSpec(runServiceAssignment(), files, testcase, &limits, mode)
+		if mode == models.RunModeMemcheck {
+			if memoryLimit := spec.Parameters.(map[string]interface{})["memorylimit"]; memoryLimit != 0 {
+				t.Errorf("memcheck memorylimit = %v, want 0", memoryLimit)
+			}
+			if limits.MaxMemoryUsage != 0 {
+				t.Errorf("memcheck reports memory limit %d, want 0", limits.MaxMemoryUsage)
+			}
+		}
+
+		result, err := pool.Run(context.Background(), spec)
+		if err != nil {
+			t.Fatalf("%s: Run: %v", mode, err)
+		}
+		if got := jobe.Verdict(result, true); got != want {
+			t.Errorf("%s: verdict = %s, want %s", mode, got, want)
+		}
+	}
+}
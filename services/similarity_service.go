@@ -0,0 +1,262 @@
+package services
+
+import (
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/tison2810/be-go-tc/database"
+	"github.com/tison2810/be-go-tc/models"
+	"github.com/tison2810/be-go-tc/similarity"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	DefaultSimilarityThreshold = 0.6
+	// Hash có trong file của nhiều hơn tỉ lệ này số sinh viên được coi là code mẫu và bị bỏ qua
+	commonFingerprintRatio = 0.3
+	minCommonStudents      = 3
+)
+
+// similarityEntry là file trong phiên bản upload mới nhất của một sinh viên cùng dấu vân tay của nó
+type similarityEntry struct {
+	UploadFileID uuid.UUID
+	UploadID     uuid.UUID
+	StudentMail  string
+	Version      int
+	FormKey      string
+	FileName     string
+
+	fingerprints []models.Fingerprint
+	hashes       map[uint64]bool
+}
+
+// FingerprintUpload tính và lưu dấu vân tay của các file trong phiên bản upload, mỗi file chỉ tính một lần
+func FingerprintUpload(upload *models.StudentUpload) error {
+	if len(upload.Files) == 0 {
+		return nil
+	}
+	rows := make([]models.UploadFingerprint, 0, len(upload.Files))
+	for _, file := range upload.Files {
+		rows = append(rows, models.UploadFingerprint{
+			UploadFileID: file.ID,
+			UploadID:     upload.ID,
+			StudentMail:  upload.StudentMail,
+			AssignmentID: upload.AssignmentID,
+			FormKey:      file.FormKey,
+			Fingerprints: similarity.Fingerprints(string(file.Content)),
+		})
+	}
+	return database.DB.Db.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error
+}
+
+// SimilarityReport liệt kê các cặp sinh viên có file giống nhau từ threshold trở lên,
+// so sánh phiên bản upload mới nhất của mỗi sinh viên. formKey rỗng là so sánh mọi file.
+func SimilarityReport(assignmentID uuid.UUID, formKey string, threshold float64) ([]models.SimilarityPair, error) {
+	groups, err := similarityGroups(assignmentID, formKey)
+	if err != nil {
+		return nil, err
+	}
+
+	pairs := []models.SimilarityPair{}
+	for _, entries := range groups {
+		ignored := commonFingerprints(entries)
+		for _, candidate := range candidatePairs(entries, ignored, threshold) {
+			a, b := entries[candidate[0]], entries[candidate[1]]
+			if pair, ok := comparePair(a, b, ignored); ok && pair.Similarity >= threshold {
+				pairs = append(pairs, pair)
+			}
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i].Similarity > pairs[j].Similarity
+	})
+	return pairs, nil
+}
+
+// SimilarityPairDetail so sánh file formKey của hai sinh viên và trả về kèm nội dung để tô sáng vùng giống nhau
+func SimilarityPairDetail(assignmentID uuid.UUID, formKey, studentA, studentB string) (*models.SimilarityDetail, error) {
+	// Cần cả nhóm để bỏ qua code mẫu giống như khi lập báo cáo
+	groups, err := similarityGroups(assignmentID, formKey)
+	if err != nil {
+		return nil, err
+	}
+	entries := groups[formKey]
+	ignored := commonFingerprints(entries)
+
+	var a, b *similarityEntry
+	for i := range entries {
+		switch entries[i].StudentMail {
+		case studentA:
+			a = &entries[i]
+		case studentB:
+			b = &entries[i]
+		}
+	}
+	if a == nil || b == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	pair, _ := comparePair(*a, *b, ignored)
+	if pair.StudentA != a.StudentMail {
+		a, b = b, a
+	}
+	detail := &models.SimilarityDetail{SimilarityPair: pair}
+	var files []models.StudentUploadFile
+	if err := database.DB.Db.Where("id IN ?", []uuid.UUID{a.UploadFileID, b.UploadFileID}).Find(&files).Error; err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		side := models.SimilarityFile{FileName: file.FileName, Content: string(file.Content)}
+		if file.ID == a.UploadFileID {
+			side.StudentMail, side.Version = a.StudentMail, a.Version
+			detail.FileA = side
+		} else {
+			side.StudentMail, side.Version = b.StudentMail, b.Version
+			detail.FileB = side
+		}
+	}
+	return detail, nil
+}
+
+// similarityGroups lấy file trong phiên bản upload đang dùng (được student_files trỏ tới) của từng sinh viên,
+// nhóm theo form key. File chưa có dấu vân tay (upload trước khi có tính năng này) được tính và lưu lại.
+func similarityGroups(assignmentID uuid.UUID, formKey string) (map[string][]similarityEntry, error) {
+	var assignmentRef *uuid.UUID
+	if assignmentID != uuid.Nil {
+		assignmentRef = &assignmentID
+	}
+	// Sinh viên có thể đã khôi phục một phiên bản cũ nên không lấy theo version lớn nhất
+	active := database.DB.Db.Table("student_files AS sf").
+		Select("DISTINCT ON (u.student_mail) u.id, u.student_mail, u.version").
+		Joins("JOIN student_uploads AS u ON u.id = sf.upload_id").
+		Where("u.assignment_id IS NOT DISTINCT FROM ?", assignmentRef).
+		Order("u.student_mail, sf.uploaded_at DESC")
+	query := database.DB.Db.Table("student_upload_files AS f").
+		Select("f.id AS upload_file_id, f.upload_id, f.form_key, f.file_name, u.student_mail, u.version").
+		Joins("JOIN (?) AS u ON u.id = f.upload_id", active)
+	if formKey != "" {
+		query = query.Where("f.form_key = ?", formKey)
+	}
+	var entries []similarityEntry
+	if err := query.Scan(&entries).Error; err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return map[string][]similarityEntry{}, nil
+	}
+
+	fileIDs := make([]uuid.UUID, 0, len(entries))
+	for _, entry := range entries {
+		fileIDs = append(fileIDs, entry.UploadFileID)
+	}
+	var stored []models.UploadFingerprint
+	if err := database.DB.Db.Where("upload_file_id IN ?", fileIDs).Find(&stored).Error; err != nil {
+		return nil, err
+	}
+	byFile := make(map[uuid.UUID][]models.Fingerprint, len(stored))
+	for _, row := range stored {
+		byFile[row.UploadFileID] = row.Fingerprints
+	}
+
+	groups := make(map[string][]similarityEntry)
+	for _, entry := range entries {
+		fingerprints, ok := byFile[entry.UploadFileID]
+		if !ok {
+			var err error
+			if fingerprints, err = backfillFingerprints(entry, assignmentRef); err != nil {
+				return nil, err
+			}
+		}
+		entry.fingerprints = fingerprints
+		entry.hashes = similarity.Distinct(fingerprints)
+		groups[entry.FormKey] = append(groups[entry.FormKey], entry)
+	}
+	return groups, nil
+}
+
+func backfillFingerprints(entry similarityEntry, assignmentID *uuid.UUID) ([]models.Fingerprint, error) {
+	var file models.StudentUploadFile
+	if err := database.DB.Db.Select("id", "content").First(&file, "id = ?", entry.UploadFileID).Error; err != nil {
+		return nil, err
+	}
+	row := models.UploadFingerprint{
+		UploadFileID: entry.UploadFileID,
+		UploadID:     entry.UploadID,
+		StudentMail:  entry.StudentMail,
+		AssignmentID: assignmentID,
+		FormKey:      entry.FormKey,
+		Fingerprints: similarity.Fingerprints(string(file.Content)),
+	}
+	if err := database.DB.Db.Clauses(clause.OnConflict{DoNothing: true}).Create(&row).Error; err != nil {
+		return nil, err
+	}
+	return row.Fingerprints, nil
+}
+
+// commonFingerprints trả về các hash xuất hiện ở quá nhiều sinh viên, thường là code mẫu của đề bài
+func commonFingerprints(entries []similarityEntry) map[uint64]bool {
+	counts := make(map[uint64]int)
+	for _, entry := range entries {
+		for hash := range entry.hashes {
+			counts[hash]++
+		}
+	}
+	limit := max(minCommonStudents, int(commonFingerprintRatio*float64(len(entries))))
+	ignored := make(map[uint64]bool)
+	for hash, count := range counts {
+		if count > limit {
+			ignored[hash] = true
+		}
+	}
+	return ignored
+}
+
+// candidatePairs dùng chỉ mục ngược hash -> file để chỉ so sánh các cặp có đủ hash chung
+func candidatePairs(entries []similarityEntry, ignored map[uint64]bool, threshold float64) [][2]int {
+	index := make(map[uint64][]int)
+	sizes := make([]int, len(entries))
+	for i, entry := range entries {
+		for hash := range entry.hashes {
+			if !ignored[hash] {
+				index[hash] = append(index[hash], i)
+				sizes[i]++
+			}
+		}
+	}
+
+	shared := make(map[[2]int]int)
+	for _, postings := range index {
+		for x := 0; x < len(postings); x++ {
+			for y := x + 1; y < len(postings); y++ {
+				shared[[2]int{postings[x], postings[y]}]++
+			}
+		}
+	}
+
+	var candidates [][2]int
+	for pair, count := range shared {
+		smaller := min(sizes[pair[0]], sizes[pair[1]])
+		if smaller > 0 && float64(count)/float64(smaller) >= threshold {
+			candidates = append(candidates, pair)
+		}
+	}
+	return candidates
+}
+
+func comparePair(a, b similarityEntry, ignored map[uint64]bool) (models.SimilarityPair, bool) {
+	if b.StudentMail < a.StudentMail {
+		a, b = b, a
+	}
+	sharedCount, score, regions := similarity.Compare(a.fingerprints, b.fingerprints, ignored)
+	return models.SimilarityPair{
+		StudentA:   a.StudentMail,
+		StudentB:   b.StudentMail,
+		FormKey:    a.FormKey,
+		VersionA:   a.Version,
+		VersionB:   b.Version,
+		Similarity: score,
+		Shared:     sharedCount,
+		Regions:    regions,
+	}, sharedCount > 0
+}
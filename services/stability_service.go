@@ -0,0 +1,265 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tison2810/be-go-tc/database"
+	"github.com/tison2810/be-go-tc/diff"
+	"github.com/tison2810/be-go-tc/jobe"
+	"github.com/tison2810/be-go-tc/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	DefaultStabilityRuns  = 5
+	MaxStabilityRuns      = 10
+	MaxStabilityTotalRuns = 200              // Giới hạn runs × số testcase của một lần kiểm tra
+	stabilityCheckTimeout = 15 * time.Minute // Thời gian tối đa của một lần kiểm tra chạy nền
+	maxFlakySamples       = 3                // Số output khác nhau giữ lại cho mỗi testcase flaky
+	maxFlakySampleLength  = 2000             // Output mẫu dài hơn bị cắt bớt
+)
+
+var (
+	ErrStudentNotFound       = errors.New("student not found")
+	ErrStabilityTooManyRuns  = fmt.Errorf("stability check exceeds %d runs", MaxStabilityTotalRuns)
+	ErrStabilityCheckRunning = errors.New("stability check already running")
+)
+
+// stabilityRun chạy một testcase một lần trên Jobe, không dùng cache kết quả
+type stabilityRun func(ctx context.Context, testcase models.Testcase) (*models.JobeRunResult, error)
+
+// StartStabilityCheck tạo một lần kiểm tra ổn định và chạy nền: mỗi testcase của post được chạy runs lần
+// với lời giải mẫu, hoặc với bài nộp đang dùng của studentMail nếu có. Nếu post đang được kiểm tra thì
+// trả về lần kiểm tra đó cùng ErrStabilityCheckRunning.
+func StartStabilityCheck(assignment models.Assignment, post *models.Post, studentMail, requestedBy string, runs int) (*models.StabilityCheck, error) {
+	if runs*len(post.Testcases) > MaxStabilityTotalRuns {
+		return nil, ErrStabilityTooManyRuns
+	}
+	// Lỗi do thiếu lời giải mẫu hoặc sinh viên được báo ngay thay vì chờ client hỏi lại
+	ctx, cancel := context.WithTimeout(context.Background(), stabilityCheckTimeout)
+	run, err := stabilityRunner(ctx, assignment, studentMail)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	check := models.StabilityCheck{
+		ID:          uuid.New(),
+		PostID:      post.ID,
+		RequestedBy: requestedBy,
+		Source:      "reference",
+		Runs:        runs,
+		State:       models.RunStateRunning,
+	}
+	if studentMail != "" {
+		check.Source = studentMail
+	}
+	// Khóa dòng post để hai request đồng thời không cùng bắt đầu kiểm tra,
+	// index unique trên stability_checks chặn thêm ở mức database
+	var running models.StabilityCheck
+	err = database.DB.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.Post{}, "id = ?", post.ID).Error; err != nil {
+			return err
+		}
+		err := tx.Where("post_id = ? AND state = ?", post.ID, models.RunStateRunning).First(&running).Error
+		if err == nil {
+			return ErrStabilityCheckRunning
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		return tx.Create(&check).Error
+	})
+	if err != nil {
+		cancel()
+		if errors.Is(err, ErrStabilityCheckRunning) {
+			return &running, err
+		}
+		return nil, err
+	}
+
+	go func(result models.StabilityCheck) {
+		defer cancel()
+		report, err := checkPostStability(ctx, run, post, result.Source, runs)
+		result.State, result.Report = models.RunStateDone, report
+		if err != nil {
+			log.Printf("Stability check %s failed: %v", result.ID, err)
+			result.State, result.Error = models.RunStateFailed, err.Error()
+		}
+		if err := database.DB.Db.Model(&result).Select("state", "error", "report").Updates(&result).Error; err != nil {
+			log.Printf("Failed to save stability check %s: %v", result.ID, err)
+		}
+	}(check)
+	return &check, nil
+}
+
+// GetStabilityCheck lấy lần kiểm tra ổn định gần nhất của post
+func GetStabilityCheck(postID uuid.UUID) (*models.StabilityCheck, error) {
+	var check models.StabilityCheck
+	if err := database.DB.Db.Where("post_id = ?", postID).Order("created_at DESC").First(&check).Error; err != nil {
+		return nil, err
+	}
+	return &check, nil
+}
+
+// FailInterruptedStabilityChecks đánh dấu thất bại các lần kiểm tra đang chạy dở khi server tắt
+func FailInterruptedStabilityChecks() {
+	if err := database.DB.Db.Model(&models.StabilityCheck{}).
+		Where("state = ?", models.RunStateRunning).
+		Updates(map[string]interface{}{"state": models.RunStateFailed, "error": "interrupted by server restart"}).Error; err != nil {
+		log.Printf("Failed to mark interrupted stability checks: %v", err)
+	}
+}
+
+// checkPostStability chạy các testcase của post, đánh dấu flaky các testcase cho output khác nhau
+// giữa các lần chạy và lưu kết quả vào post
+func checkPostStability(ctx context.Context, run stabilityRun, post *models.Post, source string, runs int) (*models.StabilityReport, error) {
+	report := &models.StabilityReport{
+		PostID:    post.ID,
+		Source:    source,
+		Runs:      runs,
+		Testcases: make([]models.StabilityResult, 0, len(post.Testcases)),
+	}
+	for _, testcase := range post.Testcases {
+		result := checkTestcaseStability(ctx, run, testcase, runs)
+		report.Flaky = report.Flaky || result.Flaky
+		report.Testcases = append(report.Testcases, result)
+	}
+	report.CheckedAt = time.Now()
+
+	for i := range post.Testcases {
+		testcase := &post.Testcases[i]
+		testcase.Flaky = report.Testcases[i].Flaky
+		testcase.FlakySamples = nil
+		if testcase.Flaky {
+			testcase.FlakySamples = report.Testcases[i].Samples
+		}
+		if err := database.DB.Db.Model(testcase).Select("flaky", "flaky_samples").Updates(testcase).Error; err != nil {
+			return nil, err
+		}
+	}
+	post.Flaky = report.Flaky
+	post.StabilityCheckedAt = &report.CheckedAt
+	if err := database.DB.Db.Model(post).UpdateColumns(map[string]interface{}{
+		"flaky":                post.Flaky,
+		"stability_checked_at": post.StabilityCheckedAt,
+	}).Error; err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// stabilityRunner chọn code dùng để chạy: lời giải mẫu nếu studentMail rỗng, ngược lại là bài nộp của sinh viên
+func stabilityRunner(ctx context.Context, assignment models.Assignment, studentMail string) (stabilityRun, error) {
+	if studentMail == "" {
+		runner, err := newReferenceRunner(assignment)
+		if err != nil {
+			return nil, err
+		}
+		if runner == nil {
+			return nil, ErrNoReferenceSolution
+		}
+		return runner.run, nil
+	}
+
+	studentID, err := GetMaso(database.DB.Db, studentMail)
+	if err != nil {
+		return nil, err
+	}
+	if studentID == "" {
+		return nil, ErrStudentNotFound
+	}
+	harness, err := GetActiveHarness(assignment.ID)
+	if err != nil {
+		return nil, err
+	}
+	ApplyHarness(&assignment, harness)
+	courseLimit, err := GetCourseLimit()
+	if err != nil {
+		return nil, err
+	}
+	files, _ := StudentRunFiles(studentMail, studentID, assignment)
+	fileIDs := make([]string, 0, len(files))
+	for _, file := range files {
+		fileIDs = append(fileIDs, file.FileID)
+	}
+	EnsureStudentFiles(ctx, fileIDs)
+
+	return func(ctx context.Context, testcase models.Testcase) (*models.JobeRunResult, error) {
+		limits := ResolveRunLimits(courseLimit, assignment, testcase)
+		return jobePool.Run(ctx, BuildRunSpec(assignment, files, testcase, limits))
+	}, nil
+}
+
+// checkTestcaseStability chạy testcase runs lần và gom các lần chạy theo verdict và stdout
+func checkTestcaseStability(ctx context.Context, run stabilityRun, testcase models.Testcase, runs int) models.StabilityResult {
+	result := models.StabilityResult{
+		TestcaseID: testcase.ID.String(),
+		Name:       testcase.Name,
+		Position:   testcase.Position,
+	}
+
+	type outcome struct {
+		verdict models.Verdict
+		stdout  string
+		count   int
+	}
+	var outcomes []*outcome
+	for i := 0; i < runs; i++ {
+		jobeResult, err := run(ctx, testcase)
+		if err != nil || IsJobeBusy(jobeResult, nil) {
+			// Chạy thiếu lần thì vẫn kết luận từ các lần đã chạy xong
+			if err == nil {
+				err = jobe.ErrOverloaded
+			}
+			log.Printf("Stability run %d of testcase %s failed: %v", i+1, testcase.ID, err)
+			result.Error = fmt.Sprintf("Run %d could not be completed: %v", i+1, err)
+			break
+		}
+		result.Runs++
+
+		// Chỉ xét output có khác nhau hay không nên không so với expected
+		verdict := jobe.Verdict(jobeResult, true)
+		stdout := strings.ReplaceAll(jobeResult.Stdout, "\r\n", "\n")
+		if verdict == models.VerdictCompileError {
+			stdout = ""
+		}
+		found := false
+		for _, o := range outcomes {
+			if o.verdict == verdict && o.stdout == stdout {
+				o.count++
+				found = true
+				break
+			}
+		}
+		if !found {
+			outcomes = append(outcomes, &outcome{verdict: verdict, stdout: stdout, count: 1})
+		}
+	}
+
+	result.Flaky = len(outcomes) > 1
+	if !result.Flaky {
+		return result
+	}
+	sort.SliceStable(outcomes, func(i, j int) bool {
+		return outcomes[i].count > outcomes[j].count
+	})
+	result.Diff = diff.Unified(outcomes[0].stdout, outcomes[1].stdout, "output 1", "output 2")
+	for _, o := range outcomes[:min(len(outcomes), maxFlakySamples)] {
+		sample := models.FlakySample{Verdict: o.verdict, Stdout: o.stdout, Count: o.count}
+		if len(sample.Stdout) > maxFlakySampleLength {
+			sample.Stdout = strings.ToValidUTF8(sample.Stdout[:maxFlakySampleLength], "")
+			sample.Truncated = true
+		}
+		result.Samples = append(result.Samples, sample)
+	}
+	return result
+}
@@ -0,0 +1,380 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tison2810/be-go-tc/database"
+	"github.com/tison2810/be-go-tc/diff"
+	"github.com/tison2810/be-go-tc/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// UploadedFile là một file sinh viên gửi lên trong một lần upload
+type UploadedFile struct {
+	FileID   string
+	FormKey  string
+	FileName string
+	Content  []byte
+}
+
+// CreateUpload lưu các file thành một phiên bản upload mới của sinh viên và đặt chúng làm bản đang dùng
+func CreateUpload(studentMail string, assignmentID uuid.UUID, files []UploadedFile, warnings []models.PolicyViolation) (*models.StudentUpload, error) {
+	upload := &models.StudentUpload{
+		ID:          uuid.New(),
+		StudentMail: studentMail,
+		Warnings:    warnings,
+	}
+	if assignmentID != uuid.Nil {
+		upload.AssignmentID = &assignmentID
+	}
+	for _, file := range files {
+		sum := sha256.Sum256(file.Content)
+		upload.Files = append(upload.Files, models.StudentUploadFile{
+			ID:       uuid.New(),
+			UploadID: upload.ID,
+			FileID:   file.FileID,
+			FormKey:  file.FormKey,
+			FileName: file.FileName,
+			Content:  file.Content,
+			SHA256:   hex.EncodeToString(sum[:]),
+			Size:     int64(len(file.Content)),
+		})
+	}
+
+	err := database.DB.Db.Transaction(func(tx *gorm.DB) error {
+		// Khóa user để hai lần upload đồng thời không lấy trùng số phiên bản
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("mail = ?", studentMail).First(&models.User{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.StudentUpload{}).Where("student_mail = ?", studentMail).
+			Select("COALESCE(MAX(version), 0) + 1").Scan(&upload.Version).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(upload).Error; err != nil {
+			return err
+		}
+		return activateUpload(tx, upload)
+	})
+	if err != nil {
+		return nil, err
+	}
+	upload.Active = true
+	// Dấu vân tay dùng để phát hiện code giống nhau, lỗi ở đây không làm upload thất bại
+	if err := FingerprintUpload(upload); err != nil {
+		log.Printf("Failed to fingerprint upload %s: %v", upload.ID, err)
+	}
+	return upload, nil
+}
+
+// RestoreUpload đặt lại phiên bản version làm bản đang dùng của sinh viên và upload lại các file lên Jobe
+func RestoreUpload(ctx context.Context, studentMail string, version int) (*models.StudentUpload, error) {
+	upload, err := GetUpload(studentMail, version)
+	if err != nil {
+		return nil, err
+	}
+	if err := assignUploadFileIDs(upload); err != nil {
+		return nil, err
+	}
+	if err := database.DB.Db.Transaction(func(tx *gorm.DB) error {
+		return activateUpload(tx, upload)
+	}); err != nil {
+		return nil, err
+	}
+	upload.Active = true
+
+	for _, file := range upload.Files {
+		if err := jobePool.PutFile(ctx, file.FileID, file.Content); err != nil {
+			log.Printf("Failed to push restored file %s to Jobe, will retry before run: %v", file.FileID, err)
+		}
+	}
+	return upload, nil
+}
+
+// assignUploadFileIDs tính lại file ID trên Jobe theo cách đặt tên hiện tại cho các file của phiên bản,
+// vì phiên bản cũ có thể được lưu với file ID dùng chung giữa các assignment
+func assignUploadFileIDs(upload *models.StudentUpload) error {
+	if upload.AssignmentID == nil {
+		return nil
+	}
+	assignment, err := GetAssignment(*upload.AssignmentID)
+	if err != nil {
+		return err
+	}
+	studentID, err := GetMaso(database.DB.Db, upload.StudentMail)
+	if err != nil {
+		return err
+	}
+	if studentID == "" {
+		return ErrStudentNotFound
+	}
+
+	byKey := make(map[string]models.AssignmentStudentFile, len(assignment.StudentFiles))
+	for _, studentFile := range assignment.StudentFiles {
+		byKey[studentFile.FormKey] = studentFile
+	}
+	for i := range upload.Files {
+		file := &upload.Files[i]
+		if studentFile, ok := byKey[file.FormKey]; ok {
+			file.FileID = StudentFileID(studentID, assignment, studentFile)
+		} else {
+			file.FileID = ExtraFileID(studentID, assignment, file.FileName)
+		}
+	}
+	return nil
+}
+
+// activateUpload ghi các file của phiên bản vào bảng student_files để dùng khi chạy
+// và bỏ các file của phiên bản trước cùng assignment không còn trong phiên bản này
+func activateUpload(tx *gorm.DB, upload *models.StudentUpload) error {
+	fileIDs := make([]string, 0, len(upload.Files))
+	for _, file := range upload.Files {
+		fileIDs = append(fileIDs, file.FileID)
+	}
+	if err := tx.Where("student_mail = ? AND file_id NOT IN ?", upload.StudentMail, fileIDs).
+		Where("upload_id IN (?)", tx.Model(&models.StudentUpload{}).Select("id").
+			Where("student_mail = ? AND assignment_id IS NOT DISTINCT FROM ?", upload.StudentMail, upload.AssignmentID)).
+		Delete(&models.StudentFile{}).Error; err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, file := range upload.Files {
+		active := models.StudentFile{
+			FileID:      file.FileID,
+			StudentMail: upload.StudentMail,
+			FormKey:     file.FormKey,
+			FileName:    file.FileName,
+			Content:     file.Content,
+			SHA256:      file.SHA256,
+			Size:        file.Size,
+			UploadedAt:  now,
+			UploadID:    &upload.ID,
+			Version:     upload.Version,
+		}
+		if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&active).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetUpload lấy một phiên bản upload của sinh viên kèm nội dung file
+func GetUpload(studentMail string, version int) (*models.StudentUpload, error) {
+	var upload models.StudentUpload
+	if err := database.DB.Db.Preload("Files").
+		Where("student_mail = ? AND version = ?", studentMail, version).First(&upload).Error; err != nil {
+		return nil, err
+	}
+	return &upload, nil
+}
+
+// ListUploads lấy các phiên bản upload của sinh viên, mới nhất trước, không kèm nội dung file
+func ListUploads(studentMail string) ([]models.StudentUpload, error) {
+	var uploads []models.StudentUpload
+	if err := database.DB.Db.
+		Preload("Files", func(db *gorm.DB) *gorm.DB { return db.Omit("content") }).
+		Where("student_mail = ?", studentMail).Order("version DESC").Find(&uploads).Error; err != nil {
+		return nil, err
+	}
+
+	var activeIDs []uuid.UUID
+	if err := database.DB.Db.Model(&models.StudentFile{}).Where("student_mail = ? AND upload_id IS NOT NULL", studentMail).
+		Distinct().Pluck("upload_id", &activeIDs).Error; err != nil {
+		return nil, err
+	}
+	for i := range uploads {
+		for _, id := range activeIDs {
+			if uploads[i].ID == id {
+				uploads[i].Active = true
+			}
+		}
+	}
+	return uploads, nil
+}
+
+// DiffUploads so sánh từng file giữa hai phiên bản upload của sinh viên
+func DiffUploads(studentMail string, fromVersion, toVersion int) ([]models.UploadFileDiff, error) {
+	from, err := GetUpload(studentMail, fromVersion)
+	if err != nil {
+		return nil, err
+	}
+	to, err := GetUpload(studentMail, toVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	fromFiles := make(map[string]models.StudentUploadFile, len(from.Files))
+	for _, file := range from.Files {
+		fromFiles[file.FormKey] = file
+	}
+
+	var diffs []models.UploadFileDiff
+	fromLabel := fmt.Sprintf("v%d", fromVersion)
+	toLabel := fmt.Sprintf("v%d", toVersion)
+	addDiff := func(formKey, fileName string, before, after []byte, changed bool) {
+		fileDiff := models.UploadFileDiff{FormKey: formKey, FileName: fileName, Changed: changed}
+		if changed {
+			fileDiff.Unified = diff.Unified(string(before), string(after), fromLabel+"/"+fileName, toLabel+"/"+fileName)
+		}
+		diffs = append(diffs, fileDiff)
+	}
+	for _, file := range to.Files {
+		previous, ok := fromFiles[file.FormKey]
+		delete(fromFiles, file.FormKey)
+		addDiff(file.FormKey, file.FileName, previous.Content, file.Content, !ok || previous.SHA256 != file.SHA256)
+	}
+	for _, file := range from.Files {
+		if _, ok := fromFiles[file.FormKey]; ok {
+			addDiff(file.FormKey, file.FileName, file.Content, nil, true)
+		}
+	}
+	return diffs, nil
+}
+
+// GetStudentFiles lấy các file đang dùng theo file ID, file chưa upload không có trong map
+func GetStudentFiles(fileIDs []string) (map[string]models.StudentFile, error) {
+	var files []models.StudentFile
+	if err := database.DB.Db.Omit("content").Where("file_id IN ?", fileIDs).Find(&files).Error; err != nil {
+		return nil, err
+	}
+	result := make(map[string]models.StudentFile, len(files))
+	for _, file := range files {
+		result[file.FileID] = file
+	}
+	return result, nil
+}
+
+// ActiveUploadID trả về phiên bản upload đang dùng của các file trong run, nil nếu không xác định được
+func ActiveUploadID(studentMail string, fileIDs []string) *uuid.UUID {
+	var active models.StudentFile
+	err := database.DB.Db.Select("file_id", "upload_id").
+		Where("student_mail = ? AND file_id IN ? AND upload_id IS NOT NULL", studentMail, fileIDs).
+		Order("uploaded_at DESC").First(&active).Error
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Failed to find active upload: %v", err)
+		}
+		return nil
+	}
+	return active.UploadID
+}
+
+// StudentRunFiles trả về các file của phiên bản upload đang dùng của sinh viên cho assignment cùng ID phiên bản đó.
+// Không xác định được phiên bản đang dùng thì dùng đủ student_files theo assignment.
+func StudentRunFiles(studentMail, studentID string, assignment models.Assignment) ([]RunFile, *uuid.UUID) {
+	fileIDs := StudentFileIDs(studentID, assignment)
+	manifest := make([]RunFile, 0, len(fileIDs))
+	for i, studentFile := range assignment.StudentFiles {
+		manifest = append(manifest, RunFile{FileID: fileIDs[i], FileName: studentFile.FileName})
+	}
+
+	uploadID := ActiveUploadID(studentMail, fileIDs)
+	if uploadID == nil {
+		if uploadID = rekeyLegacyUpload(studentMail, assignment); uploadID == nil {
+			return manifest, nil
+		}
+	}
+	var upload models.StudentUpload
+	err := database.DB.Db.Preload("Files", func(db *gorm.DB) *gorm.DB { return db.Omit("content") }).
+		First(&upload, "id = ?", *uploadID).Error
+	if err != nil {
+		log.Printf("Failed to load active upload %s: %v", *uploadID, err)
+		return manifest, uploadID
+	}
+	if !uploadMatchesAssignment(upload, assignment) {
+		return manifest, uploadID
+	}
+
+	byKey := make(map[string]models.StudentUploadFile, len(upload.Files))
+	for _, file := range upload.Files {
+		byKey[file.FormKey] = file
+	}
+	files := make([]RunFile, 0, len(upload.Files))
+	for _, studentFile := range assignment.StudentFiles {
+		if file, ok := byKey[studentFile.FormKey]; ok {
+			files = append(files, RunFile{FileID: file.FileID, FileName: studentFile.FileName})
+			delete(byKey, studentFile.FormKey)
+		}
+	}
+	// Các file còn lại là file nộp thêm, chạy với đúng tên sinh viên đã nộp
+	for _, file := range upload.Files {
+		if _, ok := byKey[file.FormKey]; ok {
+			files = append(files, RunFile{FileID: file.FileID, FileName: file.FileName})
+		}
+	}
+	return files, uploadID
+}
+
+// rekeyLegacyUpload kích hoạt lại phiên bản upload của assignment được lưu trước khi file ID có phần băm
+// của assignment, để sinh viên không phải upload lại. Trả về nil nếu sinh viên chưa upload cho assignment.
+func rekeyLegacyUpload(studentMail string, assignment models.Assignment) *uuid.UUID {
+	if assignment.ID == uuid.Nil {
+		return nil
+	}
+	uploads := database.DB.Db.Model(&models.StudentUpload{}).Select("id").
+		Where("student_mail = ? AND assignment_id = ?", studentMail, assignment.ID)
+	var active models.StudentFile
+	err := database.DB.Db.Select("upload_id").Where("student_mail = ? AND upload_id IN (?)", studentMail, uploads).
+		Order("uploaded_at DESC").First(&active).Error
+	var upload models.StudentUpload
+	query := database.DB.Db.Preload("Files").Where("student_mail = ? AND assignment_id = ?", studentMail, assignment.ID)
+	if err == nil {
+		query = query.Where("id = ?", active.UploadID)
+	}
+	if err := query.Order("version DESC").First(&upload).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Failed to find legacy upload: %v", err)
+		}
+		return nil
+	}
+
+	if err := assignUploadFileIDs(&upload); err != nil {
+		log.Printf("Failed to rekey upload %s: %v", upload.ID, err)
+		return nil
+	}
+	if err := database.DB.Db.Transaction(func(tx *gorm.DB) error {
+		return activateUpload(tx, &upload)
+	}); err != nil {
+		log.Printf("Failed to activate rekeyed upload %s: %v", upload.ID, err)
+		return nil
+	}
+	return &upload.ID
+}
+
+func uploadMatchesAssignment(upload models.StudentUpload, assignment models.Assignment) bool {
+	if upload.AssignmentID == nil {
+		return assignment.ID == uuid.Nil
+	}
+	return *upload.AssignmentID == assignment.ID
+}
+
+// EnsureStudentFiles kiểm tra các Jobe server còn giữ file của sinh viên hay không
+// và upload lại từ database nếu file đã bị xóa khỏi cache. Trả về các file đã được upload lại.
+func EnsureStudentFiles(ctx context.Context, fileIDs []string) []string {
+	restored, err := jobePool.EnsureFiles(ctx, fileIDs...)
+	if err != nil {
+		log.Printf("Failed to ensure student files on Jobe: %v", err)
+	}
+	return restored
+}
+
+// studentFileSource cho phép Pool upload lại file sinh viên đã lưu lên node bị thiếu file
+func studentFileSource(ctx context.Context, fileID string) ([]byte, bool, error) {
+	var file models.StudentFile
+	err := database.DB.Db.WithContext(ctx).Select("file_id", "content").First(&file, "file_id = ?", fileID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return file.Content, true, nil
+}
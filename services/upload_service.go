@@ -0,0 +1,192 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/tison2810/be-go-tc/models"
+)
+
+const (
+	ArchiveFormKey       = "archive" // Key trong form-data của file zip chứa bài nộp
+	ExtraFilesFormKey    = "files"   // Key chứa nhiều file, ghép với student_files theo tên file
+	MaxArchiveEntries    = 64
+	DefaultMaxExtraFiles = 16
+)
+
+var extraFileNamePattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9._-]*$`)
+
+// UploadEntry là một file đọc từ request, chưa ghép với student_files của assignment
+type UploadEntry struct {
+	FormKey string // Rỗng nếu file được ghép theo tên
+	Name    string
+	Content []byte
+}
+
+// ReadArchive đọc các file trong zip, từ chối đường dẫn thoát khỏi thư mục gốc và file lớn hơn maxSize
+func ReadArchive(content []byte, maxSize int64) ([]UploadEntry, error) {
+	reader, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid zip archive: %w", err)
+	}
+	if len(reader.File) > MaxArchiveEntries {
+		return nil, fmt.Errorf("archive has more than %d entries", MaxArchiveEntries)
+	}
+
+	var entries []UploadEntry
+	for _, file := range reader.File {
+		name := strings.ReplaceAll(file.Name, `\`, "/")
+		if !isSafeArchivePath(name) {
+			return nil, fmt.Errorf("unsafe path %q in archive", file.Name)
+		}
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		base := path.Base(name)
+		// Bỏ qua metadata do hệ điều hành thêm vào khi nén
+		if strings.HasPrefix(name, "__MACOSX/") || strings.HasPrefix(base, ".") {
+			continue
+		}
+		if !file.Mode().IsRegular() {
+			return nil, fmt.Errorf("%s in archive is not a regular file", file.Name)
+		}
+		if file.UncompressedSize64 > uint64(maxSize) {
+			return nil, fmt.Errorf("%s in archive exceeds maximum size %dKB", file.Name, maxSize/1024)
+		}
+
+		src, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s in archive: %w", file.Name, err)
+		}
+		// Không tin kích thước ghi trong header, đọc tối đa maxSize+1 byte để phát hiện file lớn hơn khai báo
+		data, err := io.ReadAll(io.LimitReader(src, maxSize+1))
+		src.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s in archive: %w", file.Name, err)
+		}
+		if int64(len(data)) > maxSize {
+			return nil, fmt.Errorf("%s in archive exceeds maximum size %dKB", file.Name, maxSize/1024)
+		}
+		entries = append(entries, UploadEntry{Name: base, Content: data})
+	}
+	return entries, nil
+}
+
+// isSafeArchivePath từ chối đường dẫn tuyệt đối, có ổ đĩa hoặc có thành phần ..
+func isSafeArchivePath(name string) bool {
+	if name == "" || path.IsAbs(name) || strings.Contains(name, ":") {
+		return false
+	}
+	for _, segment := range strings.Split(name, "/") {
+		if segment == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchUploadFiles ghép các file gửi lên với student_files của assignment.
+// File có FormKey được ghép theo key, các file còn lại ghép theo tên file trên Jobe;
+// file không khớp là file nộp thêm nếu assignment cho phép đuôi của nó.
+func MatchUploadFiles(assignment models.Assignment, studentID string, entries []UploadEntry) ([]UploadedFile, error) {
+	byKey := make(map[string]int, len(assignment.StudentFiles))
+	byName := make(map[string]int, len(assignment.StudentFiles))
+	for i, studentFile := range assignment.StudentFiles {
+		byKey[studentFile.FormKey] = i
+		byName[studentFile.FileName] = i
+	}
+	reserved := map[string]bool{assignment.SourceFilename: true, assignment.InputFilename: true}
+	for _, file := range assignment.SystemFiles {
+		reserved[file.FileName] = true
+	}
+
+	matched := make([]*UploadEntry, len(assignment.StudentFiles))
+	var extras []UploadedFile
+	seenExtras := make(map[string]bool)
+	for i := range entries {
+		entry := &entries[i]
+		if int64(len(entry.Content)) > assignment.MaxFileSize {
+			return nil, fmt.Errorf("file %s exceeds maximum size %dKB (current size: %d bytes)", entry.Name, assignment.MaxFileSize/1024, len(entry.Content))
+		}
+
+		index, ok := byKey[entry.FormKey]
+		if entry.FormKey == "" {
+			index, ok = byName[entry.Name]
+		}
+		if ok {
+			studentFile := assignment.StudentFiles[index]
+			if matched[index] != nil {
+				return nil, fmt.Errorf("more than one file for %s", studentFile.FileName)
+			}
+			if !HasAllowedExtension(studentFile, entry.Name) {
+				return nil, fmt.Errorf("file %s must have extension %s", studentFile.FileName, strings.Join(studentFile.Extensions, ", "))
+			}
+			matched[index] = entry
+			continue
+		}
+
+		if !extraFileNamePattern.MatchString(entry.Name) || reserved[entry.Name] || !hasExtension(assignment.ExtraExtensions, entry.Name) {
+			return nil, fmt.Errorf("unexpected file %s", entry.Name)
+		}
+		if seenExtras[entry.Name] {
+			return nil, fmt.Errorf("more than one file named %s", entry.Name)
+		}
+		seenExtras[entry.Name] = true
+		extras = append(extras, UploadedFile{
+			FileID:   ExtraFileID(studentID, assignment, entry.Name),
+			FormKey:  entry.Name,
+			FileName: entry.Name,
+			Content:  entry.Content,
+		})
+	}
+	if limit := maxExtraFiles(assignment); len(extras) > limit {
+		return nil, fmt.Errorf("at most %d extra files are allowed", limit)
+	}
+
+	files := make([]UploadedFile, 0, len(assignment.StudentFiles)+len(extras))
+	for i, studentFile := range assignment.StudentFiles {
+		entry := matched[i]
+		if entry == nil {
+			if studentFile.Optional {
+				continue
+			}
+			return nil, fmt.Errorf("missing file %s (%s)", studentFile.FileName, studentFile.FormKey)
+		}
+		files = append(files, UploadedFile{
+			FileID:   StudentFileID(studentID, assignment, studentFile), // [xxxxxxx]cpp với assignment mặc định
+			FormKey:  studentFile.FormKey,
+			FileName: entry.Name,
+			Content:  entry.Content,
+		})
+	}
+	return append(files, extras...), nil
+}
+
+// ExtraFileID trả về file ID trên Jobe của file nộp thêm, cố định theo sinh viên, assignment và tên file
+func ExtraFileID(studentID string, assignment models.Assignment, fileName string) string {
+	sum := sha256.Sum256([]byte(assignment.ID.String() + "/" + fileName))
+	return studentID + "x" + hex.EncodeToString(sum[:])[:16]
+}
+
+func maxExtraFiles(assignment models.Assignment) int {
+	if assignment.MaxExtraFiles > 0 {
+		return assignment.MaxExtraFiles
+	}
+	return DefaultMaxExtraFiles
+}
+
+func hasExtension(extensions []string, filename string) bool {
+	for _, ext := range extensions {
+		if strings.HasSuffix(filename, ext) {
+			return true
+		}
+	}
+	return false
+}
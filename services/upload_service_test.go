@@ -0,0 +1,220 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"hash/crc32"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/tison2810/be-go-tc/models"
+)
+
+type archiveFile struct {
+	name    string
+	content string
+	mode    fs.FileMode
+}
+
+// buildArchive tạo file zip trong bộ nhớ với các file cho trước
+func buildArchive(t *testing.T, files ...archiveFile) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	for _, file := range files {
+		header := &zip.FileHeader{Name: file.name, Method: zip.Deflate}
+		if file.mode != 0 {
+			header.SetMode(file.mode)
+		}
+		w, err := writer.CreateHeader(header)
+		if err != nil {
+			t.Fatalf("create %s: %v", file.name, err)
+		}
+		if _, err := w.Write([]byte(file.content)); err != nil {
+			t.Fatalf("write %s: %v", file.name, err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("close archive: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadArchive(t *testing.T) {
+	content := buildArchive(t,
+		archiveFile{name: "submission/"},
+		archiveFile{name: "submission/hcmcampaign.cpp", content: "int main() {}"},
+		archiveFile{name: `submission\hcmcampaign.h`, content: "#pragma once"},
+		archiveFile{name: "__MACOSX/submission/._hcmcampaign.cpp", content: "metadata"},
+		archiveFile{name: "submission/.DS_Store", content: "metadata"},
+	)
+
+	entries, err := ReadArchive(content, 1024)
+	if err != nil {
+		t.Fatalf("ReadArchive: %v", err)
+	}
+	got := make(map[string]string)
+	for _, entry := range entries {
+		if entry.FormKey != "" {
+			t.Errorf("entry %s has form key %q, archive entries are matched by name", entry.Name, entry.FormKey)
+		}
+		got[entry.Name] = string(entry.Content)
+	}
+	want := map[string]string{"hcmcampaign.cpp": "int main() {}", "hcmcampaign.h": "#pragma once"}
+	if len(got) != len(want) {
+		t.Fatalf("entries = %v, want %v", got, want)
+	}
+	for name, content := range want {
+		if got[name] != content {
+			t.Errorf("entry %s = %q, want %q", name, got[name], content)
+		}
+	}
+}
+
+func TestReadArchiveRejectsUnsafePaths(t *testing.T) {
+	for _, name := range []string{
+		"../hcmcampaign.cpp",
+		"submission/../../hcmcampaign.cpp",
+		`..\hcmcampaign.cpp`,
+		`submission\..\..\hcmcampaign.cpp`,
+		"/etc/passwd",
+		`\windows\system.ini`,
+		"C:/hcmcampaign.cpp",
+		`C:\hcmcampaign.cpp`,
+		"c:hcmcampaign.cpp",
+	} {
+		t.Run(name, func(t *testing.T) {
+			content := buildArchive(t,
+				archiveFile{name: "main.cpp", content: "ok"},
+				archiveFile{name: name, content: "evil"},
+			)
+			entries, err := ReadArchive(content, 1024)
+			if err == nil || !strings.Contains(err.Error(), "unsafe path") {
+				t.Fatalf("ReadArchive = %v, %v; want unsafe path error", entries, err)
+			}
+		})
+	}
+}
+
+func TestReadArchiveRejectsOversizedEntries(t *testing.T) {
+	t.Run("header size", func(t *testing.T) {
+		content := buildArchive(t, archiveFile{name: "big.cpp", content: strings.Repeat("x", 2048)})
+		if _, err := ReadArchive(content, 1024); err == nil || !strings.Contains(err.Error(), "exceeds maximum size") {
+			t.Fatalf("err = %v, want size error", err)
+		}
+	})
+
+	t.Run("at limit", func(t *testing.T) {
+		content := buildArchive(t, archiveFile{name: "ok.cpp", content: strings.Repeat("x", 1024)})
+		if _, err := ReadArchive(content, 1024); err != nil {
+			t.Fatalf("file of exactly maxSize rejected: %v", err)
+		}
+	})
+
+	t.Run("understated header", func(t *testing.T) {
+		// Header khai báo 10 byte nhưng dữ liệu thật dài 4096 byte
+		data := []byte(strings.Repeat("y", 4096))
+		var buf bytes.Buffer
+		writer := zip.NewWriter(&buf)
+		w, err := writer.CreateRaw(&zip.FileHeader{
+			Name:               "liar.cpp",
+			Method:             zip.Store,
+			CRC32:              crc32.ChecksumIEEE(data),
+			CompressedSize64:   uint64(len(data)),
+			UncompressedSize64: 10,
+		})
+		if err != nil {
+			t.Fatalf("CreateRaw: %v", err)
+		}
+		w.Write(data)
+		writer.Close()
+
+		entries, err := ReadArchive(buf.Bytes(), 1024)
+		if err == nil {
+			t.Fatalf("ReadArchive accepted an entry larger than its header: %d entries", len(entries))
+		}
+	})
+}
+
+func TestReadArchiveEntryLimit(t *testing.T) {
+	files := make([]archiveFile, MaxArchiveEntries)
+	for i := range files {
+		files[i] = archiveFile{name: fmt.Sprintf("f%d.cpp", i), content: "x"}
+	}
+	entries, err := ReadArchive(buildArchive(t, files...), 1024)
+	if err != nil || len(entries) != MaxArchiveEntries {
+		t.Fatalf("%d entries: got %d, %v", MaxArchiveEntries, len(entries), err)
+	}
+
+	files = append(files, archiveFile{name: "one-more.cpp", content: "x"})
+	if _, err := ReadArchive(buildArchive(t, files...), 1024); err == nil || !strings.Contains(err.Error(), "more than") {
+		t.Fatalf("%d entries: err = %v, want entry limit error", len(files), err)
+	}
+
+	// Metadata và thư mục vẫn được tính vào giới hạn để không phải đọc archive quá lớn
+	files = files[:MaxArchiveEntries]
+	files = append(files, archiveFile{name: "__MACOSX/._f0.cpp", content: "x"})
+	if _, err := ReadArchive(buildArchive(t, files...), 1024); err == nil {
+		t.Fatal("entry limit ignored __MACOSX entries")
+	}
+}
+
+func TestReadArchiveRejectsSpecialFiles(t *testing.T) {
+	content := buildArchive(t, archiveFile{name: "link.cpp", content: "/etc/passwd", mode: fs.ModeSymlink | 0o777})
+	if _, err := ReadArchive(content, 1024); err == nil || !strings.Contains(err.Error(), "not a regular file") {
+		t.Fatalf("err = %v, want regular file error", err)
+	}
+
+	if _, err := ReadArchive([]byte("not a zip"), 1024); err == nil {
+		t.Fatal("ReadArchive accepted invalid zip data")
+	}
+}
+
+func TestMatchUploadFiles(t *testing.T) {
+	assignment := models.Assignment{
+		ID:             uuid.MustParse("6f1c1f3e-2d1b-4f7a-9a51-0d1e2f3a4b5c"),
+		SourceFilename: "tc.cpp",
+		MaxFileSize:    1024,
+		StudentFiles: []models.AssignmentStudentFile{
+			{FormKey: "cpp_file", FileName: "hcmcampaign.cpp", IDSuffix: "cpp", Extensions: []string{".cpp"}},
+			{FormKey: "h_file", FileName: "hcmcampaign.h", IDSuffix: "h", Extensions: []string{".h"}},
+			{FormKey: "notes", FileName: "notes.txt", IDSuffix: "txt", Optional: true},
+		},
+		SystemFiles:     []models.AssignmentSystemFile{{FileID: "systemmaincpp", FileName: "main.cpp"}},
+		ExtraExtensions: []string{".cpp", ".h"},
+	}
+
+	files, err := MatchUploadFiles(assignment, "2212345", []UploadEntry{
+		{FormKey: "cpp_file", Name: "my_solution.cpp", Content: []byte("a")},
+		{Name: "hcmcampaign.h", Content: []byte("b")},
+		{Name: "util.cpp", Content: []byte("c")},
+	})
+	if err != nil {
+		t.Fatalf("MatchUploadFiles: %v", err)
+	}
+	if len(files) != 3 {
+		t.Fatalf("files = %+v, want 3", files)
+	}
+	if files[0].FormKey != "cpp_file" || files[1].FormKey != "h_file" || files[2].FormKey != "util.cpp" {
+		t.Errorf("form keys = %s, %s, %s", files[0].FormKey, files[1].FormKey, files[2].FormKey)
+	}
+	if files[0].FileID != StudentFileID("2212345", assignment, assignment.StudentFiles[0]) ||
+		files[2].FileID != ExtraFileID("2212345", assignment, "util.cpp") {
+		t.Errorf("file IDs = %s, %s", files[0].FileID, files[2].FileID)
+	}
+
+	for name, entries := range map[string][]UploadEntry{
+		"missing required file": {{Name: "hcmcampaign.cpp"}},
+		"file too large":        {{Name: "hcmcampaign.cpp", Content: make([]byte, 2048)}, {Name: "hcmcampaign.h"}},
+		"reserved name":         {{Name: "hcmcampaign.cpp"}, {Name: "hcmcampaign.h"}, {Name: "main.cpp"}},
+		"extension not allowed": {{Name: "hcmcampaign.cpp"}, {Name: "hcmcampaign.h"}, {Name: "run.sh"}},
+		"unsafe extra name":     {{Name: "hcmcampaign.cpp"}, {Name: "hcmcampaign.h"}, {Name: "-rf.cpp"}},
+	} {
+		if _, err := MatchUploadFiles(assignment, "2212345", entries); err == nil {
+			t.Errorf("%s: MatchUploadFiles accepted %+v", name, entries)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+// Package similarity phát hiện code giống nhau giữa các bài nộp bằng cách chuẩn hóa token
+// và lấy dấu vân tay winnowing trên các k-gram token.
+package similarity
+
+import (
+	"hash/fnv"
+	"sort"
+
+	"github.com/tison2810/be-go-tc/models"
+)
+
+const (
+	KGram  = 12 // Số token trong một k-gram
+	Window = 8  // Mọi đoạn giống nhau dài từ KGram+Window-1 token trở lên chắc chắn được phát hiện
+
+	maxOccurrences = 4 // Số lần xuất hiện tối đa của một dấu vân tay được dùng để tìm vùng giống nhau
+)
+
+// Fingerprints trả về dấu vân tay winnowing của code, nil nếu code quá ngắn
+func Fingerprints(code string) []models.Fingerprint {
+	tokens := tokenize(code)
+	if len(tokens) < KGram {
+		return nil
+	}
+
+	hashes := make([]uint64, len(tokens)-KGram+1)
+	for i := range hashes {
+		h := fnv.New64a()
+		for _, t := range tokens[i : i+KGram] {
+			h.Write([]byte(t.text))
+			h.Write([]byte{0})
+		}
+		hashes[i] = h.Sum64()
+	}
+
+	var fingerprints []models.Fingerprint
+	add := func(i int) {
+		fingerprints = append(fingerprints, models.Fingerprint{
+			Hash:      hashes[i],
+			StartLine: tokens[i].line,
+			EndLine:   tokens[i+KGram-1].line,
+		})
+	}
+	if len(hashes) <= Window {
+		add(minIndex(hashes, 0, len(hashes)))
+		return fingerprints
+	}
+	// Mỗi cửa sổ chọn hash nhỏ nhất (lấy vị trí phải nhất khi bằng nhau), chỉ ghi lại khi vị trí đổi
+	last := -1
+	for start := 0; start+Window <= len(hashes); start++ {
+		if i := minIndex(hashes, start, start+Window); i != last {
+			add(i)
+			last = i
+		}
+	}
+	return fingerprints
+}
+
+func minIndex(hashes []uint64, from, to int) int {
+	best := from
+	for i := from + 1; i < to; i++ {
+		if hashes[i] <= hashes[best] {
+			best = i
+		}
+	}
+	return best
+}
+
+// Distinct trả về tập hash của các dấu vân tay
+func Distinct(fingerprints []models.Fingerprint) map[uint64]bool {
+	set := make(map[uint64]bool, len(fingerprints))
+	for _, fp := range fingerprints {
+		set[fp.Hash] = true
+	}
+	return set
+}
+
+// Compare so sánh hai tập dấu vân tay, bỏ qua các hash trong ignored (ví dụ code mẫu mà mọi người đều có).
+// Độ giống là số hash chung chia cho số hash của bên ít hơn.
+func Compare(a, b []models.Fingerprint, ignored map[uint64]bool) (int, float64, []models.MatchedRegion) {
+	byHashA := groupByHash(a, ignored)
+	byHashB := groupByHash(b, ignored)
+	if len(byHashA) == 0 || len(byHashB) == 0 {
+		return 0, 0, nil
+	}
+
+	shared := 0
+	var matches []models.MatchedRegion
+	for hash, occurrencesA := range byHashA {
+		occurrencesB, ok := byHashB[hash]
+		if !ok {
+			continue
+		}
+		shared++
+		for _, fa := range occurrencesA {
+			for _, fb := range occurrencesB {
+				matches = append(matches, models.MatchedRegion{
+					StartA: fa.StartLine, EndA: fa.EndLine,
+					StartB: fb.StartLine, EndB: fb.EndLine,
+				})
+			}
+		}
+	}
+	score := float64(shared) / float64(min(len(byHashA), len(byHashB)))
+	return shared, score, mergeRegions(matches)
+}
+
+func groupByHash(fingerprints []models.Fingerprint, ignored map[uint64]bool) map[uint64][]models.Fingerprint {
+	groups := make(map[uint64][]models.Fingerprint)
+	for _, fp := range fingerprints {
+		if ignored[fp.Hash] || len(groups[fp.Hash]) >= maxOccurrences {
+			continue
+		}
+		groups[fp.Hash] = append(groups[fp.Hash], fp)
+	}
+	return groups
+}
+
+// mergeRegions gộp các cặp vùng chồng lên hoặc nằm sát nhau ở cả hai bên
+func mergeRegions(matches []models.MatchedRegion) []models.MatchedRegion {
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].StartA != matches[j].StartA {
+			return matches[i].StartA < matches[j].StartA
+		}
+		return matches[i].StartB < matches[j].StartB
+	})
+
+	var merged []models.MatchedRegion
+	for _, m := range matches {
+		joined := false
+		for i := len(merged) - 1; i >= 0 && i >= len(merged)-4; i-- {
+			r := &merged[i]
+			if m.StartA <= r.EndA+1 && m.StartB <= r.EndB+1 && m.EndB >= r.StartB-1 {
+				r.EndA = max(r.EndA, m.EndA)
+				r.StartB = min(r.StartB, m.StartB)
+				r.EndB = max(r.EndB, m.EndB)
+				joined = true
+				break
+			}
+		}
+		if !joined {
+			merged = append(merged, m)
+		}
+	}
+	return merged
+}
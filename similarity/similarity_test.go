@@ -0,0 +1,147 @@
+package similarity
+
+import (
+	"strings"
+	"testing"
+)
+
+const original = `#include "hcmcampaign.h"
+
+// Tính tổng điểm tấn công của cả đội quân
+int Army::totalScore(Unit **units, int size) {
+    int total = 0;
+    for (int i = 0; i < size; i++) {
+        if (units[i] == nullptr) continue;
+        total += units[i]->getAttackScore() * 2 + 1;
+    }
+    return total;
+}
+
+bool Army::isStronger(Army *other) {
+    return this->totalScore(units, size) > other->totalScore(other->units, other->size);
+}
+`
+
+// renamed là original với tên biến/hàm đổi hết, comment và khoảng trắng khác đi
+const renamed = `#include "hcmcampaign.h"
+int Army::calcPower(Unit **arr, int n)
+{
+  int sum = 0;   /* accumulator */
+  for (int k = 0; k < n; k++)
+  {
+    if (arr[k] == nullptr)
+      continue;
+    sum += arr[k]->getAttackScore() * 7 + 3;
+  }
+  return sum;
+}
+bool Army::beats(Army *enemy) { return this->calcPower(arr, n) > enemy->calcPower(enemy->arr, enemy->n); }
+`
+
+const unrelated = `#include <iostream>
+#include <string>
+using namespace std;
+
+class Position {
+public:
+    Position(const string &text) {
+        switch (text.size()) {
+        case 0: throw "empty";
+        default: break;
+        }
+        while (!ready) { ready = parse(text); }
+    }
+    string str() const { return "(" + to_string(r) + "," + to_string(c) + ")"; }
+private:
+    bool ready = false;
+    int r, c;
+};
+`
+
+func TestFingerprintsShortCode(t *testing.T) {
+	if fps := Fingerprints("int main() { return 0; }"); fps != nil {
+		t.Errorf("Fingerprints of short code = %v, want nil", fps)
+	}
+	if fps := Fingerprints("// chỉ có comment\n#include <iostream>\n"); fps != nil {
+		t.Errorf("Fingerprints of comments = %v, want nil", fps)
+	}
+}
+
+func TestFingerprintsLines(t *testing.T) {
+	fps := Fingerprints(original)
+	if len(fps) == 0 {
+		t.Fatal("no fingerprints")
+	}
+	lines := strings.Count(original, "\n")
+	for _, fp := range fps {
+		if fp.StartLine < 4 || fp.EndLine > lines || fp.StartLine > fp.EndLine {
+			t.Errorf("fingerprint lines %d-%d out of code range 4-%d", fp.StartLine, fp.EndLine, lines)
+		}
+	}
+}
+
+func TestCompareRenamedIdentifiers(t *testing.T) {
+	a, b := Fingerprints(original), Fingerprints(renamed)
+	shared, score, regions := Compare(a, b, nil)
+	if score < 0.6 || shared == 0 {
+		t.Fatalf("renamed copy: shared %d, score %.2f, want >= 0.6", shared, score)
+	}
+	if len(regions) == 0 {
+		t.Fatal("renamed copy: no matched regions")
+	}
+	for _, r := range regions {
+		if r.StartA > r.EndA || r.StartB > r.EndB {
+			t.Errorf("invalid region %+v", r)
+		}
+	}
+
+	// Độ giống đối xứng
+	if _, reverse, _ := Compare(b, a, nil); reverse != score {
+		t.Errorf("Compare(b, a) = %.2f, Compare(a, b) = %.2f", reverse, score)
+	}
+}
+
+func TestCompareIdentical(t *testing.T) {
+	a := Fingerprints(original)
+	shared, score, _ := Compare(a, a, nil)
+	if score != 1 || shared != len(Distinct(a)) {
+		t.Errorf("identical: shared %d, score %.2f", shared, score)
+	}
+}
+
+func TestCompareUnrelated(t *testing.T) {
+	_, score, _ := Compare(Fingerprints(original), Fingerprints(unrelated), nil)
+	if score > 0.2 {
+		t.Errorf("unrelated code: score %.2f, want <= 0.2", score)
+	}
+}
+
+func TestCompareIgnored(t *testing.T) {
+	a, b := Fingerprints(original), Fingerprints(renamed)
+	ignored := Distinct(a)
+	shared, score, regions := Compare(a, b, ignored)
+	if shared != 0 || score != 0 || regions != nil {
+		t.Errorf("all fingerprints ignored: shared %d, score %.2f, regions %v", shared, score, regions)
+	}
+
+	if shared, score, _ := Compare(nil, b, nil); shared != 0 || score != 0 {
+		t.Errorf("empty side: shared %d, score %.2f", shared, score)
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	code := "#define MAX \\\n  100\nint x = 42; // comment\nchar *s = \"a\\\"b\"; /* multi\nline */ char c = '\\'';\nx <<= 2;"
+	var texts []string
+	for _, tok := range tokenize(code) {
+		texts = append(texts, tok.text)
+	}
+	want := "int V = N ; char * V = S ; char V = C ; V <<= N ;"
+	if got := strings.Join(texts, " "); got != want {
+		t.Errorf("tokens = %q, want %q", got, want)
+	}
+
+	tokens := tokenize(code)
+	if tokens[0].line != 3 || tokens[len(tokens)-1].line != 6 {
+		t.Errorf("lines = %d..%d, want 3..6", tokens[0].line, tokens[len(tokens)-1].line)
+	}
+}
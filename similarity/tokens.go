@@ -0,0 +1,131 @@
+package similarity
+
+import "strings"
+
+// token là một token đã chuẩn hóa của code C/C++ cùng dòng chứa nó
+type token struct {
+	text string
+	line int
+}
+
+// keywords được giữ nguyên khi chuẩn hóa, các tên khác đều thành V
+var keywords = map[string]bool{
+	"auto": true, "bool": true, "break": true, "case": true, "catch": true, "char": true, "class": true,
+	"const": true, "continue": true, "default": true, "delete": true, "do": true, "double": true,
+	"else": true, "enum": true, "explicit": true, "false": true, "float": true, "for": true,
+	"friend": true, "goto": true, "if": true, "inline": true, "int": true, "long": true, "namespace": true,
+	"new": true, "nullptr": true, "operator": true, "private": true, "protected": true, "public": true,
+	"return": true, "short": true, "signed": true, "sizeof": true, "static": true, "struct": true,
+	"switch": true, "template": true, "this": true, "throw": true, "true": true, "try": true,
+	"typedef": true, "typename": true, "union": true, "unsigned": true, "using": true, "virtual": true,
+	"void": true, "volatile": true, "while": true, "override": true, "NULL": true,
+}
+
+// operators được so khớp dài nhất trước
+var operators = []string{
+	">>=", "<<=", "->*", "...",
+	"::", "->", "++", "--", "<<", ">>", "<=", ">=", "==", "!=", "&&", "||",
+	"+=", "-=", "*=", "/=", "%=", "&=", "|=", "^=", ".*",
+}
+
+// tokenize tách code thành token đã chuẩn hóa: bỏ comment và dòng tiền xử lý,
+// tên biến/hàm/kiểu thành V, số thành N, chuỗi thành S, ký tự thành C
+func tokenize(code string) []token {
+	var tokens []token
+	line := 1
+	lineStart := true
+	for i := 0; i < len(code); {
+		ch := code[i]
+		switch {
+		case ch == '\n':
+			line++
+			lineStart = true
+			i++
+			continue
+		case ch == ' ' || ch == '\t' || ch == '\r' || ch == '\f' || ch == '\v':
+			i++
+			continue
+		case ch == '#' && lineStart:
+			// Bỏ cả dòng tiền xử lý, kể cả các dòng nối bằng \
+			for i < len(code) && code[i] != '\n' {
+				if code[i] == '\\' && i+1 < len(code) && code[i+1] == '\n' {
+					line++
+					i++
+				}
+				i++
+			}
+			continue
+		}
+		lineStart = false
+
+		switch {
+		case strings.HasPrefix(code[i:], "//"):
+			for i < len(code) && code[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(code[i:], "/*"):
+			end := strings.Index(code[i+2:], "*/")
+			if end < 0 {
+				end = len(code) - i - 2
+			} else {
+				end += 2
+			}
+			line += strings.Count(code[i:i+2+end], "\n")
+			i += 2 + end
+		case ch == '"' || ch == '\'':
+			start := line
+			i++
+			for i < len(code) && code[i] != ch && code[i] != '\n' {
+				if code[i] == '\\' && i+1 < len(code) {
+					i++
+				}
+				i++
+			}
+			i++
+			text := "S"
+			if ch == '\'' {
+				text = "C"
+			}
+			tokens = append(tokens, token{text: text, line: start})
+		case isIdentStart(ch):
+			start := i
+			for i < len(code) && isIdentPart(code[i]) {
+				i++
+			}
+			text := code[start:i]
+			if !keywords[text] {
+				text = "V"
+			}
+			tokens = append(tokens, token{text: text, line: line})
+		case isDigit(ch) || (ch == '.' && i+1 < len(code) && isDigit(code[i+1])):
+			for i < len(code) && (isIdentPart(code[i]) || code[i] == '.' || code[i] == '\'' ||
+				((code[i] == '+' || code[i] == '-') && (code[i-1] == 'e' || code[i-1] == 'E'))) {
+				i++
+			}
+			tokens = append(tokens, token{text: "N", line: line})
+		default:
+			text := code[i : i+1]
+			for _, op := range operators {
+				if strings.HasPrefix(code[i:], op) {
+					text = op
+					break
+				}
+			}
+			i += len(text)
+			tokens = append(tokens, token{text: text, line: line})
+		}
+	}
+	return tokens
+}
+
+func isIdentStart(ch byte) bool {
+	return ch == '_' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || ch >= 0x80
+}
+
+func isIdentPart(ch byte) bool {
+	return isIdentStart(ch) || isDigit(ch)
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}